@@ -0,0 +1,36 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/diamondburned/arikawa/api"
+	"github.com/diamondburned/arikawa/api/rate"
+	"github.com/diamondburned/arikawa/api/rate/proxy"
+)
+
+// To run, do `PROXY_ADDR="localhost:8080" go run .`
+//
+// Each bot process using the same token should then use the proxy as its rate
+// limiter:
+//
+//    s.Client.Limiter = proxy.NewClient("http://localhost:8080")
+
+func main() {
+	var addr = os.Getenv("PROXY_ADDR")
+	if addr == "" {
+		addr = "localhost:8080"
+	}
+
+	s := proxy.NewServer(rate.NewLimiter(api.APIPath))
+	s.ErrorLog = func(err error) {
+		log.Println("Error:", err)
+	}
+
+	log.Println("Listening on", addr)
+
+	if err := http.ListenAndServe(addr, s); err != nil {
+		log.Fatalln("Failed to serve:", err)
+	}
+}
@@ -36,6 +36,14 @@ func main() {
 		}
 	})
 
+	// The State dispatches the old message with the new one after edits.
+	s.AddHandler(func(ev *state.MessageUpdateEvent) {
+		if ev.Old != nil && ev.Old.Content != ev.New.Content {
+			log.Println(ev.New.Author.Username, "edited", ev.Old.Content,
+				"to", ev.New.Content)
+		}
+	})
+
 	if err := s.Open(); err != nil {
 		log.Fatalln("Failed to connect:", err)
 	}
@@ -3,7 +3,9 @@
 package api
 
 import (
+	"context"
 	"net/http"
+	"sync"
 
 	"github.com/diamondburned/arikawa/api/rate"
 	"github.com/diamondburned/arikawa/internal/httputil"
@@ -23,7 +25,7 @@ var UserAgent = "DiscordBot (https://github.com/diamondburned/arikawa, v0.0.1)"
 
 type Client struct {
 	httputil.Client
-	Limiter *rate.Limiter
+	Limiter rate.RateLimiter
 
 	Token string
 }
@@ -35,6 +37,10 @@ func NewClient(token string) *Client {
 		Token:   token,
 	}
 
+	// The locks held by requests in flight, so that each request releases the
+	// bucket it acquired.
+	var locks sync.Map // *http.Request -> rate.Lock
+
 	tw := httputil.NewTransportWrapper()
 	tw.Pre = func(r *http.Request) error {
 		if cli.Token != "" {
@@ -45,16 +51,41 @@ func NewClient(token string) *Client {
 		r.Header.Set("X-RateLimit-Precision", "millisecond")
 
 		// Rate limit stuff
-		return cli.Limiter.Acquire(r.Context(), r.URL.Path)
+		lock, err := cli.Limiter.Acquire(r.Context(), r.URL.Path)
+		if err != nil {
+			return err
+		}
+
+		locks.Store(r, lock)
+		return nil
 	}
 	tw.Cancel = func(r *http.Request, err error) {
-		cli.Limiter.Cancel(r.URL.Path)
+		if lock, ok := locks.Load(r); ok {
+			locks.Delete(r)
+			lock.(rate.Lock).Cancel()
+		}
 	}
-	tw.Post = func(r *http.Response) error {
-		return cli.Limiter.Release(r.Request.URL.Path, r.Header)
+	tw.Post = func(r *http.Request, resp *http.Response) error {
+		lock, ok := locks.Load(r)
+		if !ok {
+			return nil
+		}
+
+		locks.Delete(r)
+		return lock.(rate.Lock).Release(resp.Header)
 	}
 
 	cli.Client.Transport = tw
+	cli.Client.RateLimited = true
 
 	return cli
 }
+
+// WithContext returns a shallow copy of the client, which uses ctx for all of
+// its requests, including the waits for rate limits. The copy shares the
+// token and the rate limiter.
+func (c *Client) WithContext(ctx context.Context) *Client {
+	cpy := *c
+	cpy.Client = *c.Client.WithContext(ctx)
+	return &cpy
+}
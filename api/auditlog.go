@@ -0,0 +1,40 @@
+package api
+
+import (
+	"github.com/diamondburned/arikawa/discord"
+	"github.com/diamondburned/arikawa/internal/httputil"
+)
+
+// AuditLogData contains query parameters used for AuditLog. All fields are
+// optional.
+type AuditLogData struct {
+	// Filter the log for actions made by a user
+	UserID discord.Snowflake `schema:"user_id,omitempty"`
+	// The type of audit log event
+	ActionType discord.AuditLogEvent `schema:"action_type,omitempty"`
+	// Filter the log before a certain entry ID
+	Before discord.Snowflake `schema:"before,omitempty"`
+	// How many entries are returned (default 50, minimum 1, maximum 100)
+	Limit uint `schema:"limit"`
+}
+
+// AuditLog returns an audit log object for the guild. Requires the
+// VIEW_AUDIT_LOG permission.
+func (c *Client) AuditLog(
+	guildID discord.Snowflake, data AuditLogData) (*discord.AuditLog, error) {
+
+	switch {
+	case data.Limit == 0:
+		data.Limit = 50
+	case data.Limit > 100:
+		data.Limit = 100
+	}
+
+	var audit *discord.AuditLog
+
+	return audit, c.RequestJSON(
+		&audit, "GET",
+		EndpointGuilds+guildID.String()+"/audit-logs",
+		httputil.WithSchema(c, data),
+	)
+}
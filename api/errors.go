@@ -0,0 +1,87 @@
+package api
+
+import "github.com/diamondburned/arikawa/internal/httputil"
+
+type (
+	// HTTPError is returned when Discord responds with a failure status. Use
+	// errors.Is with an ErrorCode to check for a specific error:
+	//
+	//    if errors.Is(err, api.ErrMissingPermissions) {
+	//        ...
+	//    }
+	//
+	HTTPError = httputil.HTTPError
+	// ErrorCode is a JSON error code from Discord.
+	ErrorCode = httputil.ErrorCode
+	// FieldError is the error of an invalid field in the request.
+	FieldError = httputil.FieldError
+	// ErrorTree is the tree of invalid fields in the request.
+	ErrorTree = httputil.ErrorTree
+)
+
+// https://discordapp.com/developers/docs/topics/opcodes-and-status-codes#json
+const (
+	ErrUnknownAccount             ErrorCode = 10001
+	ErrUnknownApplication         ErrorCode = 10002
+	ErrUnknownChannel             ErrorCode = 10003
+	ErrUnknownGuild               ErrorCode = 10004
+	ErrUnknownIntegration         ErrorCode = 10005
+	ErrUnknownInvite              ErrorCode = 10006
+	ErrUnknownMember              ErrorCode = 10007
+	ErrUnknownMessage             ErrorCode = 10008
+	ErrUnknownPermissionOverwrite ErrorCode = 10009
+	ErrUnknownProvider            ErrorCode = 10010
+	ErrUnknownRole                ErrorCode = 10011
+	ErrUnknownToken               ErrorCode = 10012
+	ErrUnknownUser                ErrorCode = 10013
+	ErrUnknownEmoji               ErrorCode = 10014
+	ErrUnknownWebhook             ErrorCode = 10015
+	ErrUnknownBan                 ErrorCode = 10026
+
+	ErrBotsCannotUseEndpoint ErrorCode = 20001
+	ErrOnlyBotsCanUse        ErrorCode = 20002
+
+	ErrMaxGuilds      ErrorCode = 30001
+	ErrMaxFriends     ErrorCode = 30002
+	ErrMaxPins        ErrorCode = 30003
+	ErrMaxRoles       ErrorCode = 30005
+	ErrMaxWebhooks    ErrorCode = 30007
+	ErrMaxReactions   ErrorCode = 30010
+	ErrMaxChannels    ErrorCode = 30013
+	ErrMaxAttachments ErrorCode = 30015
+	ErrMaxInvites     ErrorCode = 30016
+
+	ErrUnauthorized        ErrorCode = 40001
+	ErrVerifyAccount       ErrorCode = 40002
+	ErrRequestTooLarge     ErrorCode = 40005
+	ErrFeatureDisabled     ErrorCode = 40006
+	ErrUserBannedFromGuild ErrorCode = 40007
+
+	ErrMissingAccess         ErrorCode = 50001
+	ErrInvalidAccountType    ErrorCode = 50002
+	ErrCannotExecuteOnDM     ErrorCode = 50003
+	ErrWidgetDisabled        ErrorCode = 50004
+	ErrCannotEditOthersMsg   ErrorCode = 50005
+	ErrCannotSendEmptyMsg    ErrorCode = 50006
+	ErrCannotSendToUser      ErrorCode = 50007
+	ErrCannotSendInVoice     ErrorCode = 50008
+	ErrVerificationTooHigh   ErrorCode = 50009
+	ErrOAuth2NoBot           ErrorCode = 50010
+	ErrOAuth2LimitReached    ErrorCode = 50011
+	ErrInvalidOAuth2State    ErrorCode = 50012
+	ErrMissingPermissions    ErrorCode = 50013
+	ErrInvalidToken          ErrorCode = 50014
+	ErrNoteTooLong           ErrorCode = 50015
+	ErrInvalidDeleteCount    ErrorCode = 50016
+	ErrPinInWrongChannel     ErrorCode = 50019
+	ErrInvalidInviteCode     ErrorCode = 50020
+	ErrCannotExecuteOnSystem ErrorCode = 50021
+	ErrInvalidOAuth2Token    ErrorCode = 50025
+	ErrMessageTooOldToDelete ErrorCode = 50034
+	ErrInvalidFormBody       ErrorCode = 50035
+	ErrInviteToUnknownGuild  ErrorCode = 50036
+	ErrInvalidAPIVersion     ErrorCode = 50041
+
+	ErrReactionBlocked ErrorCode = 90001
+	ErrAPIOverloaded   ErrorCode = 130000
+)
@@ -43,36 +43,25 @@ func (c *Client) Guild(guildID discord.Snowflake) (*discord.Guild, error) {
 
 // Guilds returns all guilds, automatically paginating. Be careful, as this
 // method may abuse the API by requesting thousands or millions of guilds. For
-// lower-level access, usee GuildsRange. Guilds returned have some fields
-// filled only (ID, Name, Icon, Owner, Permissions).
+// lower-level access, usee GuildsRange or GuildsPaginator. Guilds returned
+// have some fields filled only (ID, Name, Icon, Owner, Permissions). Max can
+// be 0, in which all guilds are fetched.
 func (c *Client) Guilds(max uint) ([]discord.Guild, error) {
-	var guilds []discord.Guild
-	var after discord.Snowflake = 0
-
-	const hardLimit int = 100
+	p := c.GuildsPaginator(0, After)
+	p.Max = max
 
-	for fetch := uint(hardLimit); max > 0; fetch = uint(hardLimit) {
-		if max > 0 {
-			if fetch > max {
-				fetch = max
-			}
-			max -= fetch
-		}
+	var guilds []discord.Guild
 
-		g, err := c.GuildsAfter(after, fetch)
+	for {
+		g, err := p.NextPage(c.Context())
 		if err != nil {
 			return guilds, err
 		}
-		guilds = append(guilds, g...)
-
-		if len(g) < hardLimit {
-			break
+		if len(g) == 0 {
+			return guilds, nil
 		}
-
-		after = g[hardLimit-1].ID
+		guilds = append(guilds, g...)
 	}
-
-	return guilds, nil
 }
 
 // GuildsBefore fetches guilds. Check GuildsRange.
@@ -89,7 +78,8 @@ func (c *Client) GuildsAfter(
 	return c.GuildsRange(0, after, limit)
 }
 
-// GuildsRange fetches guilds. The limit is 1-100.
+// GuildsRange fetches guilds. The limit is 1-100. Cursors that are 0 aren't
+// sent, and Discord gives the oldest guilds without one.
 func (c *Client) GuildsRange(
 	before, after discord.Snowflake, limit uint) ([]discord.Guild, error) {
 
@@ -102,8 +92,8 @@ func (c *Client) GuildsRange(
 	}
 
 	var param struct {
-		Before discord.Snowflake `schema:"before"`
-		After  discord.Snowflake `schema:"after"`
+		Before discord.Snowflake `schema:"before,omitempty"`
+		After  discord.Snowflake `schema:"after,omitempty"`
 
 		Limit uint `schema:"limit"`
 	}
@@ -15,38 +15,26 @@ func (c *Client) Member(
 
 // Members returns members until it reaches max. This function automatically
 // paginates, meaning the normal 1000 limit is handled internally. Max can be 0,
-// in which the function will try and fetch everything.
+// in which the function will try and fetch everything. Use MembersPaginator to
+// go through the members without loading all of them.
 func (c *Client) Members(
 	guildID discord.Snowflake, max uint) ([]discord.Member, error) {
 
-	var mems []discord.Member
-	var after discord.Snowflake = 0
-
-	const hardLimit int = 1000
+	p := c.MembersPaginator(guildID, 0)
+	p.Max = max
 
-	for fetch := uint(hardLimit); max > 0; fetch = uint(hardLimit) {
-		if max > 0 {
-			if fetch > max {
-				fetch = max
-			}
-			max -= fetch
-		}
+	var mems []discord.Member
 
-		m, err := c.MembersAfter(guildID, after, fetch)
+	for {
+		m, err := p.NextPage(c.Context())
 		if err != nil {
 			return mems, err
 		}
-		mems = append(mems, m...)
-
-		// There aren't any to fetch, even if this is less than max.
-		if len(mems) < hardLimit {
-			break
+		if len(m) == 0 {
+			return mems, nil
 		}
-
-		after = mems[hardLimit-1].User.ID
+		mems = append(mems, m...)
 	}
-
-	return mems, nil
 }
 
 // MembersAfter returns a list of all guild members, from 1-1000 for limits. The
@@ -191,6 +179,34 @@ func (c *Client) Bans(guildID discord.Snowflake) ([]discord.Ban, error) {
 		EndpointGuilds+guildID.String()+"/bans")
 }
 
+// BansRange returns bans before or after the user IDs, ordered by the user IDs.
+// The limit is 1-1000, defaulting to 1000.
+func (c *Client) BansRange(guildID, before, after discord.Snowflake,
+	limit uint) ([]discord.Ban, error) {
+
+	if limit == 0 || limit > 1000 {
+		limit = 1000
+	}
+
+	var param struct {
+		Before discord.Snowflake `schema:"before,omitempty"`
+		After  discord.Snowflake `schema:"after,omitempty"`
+
+		Limit uint `schema:"limit"`
+	}
+
+	param.Before = before
+	param.After = after
+	param.Limit = limit
+
+	var bans []discord.Ban
+	return bans, c.RequestJSON(
+		&bans, "GET",
+		EndpointGuilds+guildID.String()+"/bans",
+		httputil.WithSchema(c, param),
+	)
+}
+
 func (c *Client) GetBan(
 	guildID, userID discord.Snowflake) (*discord.Ban, error) {
 
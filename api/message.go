@@ -8,39 +8,29 @@ import (
 	"github.com/pkg/errors"
 )
 
-// Messages gets all mesesages, automatically paginating. Use with care, as
-// this could get as many as hundred thousands of messages, making a lot of
-// queries.
+// Messages gets the latest messages until it reaches max, automatically
+// paginating. Max can be 0, in which all messages are fetched. Use with care,
+// as this could get as many as hundred thousands of messages, making a lot of
+// queries. Use MessagesPaginator to go through them without loading all of
+// them.
 func (c *Client) Messages(
 	channelID discord.Snowflake, max uint) ([]discord.Message, error) {
 
-	var msgs []discord.Message
-	var after discord.Snowflake = 0
-
-	const hardLimit int = 100
+	p := c.MessagesPaginator(channelID, 0, Before)
+	p.Max = max
 
-	for fetch := uint(hardLimit); max > 0; fetch = uint(hardLimit) {
-		if max > 0 {
-			if fetch > max {
-				fetch = max
-			}
-			max -= fetch
-		}
+	var msgs []discord.Message
 
-		m, err := c.messagesRange(channelID, 0, after, 0, fetch)
+	for {
+		m, err := p.NextPage(c.Context())
 		if err != nil {
 			return msgs, err
 		}
-		msgs = append(msgs, m...)
-
-		if len(m) < hardLimit {
-			break
+		if len(m) == 0 {
+			return msgs, nil
 		}
-
-		after = m[hardLimit-1].Author.ID
+		msgs = append(msgs, m...)
 	}
-
-	return msgs, nil
 }
 
 // MessagesAround returns messages around the ID, with a limit of 1-100.
@@ -48,27 +38,51 @@ func (c *Client) MessagesAround(
 	channelID, around discord.Snowflake,
 	limit uint) ([]discord.Message, error) {
 
-	return c.messagesRange(channelID, 0, 0, around, limit)
+	var param messagesParam
+	if around > 0 {
+		param.Around = &around
+	}
+
+	return c.messagesRange(channelID, param, limit)
 }
 
-// MessagesBefore returns messages before the ID, with a limit of 1-100.
+// MessagesBefore returns messages before the ID, with a limit of 1-100. A 0
+// before gets the latest messages.
 func (c *Client) MessagesBefore(
 	channelID, before discord.Snowflake,
 	limit uint) ([]discord.Message, error) {
 
-	return c.messagesRange(channelID, before, 0, 0, limit)
+	var param messagesParam
+	if before > 0 {
+		param.Before = &before
+	}
+
+	return c.messagesRange(channelID, param, limit)
 }
 
-// MessagesAfter returns messages after the ID, with a limit of 1-100.
+// MessagesAfter returns messages after the ID, with a limit of 1-100. A 0 after
+// gets the oldest messages.
 func (c *Client) MessagesAfter(
 	channelID, after discord.Snowflake,
 	limit uint) ([]discord.Message, error) {
 
-	return c.messagesRange(channelID, 0, after, 0, limit)
+	// Discord gives the latest messages without a cursor, so after is sent
+	// even if it's 0.
+	return c.messagesRange(channelID, messagesParam{After: &after}, limit)
+}
+
+// messagesParam has the cursors of a messages request. Only one of them should
+// be set, and the ones that are nil aren't sent.
+type messagesParam struct {
+	Before *discord.Snowflake `schema:"before,omitempty"`
+	After  *discord.Snowflake `schema:"after,omitempty"`
+	Around *discord.Snowflake `schema:"around,omitempty"`
+
+	Limit uint `schema:"limit"`
 }
 
-func (c *Client) messagesRange(channelID, before, after,
-	around discord.Snowflake, limit uint) ([]discord.Message, error) {
+func (c *Client) messagesRange(channelID discord.Snowflake,
+	param messagesParam, limit uint) ([]discord.Message, error) {
 
 	switch {
 	case limit == 0:
@@ -77,17 +91,6 @@ func (c *Client) messagesRange(channelID, before, after,
 		limit = 100
 	}
 
-	var param struct {
-		Before discord.Snowflake `schema:"before,omitempty"`
-		After  discord.Snowflake `schema:"after,omitempty"`
-		Around discord.Snowflake `schema:"around,omitempty"`
-
-		Limit uint `schema:"limit"`
-	}
-
-	param.Before = before
-	param.After = after
-	param.Around = around
 	param.Limit = limit
 
 	var msgs []discord.Message
@@ -21,38 +21,28 @@ func (c *Client) Unreact(chID, msgID discord.Snowflake, emoji EmojiAPI) error {
 	return c.DeleteUserReaction(chID, msgID, 0, emoji)
 }
 
-// Reactions returns all reactions. It will paginate automatically.
+// Reactions returns the users who reacted with the emoji until it reaches
+// max. It will paginate automatically. Max can be 0, in which all users are
+// fetched.
 func (c *Client) Reactions(
 	channelID, messageID discord.Snowflake,
 	max uint, emoji EmojiAPI) ([]discord.User, error) {
 
-	var users []discord.User
-	var after discord.Snowflake = 0
-
-	const hardLimit int = 100
+	p := c.ReactionsPaginator(channelID, messageID, emoji, 0, After)
+	p.Max = max
 
-	for fetch := uint(hardLimit); max > 0; fetch = uint(hardLimit) {
-		if max > 0 {
-			if fetch > max {
-				fetch = max
-			}
-			max -= fetch
-		}
+	var users []discord.User
 
-		r, err := c.ReactionsRange(channelID, messageID, 0, after, fetch, emoji)
+	for {
+		u, err := p.NextPage(c.Context())
 		if err != nil {
 			return users, err
 		}
-		users = append(users, r...)
-
-		if len(r) < hardLimit {
-			break
+		if len(u) == 0 {
+			return users, nil
 		}
-
-		after = r[hardLimit-1].ID
+		users = append(users, u...)
 	}
-
-	return users, nil
 }
 
 // Refer to ReactionsRange.
@@ -0,0 +1,398 @@
+package api
+
+import (
+	"context"
+	"math"
+
+	"github.com/diamondburned/arikawa/discord"
+)
+
+// Direction is the direction a paginator goes in.
+type Direction uint8
+
+const (
+	// Before goes towards older items, starting from the newest if the cursor
+	// is 0.
+	Before Direction = iota
+	// After goes towards newer items, starting from the oldest if the cursor
+	// is 0.
+	After
+)
+
+// latestCursor is the cursor sent for a Before paginator with a 0 cursor, as
+// most endpoints give the oldest items without a cursor.
+const latestCursor = discord.Snowflake(math.MaxInt64)
+
+// paginator is the part of each paginator that doesn't depend on the item type.
+// Each page is fetched lazily, when the items of the last one are used up.
+//
+// The item-level API is similar to bufio.Scanner:
+//
+//    for p.Next(ctx) {
+//        log.Println(p.Message().Content)
+//    }
+//    if err := p.Err(); err != nil {
+//        return err
+//    }
+//
+type paginator struct {
+	// Max is the maximum number of items to get. 0 means no limit.
+	Max uint
+
+	direction Direction
+	cursor    discord.Snowflake
+	pageSize  uint
+
+	// fetch gets a page and returns the IDs of its items.
+	fetch func(ctx context.Context,
+		before, after discord.Snowflake, limit uint) ([]discord.Snowflake, error)
+
+	count uint
+	index int
+	size  int
+	done  bool
+	err   error
+}
+
+func newPaginator(dir Direction, cursor discord.Snowflake, pageSize uint) paginator {
+	return paginator{
+		direction: dir,
+		cursor:    cursor,
+		pageSize:  pageSize,
+	}
+}
+
+// nextPage fetches the next page. It returns false if there are no more
+// items or if the fetch failed.
+func (p *paginator) nextPage(ctx context.Context) bool {
+	if p.done || p.err != nil {
+		return false
+	}
+
+	limit := p.pageSize
+	if p.Max > 0 {
+		if left := p.Max - p.count; left < limit {
+			limit = left
+		}
+	}
+
+	if limit == 0 {
+		p.done = true
+		return false
+	}
+
+	// A fetch going Before always gets a before cursor, so an after of 0 means
+	// starting from the oldest.
+	var before, after discord.Snowflake
+	if p.direction == Before {
+		before = p.cursor
+		if before == 0 {
+			before = latestCursor
+		}
+	} else {
+		after = p.cursor
+	}
+
+	ids, err := p.fetch(ctx, before, after, limit)
+	if err != nil {
+		p.err = err
+		return false
+	}
+
+	// A short page means there's nothing after it.
+	if uint(len(ids)) < limit {
+		p.done = true
+	}
+
+	if len(ids) == 0 {
+		p.done = true
+		return false
+	}
+
+	// Pages aren't always in the same order, so find the edge.
+	for _, id := range ids {
+		switch {
+		case p.direction == Before && (p.cursor == 0 || id < p.cursor):
+			p.cursor = id
+		case p.direction == After && id > p.cursor:
+			p.cursor = id
+		}
+	}
+
+	p.count += uint(len(ids))
+	p.index = -1
+	p.size = len(ids)
+
+	return true
+}
+
+// Next advances to the next item, fetching the next page if needed. It
+// returns false when there are no more items, or when an error occurred, which
+// Err returns.
+func (p *paginator) Next(ctx context.Context) bool {
+	if p.index+1 < p.size {
+		p.index++
+		return true
+	}
+
+	if !p.nextPage(ctx) {
+		return false
+	}
+
+	p.index = 0
+	return true
+}
+
+// Err returns the error that stopped the paginator, if any.
+func (p *paginator) Err() error {
+	return p.err
+}
+
+// takePage marks the current page as used by NextPage.
+func (p *paginator) takePage(ctx context.Context) bool {
+	if !p.nextPage(ctx) {
+		return false
+	}
+
+	p.index = p.size - 1
+	return true
+}
+
+// MessagePaginator paginates messages in a channel.
+type MessagePaginator struct {
+	paginator
+	page []discord.Message
+}
+
+// MessagesPaginator returns a paginator of messages in the direction from the
+// cursor. A Before paginator with a 0 cursor starts from the latest message,
+// and an After one from the first message.
+func (c *Client) MessagesPaginator(
+	channelID, cursor discord.Snowflake, dir Direction) *MessagePaginator {
+
+	p := &MessagePaginator{paginator: newPaginator(dir, cursor, 100)}
+	p.fetch = func(ctx context.Context,
+		before, after discord.Snowflake, limit uint) ([]discord.Snowflake, error) {
+
+		var msgs []discord.Message
+		var err error
+
+		if before > 0 {
+			msgs, err = c.WithContext(ctx).MessagesBefore(channelID, before, limit)
+		} else {
+			msgs, err = c.WithContext(ctx).MessagesAfter(channelID, after, limit)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		p.page = msgs
+
+		ids := make([]discord.Snowflake, len(msgs))
+		for i, m := range msgs {
+			ids[i] = m.ID
+		}
+		return ids, nil
+	}
+
+	return p
+}
+
+// Message returns the current message.
+func (p *MessagePaginator) Message() discord.Message {
+	return p.page[p.index]
+}
+
+// NextPage returns the next page of messages. It returns an empty page when
+// there are no more.
+func (p *MessagePaginator) NextPage(ctx context.Context) ([]discord.Message, error) {
+	if !p.takePage(ctx) {
+		return nil, p.err
+	}
+	return p.page, nil
+}
+
+// MemberPaginator paginates members of a guild. It can only go After.
+type MemberPaginator struct {
+	paginator
+	page []discord.Member
+}
+
+// MembersPaginator returns a paginator of members, ordered by their user IDs,
+// after the cursor.
+func (c *Client) MembersPaginator(guildID, after discord.Snowflake) *MemberPaginator {
+	p := &MemberPaginator{paginator: newPaginator(After, after, 1000)}
+	p.fetch = func(ctx context.Context,
+		_, after discord.Snowflake, limit uint) ([]discord.Snowflake, error) {
+
+		mems, err := c.WithContext(ctx).MembersAfter(guildID, after, limit)
+		if err != nil {
+			return nil, err
+		}
+
+		p.page = mems
+
+		ids := make([]discord.Snowflake, len(mems))
+		for i, m := range mems {
+			ids[i] = m.User.ID
+		}
+		return ids, nil
+	}
+
+	return p
+}
+
+// Member returns the current member.
+func (p *MemberPaginator) Member() discord.Member {
+	return p.page[p.index]
+}
+
+// NextPage returns the next page of members. It returns an empty page when
+// there are no more.
+func (p *MemberPaginator) NextPage(ctx context.Context) ([]discord.Member, error) {
+	if !p.takePage(ctx) {
+		return nil, p.err
+	}
+	return p.page, nil
+}
+
+// GuildPaginator paginates the guilds of the current user.
+type GuildPaginator struct {
+	paginator
+	page []discord.Guild
+}
+
+// GuildsPaginator returns a paginator of guilds in the direction from the
+// cursor. A Before paginator with a 0 cursor starts from the newest guild, and
+// an After one from the oldest guild.
+func (c *Client) GuildsPaginator(
+	cursor discord.Snowflake, dir Direction) *GuildPaginator {
+
+	p := &GuildPaginator{paginator: newPaginator(dir, cursor, 100)}
+	p.fetch = func(ctx context.Context,
+		before, after discord.Snowflake, limit uint) ([]discord.Snowflake, error) {
+
+		guilds, err := c.WithContext(ctx).GuildsRange(before, after, limit)
+		if err != nil {
+			return nil, err
+		}
+
+		p.page = guilds
+
+		ids := make([]discord.Snowflake, len(guilds))
+		for i, g := range guilds {
+			ids[i] = g.ID
+		}
+		return ids, nil
+	}
+
+	return p
+}
+
+// Guild returns the current guild.
+func (p *GuildPaginator) Guild() discord.Guild {
+	return p.page[p.index]
+}
+
+// NextPage returns the next page of guilds. It returns an empty page when
+// there are no more.
+func (p *GuildPaginator) NextPage(ctx context.Context) ([]discord.Guild, error) {
+	if !p.takePage(ctx) {
+		return nil, p.err
+	}
+	return p.page, nil
+}
+
+// ReactionPaginator paginates the users who reacted with an emoji.
+type ReactionPaginator struct {
+	paginator
+	page []discord.User
+}
+
+// ReactionsPaginator returns a paginator of users who reacted with the emoji,
+// in the direction from the cursor.
+func (c *Client) ReactionsPaginator(
+	channelID, messageID discord.Snowflake, emoji EmojiAPI,
+	cursor discord.Snowflake, dir Direction) *ReactionPaginator {
+
+	p := &ReactionPaginator{paginator: newPaginator(dir, cursor, 100)}
+	p.fetch = func(ctx context.Context,
+		before, after discord.Snowflake, limit uint) ([]discord.Snowflake, error) {
+
+		users, err := c.WithContext(ctx).ReactionsRange(
+			channelID, messageID, before, after, limit, emoji)
+		if err != nil {
+			return nil, err
+		}
+
+		p.page = users
+
+		ids := make([]discord.Snowflake, len(users))
+		for i, u := range users {
+			ids[i] = u.ID
+		}
+		return ids, nil
+	}
+
+	return p
+}
+
+// User returns the current user.
+func (p *ReactionPaginator) User() discord.User {
+	return p.page[p.index]
+}
+
+// NextPage returns the next page of users. It returns an empty page when
+// there are no more.
+func (p *ReactionPaginator) NextPage(ctx context.Context) ([]discord.User, error) {
+	if !p.takePage(ctx) {
+		return nil, p.err
+	}
+	return p.page, nil
+}
+
+// BanPaginator paginates the bans of a guild.
+type BanPaginator struct {
+	paginator
+	page []discord.Ban
+}
+
+// BansPaginator returns a paginator of bans, ordered by the banned users' IDs,
+// in the direction from the cursor.
+func (c *Client) BansPaginator(
+	guildID, cursor discord.Snowflake, dir Direction) *BanPaginator {
+
+	p := &BanPaginator{paginator: newPaginator(dir, cursor, 1000)}
+	p.fetch = func(ctx context.Context,
+		before, after discord.Snowflake, limit uint) ([]discord.Snowflake, error) {
+
+		bans, err := c.WithContext(ctx).BansRange(guildID, before, after, limit)
+		if err != nil {
+			return nil, err
+		}
+
+		p.page = bans
+
+		ids := make([]discord.Snowflake, len(bans))
+		for i, b := range bans {
+			ids[i] = b.User.ID
+		}
+		return ids, nil
+	}
+
+	return p
+}
+
+// Ban returns the current ban.
+func (p *BanPaginator) Ban() discord.Ban {
+	return p.page[p.index]
+}
+
+// NextPage returns the next page of bans. It returns an empty page when there
+// are no more.
+func (p *BanPaginator) NextPage(ctx context.Context) ([]discord.Ban, error) {
+	if !p.takePage(ctx) {
+		return nil, p.err
+	}
+	return p.page, nil
+}
@@ -0,0 +1,215 @@
+// +build unit
+
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/diamondburned/arikawa/discord"
+	"github.com/diamondburned/arikawa/internal/httputil"
+)
+
+// fakePaginator returns a paginator over the IDs 1 to n, and the page it last
+// fetched.
+func fakePaginator(n int, dir Direction, pageSize uint) (*paginator, *[]discord.Snowflake) {
+	var page []discord.Snowflake
+
+	p := newPaginator(dir, 0, pageSize)
+	p.fetch = func(_ context.Context,
+		before, after discord.Snowflake, limit uint) ([]discord.Snowflake, error) {
+
+		page = nil
+
+		// The closest items to the cursor are returned, newest first for
+		// Before and oldest first for After. Without a cursor, the oldest
+		// items are returned, like most endpoints do.
+		if before == 0 {
+			for id := after + 1; id <= discord.Snowflake(n) && uint(len(page)) < limit; id++ {
+				page = append(page, id)
+			}
+		} else {
+			id := before - 1
+			if id > discord.Snowflake(n) {
+				id = discord.Snowflake(n)
+			}
+			for ; id > 0 && uint(len(page)) < limit; id-- {
+				page = append(page, id)
+			}
+		}
+
+		return page, nil
+	}
+
+	return &p, &page
+}
+
+func collect(p *paginator, page *[]discord.Snowflake) []discord.Snowflake {
+	var ids []discord.Snowflake
+	for p.Next(context.Background()) {
+		ids = append(ids, (*page)[p.index])
+	}
+	return ids
+}
+
+func TestPaginator(t *testing.T) {
+	var tests = []struct {
+		dir    Direction
+		max    uint
+		expect []discord.Snowflake
+	}{
+		{Before, 0, []discord.Snowflake{7, 6, 5, 4, 3, 2, 1}},
+		{Before, 4, []discord.Snowflake{7, 6, 5, 4}},
+		{After, 0, []discord.Snowflake{1, 2, 3, 4, 5, 6, 7}},
+		{After, 5, []discord.Snowflake{1, 2, 3, 4, 5}},
+	}
+
+	for _, test := range tests {
+		p, page := fakePaginator(7, test.dir, 3)
+		p.Max = test.max
+
+		ids := collect(p, page)
+
+		if err := p.Err(); err != nil {
+			t.Fatal("Unexpected error:", err)
+		}
+
+		if !reflect.DeepEqual(ids, test.expect) {
+			t.Fatalf("Unexpected IDs for direction %d max %d: %v",
+				test.dir, test.max, ids)
+		}
+	}
+}
+
+func TestPaginatorNextPage(t *testing.T) {
+	var calls int
+
+	p, page := fakePaginator(6, After, 3)
+	fetch := p.fetch
+	p.fetch = func(ctx context.Context,
+		before, after discord.Snowflake, limit uint) ([]discord.Snowflake, error) {
+
+		calls++
+		return fetch(ctx, before, after, limit)
+	}
+
+	// Pages are only fetched when needed.
+	if !p.takePage(context.Background()) || len(*page) != 3 || calls != 1 {
+		t.Fatal("Unexpected first page:", *page)
+	}
+
+	// The items of a taken page are used up.
+	if !p.Next(context.Background()) || (*page)[p.index] != 4 {
+		t.Fatal("Unexpected item after a page:", *page)
+	}
+
+	// Skip the rest of the page. A full page could be followed by an empty
+	// one, which ends the paginator.
+	p.index = p.size - 1
+	if p.Next(context.Background()) {
+		t.Fatal("Unexpected item after the end:", *page)
+	}
+
+	if calls != 3 {
+		t.Fatal("Unexpected number of fetches:", calls)
+	}
+}
+
+func TestPaginatorError(t *testing.T) {
+	fetchErr := errors.New("oops")
+
+	p := newPaginator(Before, 0, 3)
+	p.fetch = func(context.Context,
+		discord.Snowflake, discord.Snowflake, uint) ([]discord.Snowflake, error) {
+
+		return nil, fetchErr
+	}
+
+	if p.Next(context.Background()) {
+		t.Fatal("Next returned true on error")
+	}
+
+	if p.Err() != fetchErr {
+		t.Fatal("Unexpected error:", p.Err())
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+// newQueryClient returns a Client that responds with body to every request,
+// and the queries of the requests it got.
+func newQueryClient(body string) (*Client, *[]url.Values) {
+	var queries []url.Values
+
+	c := NewClient("")
+	c.Client.Transport.(*httputil.TransportWrapper).Default = roundTripFunc(
+		func(r *http.Request) (*http.Response, error) {
+			queries = append(queries, r.URL.Query())
+
+			return &http.Response{
+				StatusCode: 200,
+				Header:     http.Header{},
+				Body:       ioutil.NopCloser(bytes.NewBufferString(body)),
+				Request:    r,
+			}, nil
+		},
+	)
+
+	return c, &queries
+}
+
+func TestPaginatorZeroCursor(t *testing.T) {
+	type paginator interface {
+		Next(context.Context) bool
+	}
+
+	var tests = []struct {
+		name   string
+		new    func(c *Client, dir Direction) paginator
+		dir    Direction
+		expect url.Values
+	}{{
+		"messages before",
+		func(c *Client, dir Direction) paginator { return c.MessagesPaginator(1, 0, dir) },
+		Before, url.Values{"before": {"9223372036854775807"}, "limit": {"100"}},
+	}, {
+		"messages after",
+		func(c *Client, dir Direction) paginator { return c.MessagesPaginator(1, 0, dir) },
+		After, url.Values{"after": {"0"}, "limit": {"100"}},
+	}, {
+		"guilds before",
+		func(c *Client, dir Direction) paginator { return c.GuildsPaginator(0, dir) },
+		Before, url.Values{"before": {"9223372036854775807"}, "limit": {"100"}},
+	}, {
+		"guilds after",
+		func(c *Client, dir Direction) paginator { return c.GuildsPaginator(0, dir) },
+		After, url.Values{"limit": {"100"}},
+	}}
+
+	for _, test := range tests {
+		c, queries := newQueryClient(`[{"id":"5"}]`)
+
+		p := test.new(c, test.dir)
+		if !p.Next(context.Background()) {
+			t.Fatalf("No items for %s", test.name)
+		}
+
+		if len(*queries) != 1 {
+			t.Fatalf("Unexpected requests for %s: %v", test.name, *queries)
+		}
+
+		if q := (*queries)[0]; !reflect.DeepEqual(q, test.expect) {
+			t.Errorf("Unexpected query for %s: %v, expected %v", test.name, q, test.expect)
+		}
+	}
+}
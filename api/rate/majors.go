@@ -5,9 +5,12 @@ import (
 	"strings"
 )
 
-// TODO: webhook
-var MajorRootPaths = []string{"channels", "guilds"}
+// MajorRootPaths are the root paths followed by a major parameter. Routes with
+// different major parameters never share a bucket.
+var MajorRootPaths = []string{"channels", "guilds", "webhooks"}
 
+// ParseBucketKey returns the key of the route, which is the path without minor
+// parameters such as message IDs and emojis. Major parameters are kept.
 func ParseBucketKey(path string) string {
 	path = strings.SplitN(path, "?", 2)[0]
 
@@ -18,18 +21,9 @@ func ParseBucketKey(path string) string {
 
 	parts = parts[1:] // [0] is just "" since URL
 
-	var skip int
-
-	for _, part := range MajorRootPaths {
-		if part == parts[0] {
-			skip = 2
-			break
-		}
-	}
-
 	// We add 1, since this is the string path. The path following this would be
 	// the actual value, which we check.
-	skip++
+	skip := majorLength(parts) + 1
 
 	// we need to remove IDs from these
 	for ; skip < len(parts); skip += 2 {
@@ -56,3 +50,46 @@ func ParseBucketKey(path string) string {
 	path = strings.Join(parts, "/")
 	return "/" + path
 }
+
+// SplitMajor splits a key from ParseBucketKey into the route, which has the
+// major parameter emptied, and the major parameter. The major is empty if the
+// route doesn't have one.
+func SplitMajor(key string) (route, major string) {
+	parts := strings.Split(strings.TrimPrefix(key, "/"), "/")
+
+	n := majorLength(parts)
+	if n == 0 {
+		return key, ""
+	}
+
+	major = strings.Join(parts[1:n], "/")
+
+	for i := 1; i < n; i++ {
+		parts[i] = ""
+	}
+
+	return "/" + strings.Join(parts, "/"), major
+}
+
+// majorLength returns the number of parts the root path and its major
+// parameter take, or 0 if there's no major parameter.
+func majorLength(parts []string) int {
+	if len(parts) < 2 {
+		return 0
+	}
+
+	for _, root := range MajorRootPaths {
+		if root != parts[0] {
+			continue
+		}
+
+		// Webhooks are limited by both the ID and the token.
+		if root == "webhooks" && len(parts) > 2 {
+			return 3
+		}
+
+		return 2
+	}
+
+	return 0
+}
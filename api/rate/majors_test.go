@@ -24,6 +24,10 @@ func TestBucketKey(t *testing.T) {
 		// Actual URL:
 		{"/channels/486833611564253186/messages/540519319814275089/reactions/🥺/@me",
 			"/channels/486833611564253186/messages//reactions//@me"},
+		// Webhooks are limited by the ID and token.
+		{"/webhooks/123", "/webhooks/123"},
+		{"/webhooks/123/token", "/webhooks/123/token"},
+		{"/webhooks/123/token/slack", "/webhooks/123/token/slack"},
 	}
 
 	for _, conds := range tests {
@@ -33,3 +37,20 @@ func TestBucketKey(t *testing.T) {
 		}
 	}
 }
+
+func TestSplitMajor(t *testing.T) {
+	var tests = [][3]string{
+		{"/channels/1/messages/", "/channels//messages/", "1"},
+		{"/guilds/2", "/guilds/", "2"},
+		{"/webhooks/3/token", "/webhooks//", "3/token"},
+		{"/users/@me", "/users/@me", ""},
+	}
+
+	for _, test := range tests {
+		route, major := SplitMajor(test[0])
+		if route != test[1] || major != test[2] {
+			t.Fatalf("Unexpected route and major for %s: %s, %s",
+				test[0], route, major)
+		}
+	}
+}
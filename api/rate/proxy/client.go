@@ -0,0 +1,113 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/diamondburned/arikawa/api/rate"
+	"github.com/diamondburned/arikawa/internal/json"
+	"github.com/pkg/errors"
+)
+
+// Client is a rate.RateLimiter using a Server. It could be used as the
+// Limiter of an api.Client.
+type Client struct {
+	// URL is the base URL of the Server, such as "http://localhost:8080".
+	URL string
+
+	HTTPClient *http.Client
+}
+
+var _ rate.RateLimiter = (*Client)(nil)
+
+func NewClient(url string) *Client {
+	return &Client{
+		URL:        strings.TrimSuffix(url, "/"),
+		HTTPClient: http.DefaultClient,
+	}
+}
+
+func (c *Client) Acquire(ctx context.Context, path string) (rate.Lock, error) {
+	var resp acquireResponse
+
+	err := c.post(ctx, "/acquire", acquireRequest{Path: path}, &resp)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to acquire")
+	}
+
+	return &clientLock{c, resp.Lease}, nil
+}
+
+// clientLock is a lease on the Server. A lease that timed out is ignored by the
+// Server, so it doesn't release the bucket of whoever holds it now.
+type clientLock struct {
+	client *Client
+	lease  uint64
+}
+
+func (l *clientLock) Cancel() error {
+	err := l.client.post(context.Background(), "/cancel", releaseRequest{
+		Lease: l.lease,
+	}, nil)
+	if err != nil {
+		return errors.Wrap(err, "Failed to cancel")
+	}
+
+	return nil
+}
+
+func (l *clientLock) Release(headers http.Header) error {
+	err := l.client.post(context.Background(), "/release", releaseRequest{
+		Lease:   l.lease,
+		Headers: headers,
+	}, nil)
+	if err != nil {
+		return errors.Wrap(err, "Failed to release")
+	}
+
+	return nil
+}
+
+func (c *Client) post(ctx context.Context, path string, body, v interface{}) error {
+	b, err := (json.Default{}).Marshal(body)
+	if err != nil {
+		return errors.Wrap(err, "Failed to encode request")
+	}
+
+	req, err := http.NewRequest(http.MethodPost, c.URL+path, bytes.NewReader(b))
+	if err != nil {
+		return errors.Wrap(err, "Failed to create request")
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusGone:
+		// The lease timed out, nothing else to do.
+		return nil
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("Unexpected status %d: %s",
+			resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+
+	if v == nil {
+		return nil
+	}
+
+	if err := (json.Default{}).DecodeStream(resp.Body, v); err != nil {
+		return errors.Wrap(err, "Failed to decode response")
+	}
+
+	return nil
+}
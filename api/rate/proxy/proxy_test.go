@@ -0,0 +1,173 @@
+// +build unit
+
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/diamondburned/arikawa/api/rate"
+)
+
+func newTestServer(t *testing.T) (*Server, *httptest.Server) {
+	s := NewServer(rate.NewLimiter(""))
+	s.ErrorLog = func(err error) { t.Error("Server error:", err) }
+
+	return s, httptest.NewServer(s)
+}
+
+func mockRequest(t *testing.T, c *Client, path string, headers http.Header) {
+	lock, err := c.Acquire(context.Background(), path)
+	if err != nil {
+		t.Fatal("Failed to acquire:", err)
+	}
+
+	if err := lock.Release(headers); err != nil {
+		t.Fatal("Failed to release:", err)
+	}
+}
+
+// This test takes ~1 second to run
+func TestSharedGlobal(t *testing.T) {
+	_, srv := newTestServer(t)
+	defer srv.Close()
+
+	c1 := NewClient(srv.URL)
+	c2 := NewClient(srv.URL)
+
+	headers := http.Header{}
+	headers.Set("X-RateLimit-Global", "true")
+	headers.Set("Retry-After", "1000")
+
+	sent := time.Now()
+
+	// The first client hits the global limit, which the second one should
+	// wait for.
+	mockRequest(t, c1, "/guilds/1/channels", headers)
+	mockRequest(t, c2, "/guilds/2/channels", http.Header{})
+
+	if since := time.Since(sent); since < time.Second || since > 2*time.Second {
+		t.Error("Did not ratelimit correctly, got:", since)
+	}
+}
+
+func TestLeaseTimeout(t *testing.T) {
+	s, srv := newTestServer(t)
+	defer srv.Close()
+
+	s.LeaseTimeout = 50 * time.Millisecond
+
+	c1 := NewClient(srv.URL)
+	c2 := NewClient(srv.URL)
+
+	// The first client never releases, as if it died.
+	lock1, err := c1.Acquire(context.Background(), "/guilds/1/channels")
+	if err != nil {
+		t.Fatal("Failed to acquire:", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	lock2, err := c2.Acquire(ctx, "/guilds/1/channels")
+	if err != nil {
+		t.Fatal("Failed to acquire after the lease timed out:", err)
+	}
+
+	// Releasing the timed out lease shouldn't release the second client's.
+	if err := lock1.Release(http.Header{}); err != nil {
+		t.Fatal("Failed to release a timed out lease:", err)
+	}
+
+	s.mut.Lock()
+	n := len(s.leases)
+	s.mut.Unlock()
+
+	if n != 1 {
+		t.Fatal("Unexpected number of leases:", n)
+	}
+
+	if err := lock2.Release(http.Header{}); err != nil {
+		t.Fatal("Failed to release:", err)
+	}
+}
+
+func TestAcquireCancel(t *testing.T) {
+	_, srv := newTestServer(t)
+	defer srv.Close()
+
+	c := NewClient(srv.URL)
+
+	lock, err := c.Acquire(context.Background(), "/guilds/1/channels")
+	if err != nil {
+		t.Fatal("Failed to acquire:", err)
+	}
+
+	// The bucket is held, so this should time out.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if _, err := c.Acquire(ctx, "/guilds/1/channels"); err == nil {
+		t.Fatal("Acquired a held bucket")
+	}
+
+	if err := lock.Cancel(); err != nil {
+		t.Fatal("Failed to cancel:", err)
+	}
+
+	mockRequest(t, c, "/guilds/1/channels", http.Header{})
+}
+
+// failingWriter fails to send the response, as if the Client disconnected.
+type failingWriter struct {
+	http.ResponseWriter
+}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("connection reset")
+}
+
+func TestAcquireWriteError(t *testing.T) {
+	s := NewServer(rate.NewLimiter(""))
+
+	var logged error
+	s.ErrorLog = func(err error) { logged = err }
+
+	r := httptest.NewRequest("POST", "/acquire",
+		strings.NewReader(`{"path":"/guilds/1/channels"}`))
+	s.ServeHTTP(failingWriter{httptest.NewRecorder()}, r)
+
+	if logged == nil {
+		t.Fatal("Write error not logged")
+	}
+
+	s.mut.Lock()
+	n := len(s.leases)
+	s.mut.Unlock()
+
+	if n != 0 {
+		t.Fatal("Lease kept after the response failed:", n)
+	}
+
+	// The bucket should be free right away, not after the lease timeout.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	lock, err := s.Limiter.Acquire(ctx, "/guilds/1/channels")
+	if err != nil {
+		t.Fatal("Bucket still held:", err)
+	}
+	lock.Cancel()
+}
+
+func TestServerZero(t *testing.T) {
+	srv := httptest.NewServer(&Server{Limiter: rate.NewLimiter("")})
+	defer srv.Close()
+
+	mockRequest(t, NewClient(srv.URL), "/guilds/1/channels", http.Header{})
+}
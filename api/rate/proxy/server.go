@@ -0,0 +1,199 @@
+// Package proxy provides a rate limiter service that several API clients could
+// share over HTTP. Processes using the same token should share one Server, so
+// that they don't exceed the global and per-route limits together.
+//
+// The Server wraps a rate.Limiter. A bucket acquired by a Client is leased to
+// it until it's released or cancelled, or until the lease times out, in case
+// the Client died.
+package proxy
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/diamondburned/arikawa/api/rate"
+	"github.com/diamondburned/arikawa/internal/json"
+	"github.com/pkg/errors"
+)
+
+// DefaultLeaseTimeout is the default time a Client could hold a bucket for.
+var DefaultLeaseTimeout = time.Minute
+
+type acquireRequest struct {
+	Path string `json:"path"`
+}
+
+type acquireResponse struct {
+	Lease uint64 `json:"lease"`
+}
+
+type releaseRequest struct {
+	Lease   uint64      `json:"lease"`
+	Headers http.Header `json:"headers,omitempty"`
+}
+
+type lease struct {
+	lock  rate.Lock
+	timer *time.Timer
+}
+
+// Server is an http.Handler serving the Limiter to Clients. A Server made
+// without NewServer works too, as long as Limiter is set.
+type Server struct {
+	Limiter *rate.Limiter
+
+	// LeaseTimeout is how long a Client could hold a bucket before it's
+	// cancelled on its own. 0 uses DefaultLeaseTimeout.
+	LeaseTimeout time.Duration
+
+	// ErrorLog logs errors that couldn't be sent back to the Client.
+	ErrorLog func(error) // optional
+
+	mut    sync.Mutex
+	leases map[uint64]*lease
+	serial uint64
+}
+
+func NewServer(limiter *rate.Limiter) *Server {
+	return &Server{
+		Limiter:      limiter,
+		LeaseTimeout: DefaultLeaseTimeout,
+		ErrorLog:     func(error) {},
+		leases:       map[uint64]*lease{},
+	}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	switch r.URL.Path {
+	case "/acquire":
+		s.acquire(w, r)
+	case "/release":
+		s.release(w, r, true)
+	case "/cancel":
+		s.release(w, r, false)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) acquire(w http.ResponseWriter, r *http.Request) {
+	var req acquireRequest
+	if err := (json.Default{}).DecodeStream(r.Body, &req); err != nil {
+		http.Error(w, "Invalid body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// This blocks until the bucket is free, or the Client gives up.
+	lock, err := s.Limiter.Acquire(r.Context(), req.Path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	// The Client could've given up right as the bucket was acquired, in which
+	// case nobody would release it.
+	if err := r.Context().Err(); err != nil {
+		lock.Cancel()
+		return
+	}
+
+	var timeout = s.LeaseTimeout
+	if timeout <= 0 {
+		timeout = DefaultLeaseTimeout
+	}
+
+	s.mut.Lock()
+	s.serial++
+	id := s.serial
+
+	if s.leases == nil {
+		s.leases = map[uint64]*lease{}
+	}
+
+	s.leases[id] = &lease{
+		lock: lock,
+		timer: time.AfterFunc(timeout, func() {
+			s.cancelLease(id)
+		}),
+	}
+	s.mut.Unlock()
+
+	b, err := (json.Default{}).Marshal(acquireResponse{Lease: id})
+	if err != nil {
+		s.cancelLease(id)
+		s.logError(errors.Wrap(err, "Failed to encode lease"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if _, err := w.Write(b); err != nil {
+		s.cancelLease(id)
+		s.logError(errors.Wrap(err, "Failed to send lease"))
+	}
+}
+
+func (s *Server) release(w http.ResponseWriter, r *http.Request, release bool) {
+	var req releaseRequest
+	if err := (json.Default{}).DecodeStream(r.Body, &req); err != nil {
+		http.Error(w, "Invalid body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	l := s.takeLease(req.Lease)
+	if l == nil {
+		// The lease timed out, so the bucket is already given to someone else.
+		w.WriteHeader(http.StatusGone)
+		return
+	}
+
+	var err error
+	if release {
+		err = l.lock.Release(req.Headers)
+	} else {
+		err = l.lock.Cancel()
+	}
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// cancelLease cancels a lease that the Client never got.
+func (s *Server) cancelLease(id uint64) {
+	if l := s.takeLease(id); l != nil {
+		l.lock.Cancel()
+	}
+}
+
+func (s *Server) logError(err error) {
+	if s.ErrorLog != nil {
+		s.ErrorLog(err)
+	}
+}
+
+// takeLease removes the lease and stops its timer. It returns nil if there's
+// no such lease.
+func (s *Server) takeLease(id uint64) *lease {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	l, ok := s.leases[id]
+	if !ok {
+		return nil
+	}
+
+	delete(s.leases, id)
+	l.timer.Stop()
+
+	return l
+}
@@ -21,6 +21,25 @@ const ExtraDelay = 250 * time.Millisecond
 // This makes me suicidal.
 // https://github.com/bwmarrin/discordgo/blob/master/ratelimit.go
 
+// RateLimiter limits the requests to the REST API. Acquire is called before
+// each request, then the returned Lock is either released with the response
+// headers or cancelled if the request failed.
+//
+// Limiter is the in-memory implementation. Processes sharing a token should
+// share a limiter instead, such as with the proxy package.
+type RateLimiter interface {
+	Acquire(ctx context.Context, path string) (Lock, error)
+}
+
+// Lock is what a request holds between Acquire and the response. Either
+// Release or Cancel is called on it, once.
+type Lock interface {
+	Cancel() error
+	Release(headers http.Header) error
+}
+
+var _ RateLimiter = (*Limiter)(nil)
+
 type Limiter struct {
 	// Only 1 per bucket
 	CustomLimits []*CustomRateLimit
@@ -33,8 +52,14 @@ type Limiter struct {
 
 	Prefix string
 
+	// BucketTTL is how long a bucket is kept after it's last used. Buckets
+	// unused for longer are evicted.
+	BucketTTL time.Duration
+
 	global     *int64 // atomic guarded, unixnano
+	lastEvict  int64  // atomic guarded, unixnano
 	buckets    sync.Map
+	hashes     sync.Map // route -> X-RateLimit-Bucket
 	globalRate time.Duration
 }
 
@@ -52,6 +77,17 @@ type bucket struct {
 
 	reset     time.Time
 	lastReset time.Time // only for custom
+
+	lastUsed int64 // atomic guarded, unixnano
+}
+
+// bucketLock is the bucket locked by Acquire. The bucket is kept instead of
+// looking the path up again, as the path could be moved to another bucket
+// while the request is in flight.
+type bucketLock struct {
+	limiter *Limiter
+	path    string
+	bucket  *bucket
 }
 
 func returnTrue(string) bool {
@@ -62,6 +98,7 @@ func returnTrue(string) bool {
 func NewLimiter(prefix string) *Limiter {
 	return &Limiter{
 		Prefix:       prefix,
+		BucketTTL:    10 * time.Minute,
 		global:       new(int64),
 		buckets:      sync.Map{},
 		CustomLimits: []*CustomRateLimit{},
@@ -71,45 +108,148 @@ func NewLimiter(prefix string) *Limiter {
 	}
 }
 
+func (l *Limiter) customLimit(key string) *CustomRateLimit {
+	for _, limit := range l.CustomLimits {
+		if strings.Contains(key, limit.Contains) {
+			return limit
+		}
+	}
+	return nil
+}
+
+// bucketKey returns the key of the bucket for the path. Routes with a known
+// bucket hash share the bucket of the hash and their major parameter.
+func (l *Limiter) bucketKey(path string) (key string, custom *CustomRateLimit) {
+	key = ParseBucketKey(strings.TrimPrefix(path, l.Prefix))
+
+	// Custom limits override what Discord says.
+	if custom = l.customLimit(key); custom != nil {
+		return key, custom
+	}
+
+	route, major := SplitMajor(key)
+
+	if hash, ok := l.hashes.Load(route); ok {
+		return hash.(string) + ":" + major, nil
+	}
+
+	return key, nil
+}
+
 func (l *Limiter) getBucket(path string, store bool) *bucket {
-	path = ParseBucketKey(strings.TrimPrefix(path, l.Prefix))
+	key, custom := l.bucketKey(path)
 
-	bc, ok := l.buckets.Load(path)
+	bc, ok := l.buckets.Load(key)
 	if !ok && !store {
 		return nil
 	}
 
 	if !ok {
-		bc := &bucket{
+		bc, _ = l.buckets.LoadOrStore(key, &bucket{
 			remaining: 1,
+			custom:    custom,
+			lastUsed:  time.Now().UnixNano(),
+		})
+	}
+
+	return bc.(*bucket)
+}
+
+// learnHash maps the route of the path to the bucket hash, so that every route
+// with the same hash shares the bucket. The new bucket starts with the state of
+// the old one.
+func (l *Limiter) learnHash(path, hash string, old *bucket) {
+	key := ParseBucketKey(strings.TrimPrefix(path, l.Prefix))
+	if l.customLimit(key) != nil {
+		return
+	}
+
+	route, major := SplitMajor(key)
+
+	if known, ok := l.hashes.Load(route); ok && known.(string) == hash {
+		return
+	}
+
+	l.hashes.Store(route, hash)
+
+	l.buckets.LoadOrStore(hash+":"+major, &bucket{
+		remaining: old.remaining,
+		reset:     old.reset,
+		lastUsed:  time.Now().UnixNano(),
+	})
+}
+
+// evict removes the buckets that are unused for longer than BucketTTL. It only
+// runs once every BucketTTL.
+func (l *Limiter) evict() {
+	if l.BucketTTL <= 0 {
+		return
+	}
+
+	now := time.Now()
+
+	last := atomic.LoadInt64(&l.lastEvict)
+	if now.Sub(time.Unix(0, last)) < l.BucketTTL {
+		return
+	}
+	if !atomic.CompareAndSwapInt64(&l.lastEvict, last, now.UnixNano()) {
+		return
+	}
+
+	expiry := now.Add(-l.BucketTTL).UnixNano()
+
+	l.buckets.Range(func(k, v interface{}) bool {
+		b := v.(*bucket)
+
+		if atomic.LoadInt64(&b.lastUsed) > expiry {
+			return true
 		}
 
-		for _, limit := range l.CustomLimits {
-			if strings.Contains(path, limit.Contains) {
-				bc.custom = limit
-				break
-			}
+		// Skip buckets being used. Acquire checks if the bucket is still in the
+		// map after locking, so it's safe to delete it while locked.
+		if !b.lock.TryLock() {
+			return true
 		}
 
-		l.buckets.Store(path, bc)
-		return bc
-	}
+		// Keep buckets that are still limited.
+		if !b.reset.After(now) {
+			l.buckets.Delete(k)
+		}
 
-	return bc.(*bucket)
+		b.lock.Unlock()
+
+		return true
+	})
 }
 
-func (l *Limiter) Acquire(ctx context.Context, path string) error {
+func (l *Limiter) Acquire(ctx context.Context, path string) (Lock, error) {
+	l.evict()
+
 	b := l.getBucket(path, true)
 
 	if !l.OnAcquire(path) {
-		return nil
+		return &bucketLock{l, path, b}, nil
 	}
 
-	// Acquire lock with a timeout
-	if err := b.lock.CLock(ctx); err != nil {
-		return err
+	for {
+		// Acquire lock with a timeout
+		if err := b.lock.CLock(ctx); err != nil {
+			return nil, err
+		}
+
+		// The route could've been moved to another bucket or evicted while
+		// waiting, in which case the current bucket has to be locked instead.
+		current := l.getBucket(path, true)
+		if current == b {
+			break
+		}
+
+		b.lock.Unlock()
+		b = current
 	}
 
+	atomic.StoreInt64(&b.lastUsed, time.Now().UnixNano())
+
 	// Time to sleep
 	var sleep time.Duration
 
@@ -130,7 +270,7 @@ func (l *Limiter) Acquire(ctx context.Context, path string) error {
 		select {
 		case <-ctx.Done():
 			b.lock.Unlock()
-			return ctx.Err()
+			return nil, ctx.Err()
 		case <-time.After(sleep):
 		}
 	}
@@ -139,15 +279,13 @@ func (l *Limiter) Acquire(ctx context.Context, path string) error {
 		b.remaining--
 	}
 
-	return nil
+	return &bucketLock{l, path, b}, nil
 }
 
-func (l *Limiter) Cancel(path string) error {
-	b := l.getBucket(path, false)
-	if b == nil {
-		return nil
-	}
-	if !l.OnCancel(path) {
+func (bl *bucketLock) Cancel() error {
+	l, b := bl.limiter, bl.bucket
+
+	if !l.OnCancel(bl.path) {
 		return nil
 	}
 
@@ -158,13 +296,10 @@ func (l *Limiter) Cancel(path string) error {
 	return nil
 }
 
-// Release releases the URL from the locks. This doesn't need a context for
-// timing out, it doesn't block that much.
-func (l *Limiter) Release(path string, headers http.Header) error {
-	b := l.getBucket(path, false)
-	if b == nil {
-		return nil
-	}
+// Release releases the bucket with the new limits in the headers. This doesn't
+// need a context for timing out, it doesn't block that much.
+func (bl *bucketLock) Release(headers http.Header) error {
+	l, b, path := bl.limiter, bl.bucket, bl.path
 
 	defer func() {
 		if l.OnRelease(path) {
@@ -191,7 +326,11 @@ func (l *Limiter) Release(path string, headers http.Header) error {
 		// seconds
 		remaining  = headers.Get("X-RateLimit-Remaining")
 		reset      = headers.Get("X-RateLimit-Reset")
+		resetAfter = headers.Get("X-RateLimit-Reset-After")
 		retryAfter = headers.Get("Retry-After")
+
+		// shared between routes
+		hash = headers.Get("X-RateLimit-Bucket")
 	)
 
 	switch {
@@ -206,9 +345,19 @@ func (l *Limiter) Release(path string, headers http.Header) error {
 		if global != "" { // probably true
 			atomic.StoreInt64(l.global, at.UnixNano())
 		} else {
+			// The bucket is out until then, even if Remaining is missing.
+			b.remaining = 0
 			b.reset = at
 		}
 
+	case resetAfter != "":
+		secs, err := strconv.ParseFloat(resetAfter, 64)
+		if err != nil {
+			return errors.Wrap(err, "Invalid reset-after "+resetAfter)
+		}
+
+		b.reset = time.Now().Add(time.Duration(secs * float64(time.Second)))
+
 	case reset != "":
 		unix, err := strconv.ParseFloat(reset, 64)
 		if err != nil {
@@ -228,5 +377,9 @@ func (l *Limiter) Release(path string, headers http.Header) error {
 		b.remaining = u
 	}
 
+	if hash != "" {
+		l.learnHash(path, hash, b)
+	}
+
 	return nil
 }
@@ -13,18 +13,19 @@ import (
 // https://github.com/bwmarrin/discordgo/blob/master/ratelimit_test.go
 
 func mockRequest(t *testing.T, l *Limiter, path string, headers http.Header) {
-	if err := l.Acquire(context.Background(), path); err != nil {
+	lock, err := l.Acquire(context.Background(), path)
+	if err != nil {
 		t.Fatal("Failed to acquire lock:", err)
 	}
 
-	if err := l.Release(path, headers); err != nil {
+	if err := lock.Release(headers); err != nil {
 		t.Fatal("Failed to release lock:", err)
 	}
 }
 
 // This test takes ~2 seconds to run
 func TestRatelimitReset(t *testing.T) {
-	l := NewLimiter()
+	l := NewLimiter("")
 
 	headers := http.Header{}
 	headers.Set("X-RateLimit-Remaining", "0")
@@ -54,7 +55,7 @@ func TestRatelimitReset(t *testing.T) {
 
 // This test takes ~1 seconds to run
 func TestRatelimitGlobal(t *testing.T) {
-	l := NewLimiter()
+	l := NewLimiter("")
 
 	headers := http.Header{}
 	headers.Set("X-RateLimit-Global", "1.002")
@@ -76,3 +77,119 @@ func TestRatelimitGlobal(t *testing.T) {
 		t.Error("Did not ratelimit correctly, got:", time.Since(sent))
 	}
 }
+
+// This test takes ~1 second to run
+func TestRatelimitRetryAfter(t *testing.T) {
+	l := NewLimiter("")
+
+	// A 429 that's not global, without X-RateLimit-Remaining.
+	headers := http.Header{}
+	headers.Set("Retry-After", "1000")
+
+	sent := time.Now()
+
+	mockRequest(t, l, "/channels/1/messages", headers)
+	mockRequest(t, l, "/channels/1/messages", http.Header{})
+
+	if time.Since(sent) < time.Second {
+		t.Error("Did not wait for Retry-After, got:", time.Since(sent))
+	}
+}
+
+// This test takes ~1 second to run
+func TestRatelimitBucketHash(t *testing.T) {
+	l := NewLimiter("")
+
+	headers := http.Header{}
+	headers.Set("X-RateLimit-Bucket", "abcd")
+	headers.Set("X-RateLimit-Remaining", "1")
+	headers.Set("X-RateLimit-Reset-After", "1")
+
+	// Learn that both routes share the same bucket.
+	mockRequest(t, l, "/channels/1/messages", headers)
+	mockRequest(t, l, "/channels/1/pins", headers)
+
+	headers.Set("X-RateLimit-Remaining", "0")
+
+	sent := time.Now()
+
+	// The second route should wait for the first one, which used up the
+	// shared bucket.
+	mockRequest(t, l, "/channels/1/messages", headers)
+	mockRequest(t, l, "/channels/1/pins", headers)
+
+	if time.Since(sent) < time.Second {
+		t.Error("Did not ratelimit the shared bucket, got:", time.Since(sent))
+	}
+
+	// A different major parameter shouldn't be limited.
+	sent = time.Now()
+	mockRequest(t, l, "/channels/2/messages", http.Header{})
+
+	if time.Since(sent) >= time.Second {
+		t.Error("Ratelimited a different major, got:", time.Since(sent))
+	}
+}
+
+func TestRatelimitHashLearnedInFlight(t *testing.T) {
+	l := NewLimiter("")
+
+	lock1, err := l.Acquire(context.Background(), "/channels/1/messages")
+	if err != nil {
+		t.Fatal("Failed to acquire lock:", err)
+	}
+
+	// Another major learns the hash while the first request is in flight,
+	// which moves the first path to a new bucket.
+	headers := http.Header{}
+	headers.Set("X-RateLimit-Bucket", "abcd")
+	mockRequest(t, l, "/channels/2/messages", headers)
+
+	if err := lock1.Release(http.Header{}); err != nil {
+		t.Fatal("Failed to release lock:", err)
+	}
+
+	// The bucket locked by the first request should be the one released.
+	old := lock1.(*bucketLock).bucket
+	if !old.lock.TryLock() {
+		t.Fatal("The bucket acquired before learning the hash is still locked")
+	}
+	old.lock.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	lock, err := l.Acquire(ctx, "/channels/1/messages")
+	if err != nil {
+		t.Fatal("Failed to acquire lock after learning the hash:", err)
+	}
+
+	if lock.(*bucketLock).bucket == old {
+		t.Fatal("The path wasn't moved to the bucket of the hash")
+	}
+
+	if err := lock.Release(http.Header{}); err != nil {
+		t.Fatal("Failed to release lock:", err)
+	}
+}
+
+func TestRatelimitEvict(t *testing.T) {
+	l := NewLimiter("")
+	l.BucketTTL = time.Millisecond
+
+	mockRequest(t, l, "/guilds/1/channels", http.Header{})
+	time.Sleep(5 * time.Millisecond)
+
+	// This evicts the old bucket before making its own.
+	mockRequest(t, l, "/guilds/2/channels", http.Header{})
+
+	var keys []interface{}
+	l.buckets.Range(func(k, _ interface{}) bool {
+		keys = append(keys, k)
+		return true
+	})
+
+	if len(keys) != 1 || keys[0] != "/guilds/2/channels" {
+		t.Fatal("Unexpected buckets after eviction:", keys)
+	}
+}
@@ -124,7 +124,7 @@ func Start(token string, cmd interface{},
 
 // Wait is a convenient function that blocks until a SIGINT is sent.
 func Wait() {
-	sigs := make(chan os.Signal)
+	sigs := make(chan os.Signal, 1)
 	signal.Notify(sigs, os.Interrupt)
 	<-sigs
 }
@@ -0,0 +1,307 @@
+package discord
+
+import (
+	"encoding/json"
+
+	"github.com/pkg/errors"
+)
+
+// https://discordapp.com/developers/docs/resources/audit-log#audit-log-object
+type AuditLog struct {
+	// List of webhooks found in the audit log
+	Webhooks []Webhook `json:"webhooks"`
+	// List of users found in the audit log
+	Users []User `json:"users"`
+	// List of audit log entries
+	Entries []AuditLogEntry `json:"audit_log_entries"`
+	// List of partial integration objects, only ID, Name, Type, and Account
+	Integrations []Integration `json:"integrations"`
+}
+
+// User returns the user with the given ID from the list of users in the audit
+// log, or nil if it's not found.
+func (l AuditLog) User(id Snowflake) *User {
+	for i, u := range l.Users {
+		if u.ID == id {
+			return &l.Users[i]
+		}
+	}
+	return nil
+}
+
+// AuditLogEntry is a single entry in the audit log.
+type AuditLogEntry struct {
+	ID       Snowflake `json:"id"`
+	UserID   Snowflake `json:"user_id"`
+	TargetID string    `json:"target_id,omitempty"`
+
+	Changes []AuditLogChange `json:"changes,omitempty"`
+
+	ActionType AuditLogEvent  `json:"action_type"`
+	Options    AuditEntryInfo `json:"options,omitempty"`
+	Reason     string         `json:"reason,omitempty"`
+}
+
+// Target returns the TargetID parsed as a Snowflake. It returns 0 if the
+// target is not a snowflake.
+func (e AuditLogEntry) Target() Snowflake {
+	s, err := ParseSnowflake(e.TargetID)
+	if err != nil {
+		return 0
+	}
+	return s
+}
+
+// AuditLogEvent is the type of action that an audit log entry records.
+//
+// https://discordapp.com/developers/docs/resources/audit-log#audit-log-entry-object-audit-log-events
+type AuditLogEvent uint8
+
+const (
+	GuildUpdate AuditLogEvent = 1
+)
+
+const (
+	ChannelCreate AuditLogEvent = iota + 10
+	ChannelUpdate
+	ChannelDelete
+	ChannelOverwriteCreate
+	ChannelOverwriteUpdate
+	ChannelOverwriteDelete
+)
+
+const (
+	MemberKick AuditLogEvent = iota + 20
+	MemberPrune
+	MemberBanAdd
+	MemberBanRemove
+	MemberUpdate
+	MemberRoleUpdate
+	MemberMove
+	MemberDisconnect
+	BotAdd
+)
+
+const (
+	RoleCreate AuditLogEvent = iota + 30
+	RoleUpdate
+	RoleDelete
+)
+
+const (
+	InviteCreate AuditLogEvent = iota + 40
+	InviteUpdate
+	InviteDelete
+)
+
+const (
+	WebhookCreate AuditLogEvent = iota + 50
+	WebhookUpdate
+	WebhookDelete
+)
+
+const (
+	EmojiCreate AuditLogEvent = iota + 60
+	EmojiUpdate
+	EmojiDelete
+)
+
+const (
+	MessageDelete AuditLogEvent = iota + 72
+	MessageBulkDelete
+	MessagePin
+	MessageUnpin
+)
+
+const (
+	IntegrationCreate AuditLogEvent = iota + 80
+	IntegrationUpdate
+	IntegrationDelete
+)
+
+// AuditEntryInfo contains additional info for certain audit log events.
+type AuditEntryInfo struct {
+	// MEMBER_PRUNE
+	DeleteMemberDays string `json:"delete_member_days,omitempty"`
+	// MEMBER_PRUNE
+	MembersRemoved string `json:"members_removed,omitempty"`
+	// MEMBER_MOVE, MESSAGE_PIN, MESSAGE_UNPIN, MESSAGE_DELETE
+	ChannelID Snowflake `json:"channel_id,omitempty"`
+	// MESSAGE_PIN, MESSAGE_UNPIN
+	MessageID Snowflake `json:"message_id,omitempty"`
+	// MESSAGE_DELETE, MESSAGE_BULK_DELETE, MEMBER_DISCONNECT, MEMBER_MOVE
+	Count string `json:"count,omitempty"`
+	// CHANNEL_OVERWRITE_CREATE, CHANNEL_OVERWRITE_UPDATE,
+	// CHANNEL_OVERWRITE_DELETE
+	ID Snowflake `json:"id,omitempty"`
+	// CHANNEL_OVERWRITE_*, "member" or "role"
+	Type OverwriteType `json:"type,omitempty"`
+	// CHANNEL_OVERWRITE_*, only when Type is "role"
+	RoleName string `json:"role_name,omitempty"`
+}
+
+// AuditLogChange is a single key change. OldValue and NewValue are kept raw,
+// as their types depend on the key. Refer to UnmarshalValues and Values.
+type AuditLogChange struct {
+	Key      AuditLogChangeKey `json:"key"`
+	NewValue json.RawMessage   `json:"new_value,omitempty"`
+	OldValue json.RawMessage   `json:"old_value,omitempty"`
+}
+
+// UnmarshalValues unmarshals the old and new values into the given pointers.
+// Either could be nil, in which case it is skipped. Values that are missing
+// from the change are left untouched.
+func (a AuditLogChange) UnmarshalValues(old, new interface{}) error {
+	if old != nil && len(a.OldValue) > 0 {
+		if err := json.Unmarshal(a.OldValue, old); err != nil {
+			return errors.Wrap(err, "Failed to unmarshal old value")
+		}
+	}
+	if new != nil && len(a.NewValue) > 0 {
+		if err := json.Unmarshal(a.NewValue, new); err != nil {
+			return errors.Wrap(err, "Failed to unmarshal new value")
+		}
+	}
+	return nil
+}
+
+// Values decodes the old and new values into the type that the key expects,
+// according to AuditLogChangeKey.New. The returned values are pointers to the
+// decoded type, or nil if the value is absent. Unknown keys are decoded into
+// interface{}.
+func (a AuditLogChange) Values() (old, new interface{}, err error) {
+	if len(a.OldValue) > 0 {
+		old = a.Key.New()
+		if err := json.Unmarshal(a.OldValue, old); err != nil {
+			return nil, nil, errors.Wrap(err, "Failed to decode old value")
+		}
+	}
+	if len(a.NewValue) > 0 {
+		new = a.Key.New()
+		if err := json.Unmarshal(a.NewValue, new); err != nil {
+			return nil, nil, errors.Wrap(err, "Failed to decode new value")
+		}
+	}
+	return
+}
+
+// https://discordapp.com/developers/docs/resources/audit-log#audit-log-change-object-audit-log-change-key
+type AuditLogChangeKey string
+
+const (
+	// Guild
+	AuditGuildName            AuditLogChangeKey = "name"
+	AuditGuildIconHash        AuditLogChangeKey = "icon_hash"
+	AuditGuildSplashHash      AuditLogChangeKey = "splash_hash"
+	AuditGuildOwnerID         AuditLogChangeKey = "owner_id"
+	AuditGuildRegion          AuditLogChangeKey = "region"
+	AuditGuildAFKChannelID    AuditLogChangeKey = "afk_channel_id"
+	AuditGuildAFKTimeout      AuditLogChangeKey = "afk_timeout"
+	AuditGuildMFA             AuditLogChangeKey = "mfa_level"
+	AuditGuildVerification    AuditLogChangeKey = "verification_level"
+	AuditGuildExplicitFilter  AuditLogChangeKey = "explicit_content_filter"
+	AuditGuildNotification    AuditLogChangeKey = "default_message_notifications"
+	AuditGuildVanityURLCode   AuditLogChangeKey = "vanity_url_code"
+	AuditGuildRoleAdd         AuditLogChangeKey = "$add"
+	AuditGuildRoleRemove      AuditLogChangeKey = "$remove"
+	AuditGuildPruneDeleteDays AuditLogChangeKey = "prune_delete_days"
+	AuditGuildWidgetEnabled   AuditLogChangeKey = "widget_enabled"
+	AuditGuildWidgetChannelID AuditLogChangeKey = "widget_channel_id"
+	AuditGuildSystemChannelID AuditLogChangeKey = "system_channel_id"
+
+	// Channel
+	AuditChannelPosition         AuditLogChangeKey = "position"
+	AuditChannelTopic            AuditLogChangeKey = "topic"
+	AuditChannelBitrate          AuditLogChangeKey = "bitrate"
+	AuditChannelPermissions      AuditLogChangeKey = "permission_overwrites"
+	AuditChannelNSFW             AuditLogChangeKey = "nsfw"
+	AuditChannelApplicationID    AuditLogChangeKey = "application_id"
+	AuditChannelRateLimitPerUser AuditLogChangeKey = "rate_limit_per_user"
+
+	// Role
+	AuditRolePermissions AuditLogChangeKey = "permissions"
+	AuditRoleColor       AuditLogChangeKey = "color"
+	AuditRoleHoist       AuditLogChangeKey = "hoist"
+	AuditRoleMentionable AuditLogChangeKey = "mentionable"
+	AuditRoleAllow       AuditLogChangeKey = "allow"
+	AuditRoleDeny        AuditLogChangeKey = "deny"
+
+	// Invite
+	AuditInviteCode      AuditLogChangeKey = "code"
+	AuditInviteChannelID AuditLogChangeKey = "channel_id"
+	AuditInviteInviterID AuditLogChangeKey = "inviter_id"
+	AuditInviteMaxUses   AuditLogChangeKey = "max_uses"
+	AuditInviteUses      AuditLogChangeKey = "uses"
+	AuditInviteMaxAge    AuditLogChangeKey = "max_age"
+	AuditInviteTemporary AuditLogChangeKey = "temporary"
+
+	// User
+	AuditUserDeaf       AuditLogChangeKey = "deaf"
+	AuditUserMute       AuditLogChangeKey = "mute"
+	AuditUserNick       AuditLogChangeKey = "nick"
+	AuditUserAvatarHash AuditLogChangeKey = "avatar_hash"
+
+	// Any
+	AuditAnyID   AuditLogChangeKey = "id"
+	AuditAnyType AuditLogChangeKey = "type"
+
+	// Integration
+	AuditIntegrationEnableEmotes   AuditLogChangeKey = "enable_emoticons"
+	AuditIntegrationExpireBehavior AuditLogChangeKey = "expire_behavior"
+	AuditIntegrationExpireGrace    AuditLogChangeKey = "expire_grace_period"
+)
+
+// New returns a pointer to a new zero value of the type that the key's values
+// decode into.
+func (k AuditLogChangeKey) New() interface{} {
+	switch k {
+	case AuditGuildName, AuditGuildIconHash, AuditGuildSplashHash,
+		AuditGuildRegion, AuditGuildVanityURLCode, AuditChannelTopic,
+		AuditInviteCode, AuditUserNick, AuditUserAvatarHash:
+		return new(string)
+
+	case AuditGuildOwnerID, AuditGuildAFKChannelID, AuditGuildWidgetChannelID,
+		AuditGuildSystemChannelID, AuditChannelApplicationID,
+		AuditInviteChannelID, AuditInviteInviterID, AuditAnyID:
+		return new(Snowflake)
+
+	case AuditGuildAFKTimeout, AuditChannelRateLimitPerUser, AuditInviteMaxAge:
+		return new(Seconds)
+
+	case AuditGuildMFA:
+		return new(MFALevel)
+	case AuditGuildVerification:
+		return new(Verification)
+	case AuditGuildExplicitFilter:
+		return new(ExplicitFilter)
+	case AuditGuildNotification:
+		return new(Notification)
+
+	case AuditGuildRoleAdd, AuditGuildRoleRemove:
+		return new([]Role) // partial, only ID and Name
+
+	case AuditGuildPruneDeleteDays, AuditChannelPosition, AuditChannelBitrate,
+		AuditInviteMaxUses, AuditInviteUses, AuditIntegrationExpireBehavior,
+		AuditIntegrationExpireGrace:
+		return new(int)
+
+	case AuditGuildWidgetEnabled, AuditChannelNSFW, AuditRoleHoist,
+		AuditRoleMentionable, AuditInviteTemporary, AuditUserDeaf,
+		AuditUserMute, AuditIntegrationEnableEmotes:
+		return new(bool)
+
+	case AuditChannelPermissions:
+		return new([]Overwrite)
+
+	case AuditRolePermissions, AuditRoleAllow, AuditRoleDeny:
+		return new(Permissions)
+
+	case AuditRoleColor:
+		return new(Color)
+
+	default:
+		// "type" could be either an int (ChannelType) or a string (integration
+		// type), so it's decoded as anything.
+		return new(interface{})
+	}
+}
@@ -0,0 +1,81 @@
+// +build unit
+
+package discord
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+const auditLogJSON = `{
+	"webhooks": [],
+	"users": [{"id": "170595917233602562", "username": "ari"}],
+	"audit_log_entries": [{
+		"id": "676447716404445184",
+		"user_id": "170595917233602562",
+		"target_id": "521436374722969611",
+		"action_type": 24,
+		"reason": "nick",
+		"changes": [
+			{"key": "nick", "old_value": "old", "new_value": "new"},
+			{"key": "$add", "new_value": [{"id": "1234", "name": "role"}]},
+			{"key": "color", "old_value": 16711680},
+			{"key": "type", "new_value": 2}
+		]
+	}]
+}`
+
+func TestAuditLogDecode(t *testing.T) {
+	var log AuditLog
+	if err := json.Unmarshal([]byte(auditLogJSON), &log); err != nil {
+		t.Fatal("Failed to decode audit log:", err)
+	}
+
+	if len(log.Entries) != 1 {
+		t.Fatal("Unexpected entries:", log.Entries)
+	}
+
+	entry := log.Entries[0]
+
+	if entry.ActionType != MemberUpdate {
+		t.Fatal("Unexpected action type:", entry.ActionType)
+	}
+	if entry.Target() != 521436374722969611 {
+		t.Fatal("Unexpected target:", entry.Target())
+	}
+	if u := log.User(entry.UserID); u == nil || u.Username != "ari" {
+		t.Fatal("User not found:", u)
+	}
+
+	var old, new string
+	if err := entry.Changes[0].UnmarshalValues(&old, &new); err != nil {
+		t.Fatal("Failed to unmarshal nick:", err)
+	}
+	if old != "old" || new != "new" {
+		t.Fatal("Unexpected nick change:", old, new)
+	}
+
+	_, added, err := entry.Changes[1].Values()
+	if err != nil {
+		t.Fatal("Failed to decode $add:", err)
+	}
+	if roles := *added.(*[]Role); len(roles) != 1 || roles[0].ID != 1234 {
+		t.Fatal("Unexpected roles added:", roles)
+	}
+
+	oldColor, newColor, err := entry.Changes[2].Values()
+	if err != nil {
+		t.Fatal("Failed to decode color:", err)
+	}
+	if *oldColor.(*Color) != 0xFF0000 || newColor != nil {
+		t.Fatal("Unexpected color change:", oldColor, newColor)
+	}
+
+	_, typ, err := entry.Changes[3].Values()
+	if err != nil {
+		t.Fatal("Failed to decode type:", err)
+	}
+	if *typ.(*interface{}) != float64(2) {
+		t.Fatal("Unexpected type:", typ)
+	}
+}
@@ -49,6 +49,10 @@ type Guild struct {
 
 	// Defaults to en-US, only set if guild has DISCOVERABLE
 	PreferredLocale string `json:"preferred_locale"`
+
+	// Unavailable is true if the guild is down, or if it's a guild in Ready
+	// that's not loaded yet. Only the ID is set then.
+	Unavailable bool `json:"unavailable,omitempty"`
 }
 
 // IconURL returns the URL to the guild icon. An empty string is removed if
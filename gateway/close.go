@@ -0,0 +1,108 @@
+package gateway
+
+import (
+	"fmt"
+
+	"github.com/diamondburned/arikawa/internal/wsutil"
+	"github.com/pkg/errors"
+)
+
+// CloseCode is the code the Gateway closes the Websocket with.
+type CloseCode int
+
+// https://discordapp.com/developers/docs/topics/opcodes-and-status-codes#gateway-gateway-close-event-codes
+const (
+	UnknownErrorCode         CloseCode = 4000
+	UnknownOPCode            CloseCode = 4001
+	DecodeErrorCode          CloseCode = 4002
+	NotAuthenticatedCode     CloseCode = 4003
+	AuthenticationFailedCode CloseCode = 4004
+	AlreadyAuthenticatedCode CloseCode = 4005
+	InvalidSequenceCode      CloseCode = 4007
+	RateLimitedCode          CloseCode = 4008
+	SessionTimeoutCode       CloseCode = 4009
+	InvalidShardCode         CloseCode = 4010
+	ShardingRequiredCode     CloseCode = 4011
+	InvalidAPIVersionCode    CloseCode = 4012
+	InvalidIntentsCode       CloseCode = 4013
+	DisallowedIntentsCode    CloseCode = 4014
+)
+
+var closeCodeReasons = map[CloseCode]string{
+	UnknownErrorCode:         "unknown error",
+	UnknownOPCode:            "unknown opcode",
+	DecodeErrorCode:          "decode error",
+	NotAuthenticatedCode:     "not authenticated",
+	AuthenticationFailedCode: "authentication failed, the token is invalid",
+	AlreadyAuthenticatedCode: "already authenticated",
+	InvalidSequenceCode:      "invalid sequence",
+	RateLimitedCode:          "rate limited",
+	SessionTimeoutCode:       "session timed out",
+	InvalidShardCode:         "invalid shard",
+	ShardingRequiredCode:     "sharding required, the bot is in too many guilds",
+	InvalidAPIVersionCode:    "invalid API version",
+	InvalidIntentsCode:       "invalid intents",
+	DisallowedIntentsCode:    "disallowed intents, they're not enabled for the bot",
+}
+
+// String returns a description of the code.
+func (c CloseCode) String() string {
+	if reason, ok := closeCodeReasons[c]; ok {
+		return reason
+	}
+	return fmt.Sprintf("close code %d", int(c))
+}
+
+// Fatal returns true if reconnecting won't help, such as when the token is
+// invalid.
+func (c CloseCode) Fatal() bool {
+	switch c {
+	case AuthenticationFailedCode, InvalidShardCode, ShardingRequiredCode,
+		InvalidAPIVersionCode, InvalidIntentsCode, DisallowedIntentsCode:
+		return true
+	}
+	return false
+}
+
+// Resumable returns true if the session could be resumed after the connection
+// is closed with this code. Otherwise, a new session has to be identified.
+func (c CloseCode) Resumable() bool {
+	switch c {
+	case NotAuthenticatedCode, InvalidSequenceCode, SessionTimeoutCode:
+		return false
+	}
+	return !c.Fatal()
+}
+
+// CloseError is returned when Discord closes the Gateway with a close code.
+type CloseError struct {
+	Code   CloseCode
+	Reason string // sent by Discord, could be empty
+}
+
+func (err *CloseError) Error() string {
+	msg := fmt.Sprintf("Gateway closed with %d (%s)", int(err.Code), err.Code)
+	if err.Reason != "" {
+		msg += ": " + err.Reason
+	}
+	return msg
+}
+
+// AsCloseError returns the CloseError if err is caused by the Gateway closing
+// the connection with a close code.
+func AsCloseError(err error) (*CloseError, bool) {
+	var closeErr *CloseError
+	if errors.As(err, &closeErr) {
+		return closeErr, true
+	}
+
+	var wsErr *wsutil.CloseError
+	if errors.As(err, &wsErr) {
+		return &CloseError{
+			Code:   CloseCode(wsErr.Code),
+			Reason: wsErr.Reason,
+		}, true
+	}
+
+	return nil, false
+}
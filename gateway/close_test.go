@@ -0,0 +1,227 @@
+// +build unit
+
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	ijson "github.com/diamondburned/arikawa/internal/json"
+	"golang.org/x/time/rate"
+	"nhooyr.io/websocket"
+)
+
+// testGateway is a fake Gateway. Each connection is handled by the next
+// handler.
+type testGateway struct {
+	*httptest.Server
+	t *testing.T
+
+	mut      sync.Mutex
+	handlers []func(c *websocket.Conn, op *OP)
+}
+
+func newTestGateway(t *testing.T, handlers ...func(c *websocket.Conn, op *OP)) *testGateway {
+	g := &testGateway{t: t, handlers: handlers}
+	g.Server = httptest.NewServer(http.HandlerFunc(g.serve))
+	return g
+}
+
+func (g *testGateway) serve(w http.ResponseWriter, r *http.Request) {
+	g.mut.Lock()
+	if len(g.handlers) == 0 {
+		g.mut.Unlock()
+		g.t.Error("Unexpected connection")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	handler := g.handlers[0]
+	g.handlers = g.handlers[1:]
+	g.mut.Unlock()
+
+	c, err := websocket.Accept(w, r, nil)
+	if err != nil {
+		g.t.Error("Failed to accept:", err)
+		return
+	}
+	defer c.Close(websocket.StatusInternalError, "")
+
+	ctx := context.Background()
+
+	testWrite(g.t, c, `{"op":10,"d":{"heartbeat_interval":45000}}`)
+
+	_, b, err := c.Read(ctx)
+	if err != nil {
+		g.t.Error("Failed to read:", err)
+		return
+	}
+
+	var op *OP
+	if err := json.Unmarshal(b, &op); err != nil {
+		g.t.Error("Failed to decode:", err)
+		return
+	}
+
+	handler(c, op)
+
+	// Wait for the client to close.
+	c.Read(ctx)
+}
+
+func (g *testGateway) URL() string {
+	return strings.Replace(g.Server.URL, "http://", "ws://", 1)
+}
+
+func (g *testGateway) Remaining() int {
+	g.mut.Lock()
+	defer g.mut.Unlock()
+	return len(g.handlers)
+}
+
+func testWrite(t *testing.T, c *websocket.Conn, payload string) {
+	if err := c.Write(context.Background(), websocket.MessageText, []byte(payload)); err != nil {
+		t.Error("Failed to write:", err)
+	}
+}
+
+func expectOP(t *testing.T, op *OP, code OPCode) {
+	if op.Code != code {
+		t.Errorf("Unexpected OP %d, expected %d", op.Code, code)
+	}
+}
+
+func newTestGatewayClient(t *testing.T, URL string) *Gateway {
+	g, err := NewCustomGateway(URL, "Bot token", ijson.Default{})
+	if err != nil {
+		t.Fatal("Failed to create Gateway:", err)
+	}
+
+	g.WS.DialLimiter = rate.NewLimiter(rate.Inf, 1)
+	g.Identifier.IdentifyShortLimit = rate.NewLimiter(rate.Inf, 1)
+	g.ReconnectPolicy = ReconnectPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   time.Millisecond,
+		MaxDelay:    time.Millisecond,
+	}
+	g.ErrorLog = func(err error) { t.Log("Gateway error:", err) }
+	g.FatalLog = func(err error) { t.Error("Gateway fatal:", err) }
+
+	return g
+}
+
+func TestCloseCode(t *testing.T) {
+	var tests = []struct {
+		code      CloseCode
+		fatal     bool
+		resumable bool
+	}{
+		{UnknownErrorCode, false, true},
+		{NotAuthenticatedCode, false, false},
+		{AuthenticationFailedCode, true, false},
+		{SessionTimeoutCode, false, false},
+		{ShardingRequiredCode, true, false},
+		{DisallowedIntentsCode, true, false},
+		{1006, false, true},
+	}
+
+	for _, test := range tests {
+		if fatal := test.code.Fatal(); fatal != test.fatal {
+			t.Errorf("Unexpected Fatal for %d: %v", test.code, fatal)
+		}
+		if resumable := test.code.Resumable(); resumable != test.resumable {
+			t.Errorf("Unexpected Resumable for %d: %v", test.code, resumable)
+		}
+	}
+}
+
+func TestOpenFatalClose(t *testing.T) {
+	srv := newTestGateway(t, func(c *websocket.Conn, op *OP) {
+		expectOP(t, op, IdentifyOP)
+		c.Close(websocket.StatusCode(AuthenticationFailedCode), "Authentication failed.")
+	}, func(c *websocket.Conn, op *OP) {
+		t.Error("Gateway reconnected after a fatal close code")
+	})
+	defer srv.Close()
+
+	g := newTestGatewayClient(t, srv.URL())
+
+	err := g.Open()
+
+	closeErr, ok := AsCloseError(err)
+	if !ok {
+		t.Fatal("Unexpected error:", err)
+	}
+
+	if closeErr.Code != AuthenticationFailedCode || closeErr.Reason != "Authentication failed." {
+		t.Fatalf("Unexpected close error: %#v", closeErr)
+	}
+
+	if !strings.Contains(err.Error(), "authentication failed") {
+		t.Fatal("Error doesn't contain the reason:", err)
+	}
+}
+
+func TestReconnectCloseCodes(t *testing.T) {
+	resumed := make(chan struct{})
+
+	srv := newTestGateway(t, func(c *websocket.Conn, op *OP) {
+		// The session timed out, which needs a new Identify.
+		expectOP(t, op, IdentifyOP)
+		c.Close(websocket.StatusCode(SessionTimeoutCode), "")
+
+	}, func(c *websocket.Conn, op *OP) {
+		expectOP(t, op, IdentifyOP)
+		testWrite(t, c, `{"op":0,"t":"READY","s":1,"d":{"session_id":"session"}}`)
+
+		// Close with a resumable code after the Gateway is started.
+		time.Sleep(50 * time.Millisecond)
+		c.Close(websocket.StatusCode(UnknownErrorCode), "")
+
+	}, func(c *websocket.Conn, op *OP) {
+		expectOP(t, op, ResumeOP)
+
+		var resume ResumeData
+		json.Unmarshal(op.Data, &resume)
+
+		if resume.SessionID != "session" || resume.Sequence != 1 {
+			t.Errorf("Unexpected Resume: %#v", resume)
+		}
+
+		testWrite(t, c, `{"op":0,"t":"RESUMED","s":2,"d":{}}`)
+		close(resumed)
+	})
+	defer srv.Close()
+
+	g := newTestGatewayClient(t, srv.URL())
+
+	if err := g.Open(); err != nil {
+		t.Fatal("Failed to open:", err)
+	}
+
+	select {
+	case <-resumed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for Resume")
+	}
+
+	// Wait for the RESUMED event to be handled.
+	for ev := range g.Events {
+		if _, ok := ev.(*ResumedEvent); ok {
+			break
+		}
+	}
+
+	if err := g.Close(); err != nil {
+		t.Log("Failed to close:", err)
+	}
+
+	if n := srv.Remaining(); n != 0 {
+		t.Fatal("Unused connections:", n)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/diamondburned/arikawa/discord"
+	"github.com/diamondburned/arikawa/internal/json"
 	"github.com/pkg/errors"
 )
 
@@ -20,6 +21,15 @@ type IdentifyData struct {
 	Shard *Shard `json:"shard,omitempty"` // [ shard_id, num_shards ]
 
 	Presence *UpdateStatusData `json:"presence,omitempty"`
+
+	// Intents limits the events Discord sends. If zero, the field is omitted,
+	// and every event is sent.
+	Intents Intents `json:"intents,omitempty"`
+}
+
+// AddIntents adds the given intents to the ones sent on Identify.
+func (i *IdentifyData) AddIntents(intents Intents) {
+	i.Intents |= intents
 }
 
 func (i *IdentifyData) SetShard(id, num int) {
@@ -88,11 +98,33 @@ func (g *Gateway) Heartbeat() error {
 
 type RequestGuildMembersData struct {
 	GuildID []discord.Snowflake `json:"guild_id"`
-	UserIDs []discord.Snowflake `json:"user_id,omitempty"`
+	UserIDs []discord.Snowflake `json:"user_ids,omitempty"`
 
-	Query     string `json:"query,omitempty"`
+	// Query matches the start of usernames. An empty query with a 0 limit
+	// requests all members. It's not sent if UserIDs is given.
+	Query     string `json:"query"`
 	Limit     uint   `json:"limit"`
 	Presences bool   `json:"presences,omitempty"`
+
+	// Nonce is sent back in each GuildMembersChunkEvent for this request.
+	Nonce string `json:"nonce,omitempty"`
+}
+
+// MarshalJSON omits the query if there are user IDs, as Discord only accepts
+// one of them.
+func (data RequestGuildMembersData) MarshalJSON() ([]byte, error) {
+	type raw RequestGuildMembersData
+
+	if len(data.UserIDs) == 0 {
+		return (json.Default{}).Marshal(raw(data))
+	}
+
+	return (json.Default{}).Marshal(struct {
+		raw
+		Query *string `json:"query,omitempty"`
+	}{
+		raw: raw(data),
+	})
 }
 
 func (g *Gateway) RequestGuildMembers(data RequestGuildMembersData) error {
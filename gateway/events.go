@@ -1,6 +1,9 @@
 package gateway
 
-import "github.com/diamondburned/arikawa/discord"
+import (
+	"github.com/diamondburned/arikawa/discord"
+	"github.com/diamondburned/arikawa/internal/json"
+)
 
 // Rules: VOICE_STATE_UPDATE -> VoiceStateUpdateEvent
 
@@ -28,6 +31,16 @@ type (
 		ChannelID discord.Snowflake `json:"channel_id,omitempty"`
 		LastPin   discord.Timestamp `json:"timestamp,omitempty"`
 	}
+
+	// Undocumented, only sent to user accounts for group DMs.
+	ChannelRecipientAddEvent struct {
+		ChannelID discord.Snowflake `json:"channel_id"`
+		User      discord.User      `json:"user"`
+	}
+	ChannelRecipientRemoveEvent struct {
+		ChannelID discord.Snowflake `json:"channel_id"`
+		User      discord.User      `json:"user"`
+	}
 )
 
 // https://discordapp.com/developers/docs/topics/gateway#guilds
@@ -37,12 +50,11 @@ type (
 
 		Joined      discord.Timestamp `json:"timestamp,omitempty"`
 		Large       bool              `json:"large,omitempty"`
-		Unavailable bool              `json:"unavailable,omitempty"`
 		MemberCount uint64            `json:"member_count,omitempty"`
 
-		VoiceStates []discord.VoiceState `json:"voice_state,omitempty"`
+		VoiceStates []discord.VoiceState `json:"voice_states,omitempty"`
 		Members     []discord.Member     `json:"members,omitempty"`
-		Channels    []discord.Channel    `json:"channel,omitempty"`
+		Channels    []discord.Channel    `json:"channels,omitempty"`
 		Presences   []discord.Presence   `json:"presences,omitempty"`
 	}
 	GuildUpdateEvent discord.Guild
@@ -90,11 +102,15 @@ type (
 		GuildID discord.Snowflake `json:"guild_id"`
 		Members []discord.Member  `json:"members"`
 
+		ChunkIndex int `json:"chunk_index"`
+		ChunkCount int `json:"chunk_count"`
+
 		// Whatever's not found goes here
-		NotFound []string `json:"not_found,omitempty"`
+		NotFound []discord.Snowflake `json:"not_found,omitempty"`
 
 		// Only filled if requested
 		Presences []discord.Presence `json:"presences,omitempty"`
+		Nonce     string             `json:"nonce,omitempty"`
 	}
 
 	GuildRoleCreateEvent struct {
@@ -117,6 +133,31 @@ func (u GuildMemberUpdateEvent) Update(m *discord.Member) {
 	m.Nick = u.Nick
 }
 
+// https://discordapp.com/developers/docs/topics/gateway#invites
+type (
+	InviteCreateEvent struct {
+		Code      string            `json:"code"`
+		CreatedAt discord.Timestamp `json:"created_at"`
+		ChannelID discord.Snowflake `json:"channel_id"`
+		GuildID   discord.Snowflake `json:"guild_id,omitempty"`
+
+		Inviter *discord.User   `json:"inviter,omitempty"`
+		MaxAge  discord.Seconds `json:"max_age"`
+		MaxUses int             `json:"max_uses"`
+
+		Target     *discord.User          `json:"target_user,omitempty"` // partial
+		TargetType discord.InviteUserType `json:"target_user_type,omitempty"`
+
+		Temporary bool `json:"temporary"`
+		Uses      int  `json:"uses"`
+	}
+	InviteDeleteEvent struct {
+		Code      string            `json:"code"`
+		ChannelID discord.Snowflake `json:"channel_id"`
+		GuildID   discord.Snowflake `json:"guild_id,omitempty"`
+	}
+)
+
 // https://discordapp.com/developers/docs/topics/gateway#messages
 type (
 	MessageCreateEvent discord.Message
@@ -153,6 +194,20 @@ type (
 	}
 	MessageReactionRemoveAllEvent struct {
 		ChannelID discord.Snowflake `json:"channel_id"`
+		MessageID discord.Snowflake `json:"message_id"`
+		GuildID   discord.Snowflake `json:"guild_id,omitempty"`
+	}
+	MessageReactionRemoveEmojiEvent struct {
+		ChannelID discord.Snowflake `json:"channel_id"`
+		MessageID discord.Snowflake `json:"message_id"`
+		Emoji     discord.Emoji     `json:"emoji"`
+		GuildID   discord.Snowflake `json:"guild_id,omitempty"`
+	}
+
+	// Undocumented, only sent to user accounts when a message is read.
+	MessageAckEvent struct {
+		MessageID discord.Snowflake `json:"message_id"`
+		ChannelID discord.Snowflake `json:"channel_id"`
 	}
 )
 
@@ -160,7 +215,9 @@ type (
 type (
 	// Clients may only update their game status 5 times per 20 seconds.
 	PresenceUpdateEvent discord.Presence
-	TypingStartEvent    struct {
+	// Undocumented, replaces the presences sent in Ready.
+	PresencesReplaceEvent []discord.Presence
+	TypingStartEvent      struct {
 		ChannelID discord.Snowflake     `json:"channel_id"`
 		UserID    discord.Snowflake     `json:"user_id"`
 		Timestamp discord.UnixTimestamp `json:"timestamp"`
@@ -192,5 +249,43 @@ type (
 // Undocumented
 type (
 	UserGuildSettingsUpdateEvent UserGuildSettings
-	UserSettingsUpdateEvent      UserSettings
+	UserSettingsUpdateEvent      struct {
+		UserSettings
+
+		// raw has the fields that were sent, which are only the changed ones.
+		raw json.Raw
+	}
+	UserNoteUpdateEvent struct {
+		ID   discord.Snowflake `json:"id"`
+		Note string            `json:"note"`
+	}
+)
+
+func (u *UserSettingsUpdateEvent) UnmarshalJSON(b []byte) error {
+	u.raw = append(u.raw[:0], b...)
+	return (json.Default{}).Unmarshal(b, &u.UserSettings)
+}
+
+// Update applies the changed settings to s, leaving the rest as is.
+func (u UserSettingsUpdateEvent) Update(s *UserSettings) error {
+	if u.raw == nil {
+		*s = u.UserSettings
+		return nil
+	}
+
+	return (json.Default{}).Unmarshal(u.raw, s)
+}
+
+// Undocumented
+type (
+	RelationshipAddEvent    Relationship
+	RelationshipRemoveEvent Relationship
 )
+
+// RawEvent is sent for dispatches that don't have a known event type, so they
+// could still be handled manually. Data is encoded the same way as the
+// Gateway's payloads, so it should be decoded with the Gateway's Driver.
+type RawEvent struct {
+	Name string
+	Data json.Raw
+}
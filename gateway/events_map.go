@@ -14,6 +14,13 @@ var EventCreator = map[string]func() Event{
 	"CHANNEL_DELETE":      func() Event { return new(ChannelDeleteEvent) },
 	"CHANNEL_PINS_UPDATE": func() Event { return new(ChannelPinsUpdateEvent) },
 
+	"CHANNEL_RECIPIENT_ADD": func() Event {
+		return new(ChannelRecipientAddEvent)
+	},
+	"CHANNEL_RECIPIENT_REMOVE": func() Event {
+		return new(ChannelRecipientRemoveEvent)
+	},
+
 	"GUILD_CREATE": func() Event { return new(GuildCreateEvent) },
 	"GUILD_UPDATE": func() Event { return new(GuildUpdateEvent) },
 	"GUILD_DELETE": func() Event { return new(GuildDeleteEvent) },
@@ -35,6 +42,9 @@ var EventCreator = map[string]func() Event{
 	"GUILD_ROLE_UPDATE": func() Event { return new(GuildRoleUpdateEvent) },
 	"GUILD_ROLE_DELETE": func() Event { return new(GuildRoleDeleteEvent) },
 
+	"INVITE_CREATE": func() Event { return new(InviteCreateEvent) },
+	"INVITE_DELETE": func() Event { return new(InviteDeleteEvent) },
+
 	"MESSAGE_CREATE":      func() Event { return new(MessageCreateEvent) },
 	"MESSAGE_UPDATE":      func() Event { return new(MessageUpdateEvent) },
 	"MESSAGE_DELETE":      func() Event { return new(MessageDeleteEvent) },
@@ -49,13 +59,30 @@ var EventCreator = map[string]func() Event{
 	"MESSAGE_REACTION_REMOVE_ALL": func() Event {
 		return new(MessageReactionRemoveAllEvent)
 	},
+	"MESSAGE_REACTION_REMOVE_EMOJI": func() Event {
+		return new(MessageReactionRemoveEmojiEvent)
+	},
 
-	"PRESENCE_UPDATE": func() Event { return new(PresenceUpdateEvent) },
-	"TYPING_START":    func() Event { return new(TypingStartEvent) },
-	"USER_UPDATE":     func() Event { return new(UserUpdateEvent) },
+	"MESSAGE_ACK": func() Event { return new(MessageAckEvent) },
+
+	"PRESENCE_UPDATE":   func() Event { return new(PresenceUpdateEvent) },
+	"PRESENCES_REPLACE": func() Event { return new(PresencesReplaceEvent) },
+	"TYPING_START":      func() Event { return new(TypingStartEvent) },
+	"USER_UPDATE":       func() Event { return new(UserUpdateEvent) },
 
 	"VOICE_STATE_UPDATE":  func() Event { return new(VoiceStateUpdateEvent) },
 	"VOICE_SERVER_UPDATE": func() Event { return new(VoiceServerUpdateEvent) },
 
 	"WEBHOOKS_UPDATE": func() Event { return new(WebhooksUpdateEvent) },
+
+	"USER_SETTINGS_UPDATE": func() Event {
+		return new(UserSettingsUpdateEvent)
+	},
+	"USER_GUILD_SETTINGS_UPDATE": func() Event {
+		return new(UserGuildSettingsUpdateEvent)
+	},
+	"USER_NOTE_UPDATE": func() Event { return new(UserNoteUpdateEvent) },
+
+	"RELATIONSHIP_ADD":    func() Event { return new(RelationshipAddEvent) },
+	"RELATIONSHIP_REMOVE": func() Event { return new(RelationshipRemoveEvent) },
 }
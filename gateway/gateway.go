@@ -16,6 +16,8 @@ import (
 	"time"
 
 	"github.com/diamondburned/arikawa/api"
+	"github.com/diamondburned/arikawa/discord"
+	"github.com/diamondburned/arikawa/internal/etf"
 	"github.com/diamondburned/arikawa/internal/httputil"
 	"github.com/diamondburned/arikawa/internal/json"
 	"github.com/diamondburned/arikawa/internal/wsutil"
@@ -28,6 +30,10 @@ const (
 
 	Version  = "6"
 	Encoding = "json"
+	// EncodingETF is the Erlang term format encoding used by UseETF.
+	EncodingETF = "etf"
+	// Compression is the transport compression used by UseZlibStream.
+	Compression = "zlib-stream"
 )
 
 var (
@@ -64,6 +70,32 @@ func GatewayURL() (string, error) {
 		&Gateway, "GET", EndpointGateway)
 }
 
+// BotData contains the GatewayURL as well as extra metadata on how to
+// shard bots.
+type BotData struct {
+	URL        string             `json:"url"`
+	Shards     int                `json:"shards,omitempty"`
+	StartLimit *SessionStartLimit `json:"session_start_limit,omitempty"`
+}
+
+// SessionStartLimit is the information on the current session start limit.
+// It's used in BotData.
+type SessionStartLimit struct {
+	Total          int                  `json:"total"`
+	Remaining      int                  `json:"remaining"`
+	ResetAfter     discord.Milliseconds `json:"reset_after"`
+	MaxConcurrency int                  `json:"max_concurrency"`
+}
+
+// BotURL fetches the Gateway URL along with extra metadata. The token is used
+// as-is, so it has to be prefixed with "Bot ".
+func BotURL(token string) (*BotData, error) {
+	var d *BotData
+
+	return d, api.NewClient(token).RequestJSON(
+		&d, "GET", EndpointGatewayBot)
+}
+
 // Identity is used as the default identity when initializing a new Gateway.
 var Identity = IdentifyProperties{
 	OS:      runtime.GOOS,
@@ -79,6 +111,10 @@ type Gateway struct {
 	// WSTimeout (global).
 	WSTimeout time.Duration
 
+	// ReconnectPolicy is used by Open and Reconnect, uses default
+	// DefaultReconnectPolicy (global).
+	ReconnectPolicy ReconnectPolicy
+
 	// All events sent over are pointers to Event structs (structs suffixed with
 	// "Event"). This shouldn't be accessed if the Gateway is created with a
 	// Session.
@@ -86,6 +122,18 @@ type Gateway struct {
 
 	SessionID string
 
+	// Persister, if non-nil, saves the session ID and sequence, so that Open
+	// could resume the session after the process restarts. FilePersister is
+	// the default implementation.
+	Persister ResumePersister
+	// PersistInterval is how often the resume state is saved while connected,
+	// uses default DefaultPersistInterval (global). It's also saved on Close.
+	// The state isn't saved on every dispatch, as a synchronous save in the
+	// event loop would stall heartbeats on busy bots, so a crash could lose up
+	// to one interval of sequence numbers. Discord replays those on Resume
+	// anyway. A PersistInterval of 0 only saves on Close.
+	PersistInterval time.Duration
+
 	Identifier *Identifier
 	Pacemaker  *Pacemaker
 	Sequence   *Sequence
@@ -102,6 +150,7 @@ type Gateway struct {
 	// Filled by methods, internal use
 	paceDeath chan error
 	waitGroup *sync.WaitGroup
+	persisted ResumeState // last saved
 }
 
 // NewGateway starts a new Gateway with the default stdlib JSON driver. For more
@@ -117,14 +166,22 @@ func NewGatewayWithDriver(token string, driver json.Driver) (*Gateway, error) {
 		return nil, errors.Wrap(err, "Failed to get gateway endpoint")
 	}
 
+	return NewCustomGateway(URL, token, driver)
+}
+
+// NewCustomGateway creates a new undialed Gateway with the given Gateway URL,
+// which is usually obtained from GatewayURL or BotURL.
+func NewCustomGateway(URL, token string, driver json.Driver) (*Gateway, error) {
 	g := &Gateway{
-		Driver:     driver,
-		WSTimeout:  WSTimeout,
-		Events:     make(chan Event, WSBuffer),
-		Identifier: DefaultIdentifier(token),
-		Sequence:   NewSequence(),
-		ErrorLog:   WSError,
-		FatalLog:   WSFatal,
+		Driver:          driver,
+		WSTimeout:       WSTimeout,
+		ReconnectPolicy: DefaultReconnectPolicy,
+		PersistInterval: DefaultPersistInterval,
+		Events:          make(chan Event, WSBuffer),
+		Identifier:      DefaultIdentifier(token),
+		Sequence:        NewSequence(),
+		ErrorLog:        WSError,
+		FatalLog:        WSFatal,
 	}
 
 	// Parameters for the gateway
@@ -148,23 +205,91 @@ func NewGatewayWithDriver(token string, driver json.Driver) (*Gateway, error) {
 	return g, nil
 }
 
+// UseZlibStream enables or disables zlib-stream transport compression, which
+// inflates all messages from the Gateway with a single zlib context. It has to
+// be called before Open. Payload compression in Identify is disabled while
+// this is enabled, as Discord doesn't allow both.
+func (g *Gateway) UseZlibStream(enable bool) error {
+	var compress string
+	if enable {
+		compress = Compression
+	}
+
+	if err := g.setParam("compress", compress); err != nil {
+		return err
+	}
+
+	g.Identifier.Compress = !enable
+
+	// Custom connections have to handle the compression themselves.
+	if conn, ok := g.WS.Conn.(*wsutil.Conn); ok {
+		conn.ZlibStream = enable
+	}
+
+	return nil
+}
+
+// UseETF switches the Gateway to the Erlang term format encoding, which has
+// smaller payloads than JSON. It has to be called before Open.
+func (g *Gateway) UseETF() error {
+	if err := g.setParam("encoding", EncodingETF); err != nil {
+		return err
+	}
+
+	g.Driver = etf.Driver{}
+
+	if conn, ok := g.WS.Conn.(*wsutil.Conn); ok {
+		conn.Driver = g.Driver
+	}
+
+	return nil
+}
+
+// setParam sets a query parameter in the Gateway URL. An empty value removes
+// the parameter.
+func (g *Gateway) setParam(key, value string) error {
+	u, err := url.Parse(g.WS.Addr)
+	if err != nil {
+		return errors.Wrap(err, "Failed to parse Gateway URL")
+	}
+
+	q := u.Query()
+	if value != "" {
+		q.Set(key, value)
+	} else {
+		q.Del(key)
+	}
+	u.RawQuery = q.Encode()
+
+	g.WS.Addr = u.String()
+	return nil
+}
+
 // Close closes the underlying Websocket connection.
 func (g *Gateway) Close() error {
 	WSDebug("Stopping pacemaker...")
 
 	// If the pacemaker is running:
 	// Stop the pacemaker and the event handler
-	g.Pacemaker.Stop()
+	if g.Pacemaker != nil {
+		g.Pacemaker.Stop()
+	}
 
 	WSDebug("Stopped pacemaker. Waiting for WaitGroup to be done.")
 
 	// This should work, since Pacemaker should signal its loop to stop, which
-	// would also exit our event loop. Both would be 2.
-	g.waitGroup.Wait()
+	// would also exit our event loop. Both would be 2. The WaitGroup is nil if
+	// the connection closed before Hello.
+	if g.waitGroup != nil {
+		g.waitGroup.Wait()
+	}
 
 	// Mark g.waitGroup as empty:
 	g.waitGroup = nil
 
+	// Save the session so it could be resumed later.
+	g.saveResumeState()
+
 	// Stop the Websocket
 	return g.WS.Close(nil)
 }
@@ -184,49 +309,87 @@ func (g *Gateway) Reconnect() error {
 	return g.Open()
 }
 
+// Open connects to the Websocket and authenticates it. It retries with the
+// Gateway's ReconnectPolicy. For more information, refer to OpenContext.
 func (g *Gateway) Open() error {
-	// Reconnect timeout
-	// ctx, cancel := context.WithTimeout(context.Background(), g.WSTimeout)
-	// defer cancel()
-
-	// TODO: this could be of some use.
-	ctx := context.Background()
-
-	for i := 0; ; i++ {
-		/* Context doesn't time out.
-
-		// Check if context is expired
-		if err := ctx.Err(); err != nil {
-			// Close the connection
-			g.Close()
+	return g.OpenContext(context.Background())
+}
 
-			// Don't bother if it's expired
-			return err
+// OpenContext connects to the Websocket and authenticates it, retrying with
+// exponential backoff until ctx expires or the ReconnectPolicy's MaxAttempts
+// is reached. In the latter case, the returned error matches ErrWSMaxTries
+// with errors.Is and wraps the error of the last try.
+func (g *Gateway) OpenContext(ctx context.Context) error {
+	// Try to resume a persisted session first.
+	g.loadResumeState()
+
+	var policy = g.ReconnectPolicy
+	var lastErr error
+
+	for i := 0; policy.MaxAttempts <= 0 || i < policy.MaxAttempts; i++ {
+		// Back off before retrying.
+		if i > 0 {
+			delay := policy.Delay(i - 1)
+			WSDebug("Waiting before retrying:", delay)
+
+			timer := time.NewTimer(delay)
+
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return errors.Wrap(ctx.Err(), "Failed to open gateway")
+			}
 		}
 
-		*/
-
 		WSDebug("Trying to dial...", i)
 
 		// Reconnect to the Gateway
 		if err := g.WS.Dial(ctx); err != nil {
+			// Don't retry if the context expired.
+			if ctx.Err() != nil {
+				return errors.Wrap(ctx.Err(), "Failed to open gateway")
+			}
+
 			// Save the error, retry again
-			g.ErrorLog(errors.Wrap(err, "Failed to reconnect"))
+			lastErr = errors.Wrap(err, "Failed to reconnect")
+			g.ErrorLog(lastErr)
 			continue
 		}
 
 		WSDebug("Trying to start...", i)
 
 		// Try to resume the connection
-		if err := g.Start(); err != nil {
+		if err := g.StartContext(ctx); err != nil {
+			// Don't retry if the context expired.
+			if ctx.Err() != nil {
+				return errors.Wrap(ctx.Err(), "Failed to open gateway")
+			}
+
 			// If the connection is rate limited (documented behavior):
 			// https://discordapp.com/developers/docs/topics/gateway#rate-limiting
-			if err == ErrInvalidSession {
-				continue
+			lastErr = errors.Wrap(err, "Failed to start gateway")
+
+			if closeErr, ok := AsCloseError(err); ok {
+				// Keep the CloseError for errors.As.
+				lastErr = errors.Wrap(closeErr, "Failed to start gateway")
+
+				// Don't retry if it won't help, such as with an invalid token.
+				if closeErr.Code.Fatal() {
+					return lastErr
+				}
+
+				// Identify a new session in the next try.
+				if !closeErr.Code.Resumable() {
+					g.resetSession()
+				}
+			}
+
+			if err != ErrInvalidSession {
+				g.ErrorLog(lastErr)
 			}
 
 			// Else, keep retrying
-			g.ErrorLog(errors.Wrap(err, "Failed to start gateway"))
 			continue
 		}
 
@@ -234,12 +397,21 @@ func (g *Gateway) Open() error {
 		// Started successfully, return
 		return nil
 	}
+
+	return &maxTriesError{lastErr}
 }
 
 // Start authenticates with the websocket, or resume from a dead Websocket
-// connection. This function doesn't block.
+// connection. This function doesn't block. For more information, refer to
+// StartContext.
 func (g *Gateway) Start() error {
-	if err := g.start(); err != nil {
+	return g.StartContext(context.Background())
+}
+
+// StartContext authenticates with the websocket, or resume from a dead
+// Websocket connection. The handshake is aborted if ctx expires.
+func (g *Gateway) StartContext(ctx context.Context) error {
+	if err := g.start(ctx); err != nil {
 		WSDebug("Start failed:", err)
 		if err := g.Close(); err != nil {
 			WSDebug("Failed to close after start fail:", err)
@@ -249,13 +421,20 @@ func (g *Gateway) Start() error {
 	return nil
 }
 
-func (g *Gateway) start() error {
+func (g *Gateway) start(ctx context.Context) error {
 	// This is where we'll get our events
 	ch := g.WS.Listen()
 
 	// Wait for an OP 10 Hello
+	var ev wsutil.Event
+	select {
+	case ev = <-ch:
+	case <-ctx.Done():
+		return errors.Wrap(ctx.Err(), "Failed to wait for Hello")
+	}
+
 	var hello HelloEvent
-	if _, err := AssertEvent(g, <-ch, HelloOP, &hello); err != nil {
+	if _, err := AssertEvent(g, ev, HelloOP, &hello); err != nil {
 		return errors.Wrap(err, "Error at Hello")
 	}
 
@@ -285,7 +464,11 @@ func (g *Gateway) start() error {
 	}
 
 	// Expect at least one event
-	ev := <-ch
+	select {
+	case ev = <-ch:
+	case <-ctx.Done():
+		return errors.Wrap(ctx.Err(), "Failed to wait for the first event")
+	}
 
 	// Check for error
 	if ev.Error != nil {
@@ -312,6 +495,20 @@ func (g *Gateway) handleWS() {
 	g.waitGroup.Done()
 
 	if err != nil {
+		if closeErr, ok := AsCloseError(err); ok {
+			// Reconnecting won't help, so stop here.
+			if closeErr.Code.Fatal() {
+				g.Close()
+				g.FatalLog(closeErr)
+				return
+			}
+
+			// The session can't be resumed, so identify a new one.
+			if !closeErr.Code.Resumable() {
+				g.resetSession()
+			}
+		}
+
 		if err := g.Reconnect(); err != nil {
 			g.FatalLog(errors.Wrap(err, "Failed to reconnect"))
 		}
@@ -323,8 +520,21 @@ func (g *Gateway) handleWS() {
 func (g *Gateway) eventLoop() error {
 	ch := g.WS.Listen()
 
+	// Save the resume state every once in a while rather than on every event,
+	// as saving could be slow.
+	var persist <-chan time.Time
+	if g.Persister != nil && g.PersistInterval > 0 {
+		ticker := time.NewTicker(g.PersistInterval)
+		defer ticker.Stop()
+
+		persist = ticker.C
+	}
+
 	for {
 		select {
+		case <-persist:
+			g.saveResumeState()
+
 		case err := <-g.paceDeath:
 			// Got a paceDeath, we're exiting from here on out.
 			g.paceDeath = nil // mark
@@ -340,6 +550,12 @@ func (g *Gateway) eventLoop() error {
 		case ev := <-ch:
 			// Check for error
 			if ev.Error != nil {
+				// The Gateway closed the connection, so handleWS decides
+				// whether to resume, identify or stop.
+				if closeErr, ok := AsCloseError(ev.Error); ok {
+					return closeErr
+				}
+
 				g.ErrorLog(ev.Error)
 				continue
 			}
@@ -357,17 +573,14 @@ func (g *Gateway) eventLoop() error {
 }
 
 func (g *Gateway) Send(code OPCode, v interface{}) error {
-	var op = OP{
+	// The payload is encoded at once, as not all drivers could embed an
+	// already encoded json.Raw.
+	var op = struct {
+		Code OPCode      `json:"op"`
+		Data interface{} `json:"d,omitempty"`
+	}{
 		Code: code,
-	}
-
-	if v != nil {
-		b, err := g.Driver.Marshal(v)
-		if err != nil {
-			return errors.Wrap(err, "Failed to encode v")
-		}
-
-		op.Data = b
+		Data: v,
 	}
 
 	b, err := g.Driver.Marshal(op)
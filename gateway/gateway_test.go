@@ -0,0 +1,160 @@
+// +build unit
+
+package gateway
+
+import (
+	"testing"
+
+	"github.com/diamondburned/arikawa/discord"
+	"github.com/diamondburned/arikawa/internal/json"
+	"github.com/diamondburned/arikawa/internal/wsutil"
+)
+
+func TestUseZlibStream(t *testing.T) {
+	g, err := NewCustomGateway("wss://gateway.discord.gg", "Bot token", json.Default{})
+	if err != nil {
+		t.Fatal("Failed to create Gateway:", err)
+	}
+
+	if err := g.UseZlibStream(true); err != nil {
+		t.Fatal("Failed to enable zlib-stream:", err)
+	}
+
+	const withStream = "wss://gateway.discord.gg?compress=zlib-stream&encoding=json&v=6"
+	if g.WS.Addr != withStream {
+		t.Fatal("Unexpected URL:", g.WS.Addr)
+	}
+
+	if g.Identifier.Compress {
+		t.Fatal("Payload compression is still enabled")
+	}
+
+	if !g.WS.Conn.(*wsutil.Conn).ZlibStream {
+		t.Fatal("zlib-stream is not enabled on the connection")
+	}
+
+	if err := g.UseZlibStream(false); err != nil {
+		t.Fatal("Failed to disable zlib-stream:", err)
+	}
+
+	const withoutStream = "wss://gateway.discord.gg?encoding=json&v=6"
+	if g.WS.Addr != withoutStream {
+		t.Fatal("Unexpected URL:", g.WS.Addr)
+	}
+
+	if !g.Identifier.Compress || g.WS.Conn.(*wsutil.Conn).ZlibStream {
+		t.Fatal("zlib-stream is not disabled")
+	}
+}
+
+func TestUseETF(t *testing.T) {
+	g, err := NewCustomGateway("wss://gateway.discord.gg", "Bot token", json.Default{})
+	if err != nil {
+		t.Fatal("Failed to create Gateway:", err)
+	}
+
+	if err := g.UseETF(); err != nil {
+		t.Fatal("Failed to use ETF:", err)
+	}
+
+	if g.WS.Addr != "wss://gateway.discord.gg?encoding=etf&v=6" {
+		t.Fatal("Unexpected URL:", g.WS.Addr)
+	}
+
+	// Payloads have to be sent as binary messages.
+	if d, ok := g.WS.Conn.(*wsutil.Conn).Driver.(json.BinaryDriver); !ok || !d.Binary() {
+		t.Fatal("The connection doesn't send binary messages")
+	}
+
+	// Identify should be encoded and decoded back the same.
+	b, err := g.Driver.Marshal(g.Identifier)
+	if err != nil {
+		t.Fatal("Failed to marshal Identify:", err)
+	}
+
+	var data IdentifyData
+	if err := g.Driver.Unmarshal(b, &data); err != nil {
+		t.Fatal("Failed to unmarshal Identify:", err)
+	}
+
+	if data.Token != "Bot token" || *data.Shard != *DefaultShard() {
+		t.Fatalf("Unexpected Identify: %#v", data)
+	}
+}
+
+func TestHandleOPEvents(t *testing.T) {
+	g, err := NewCustomGateway("wss://gateway.discord.gg", "Bot token", json.Default{})
+	if err != nil {
+		t.Fatal("Failed to create Gateway:", err)
+	}
+
+	err = HandleOP(g, &OP{
+		Code:      DispatchOP,
+		Data:      json.Raw(`{"guild_id":"1","nonce":"abc","not_found":["2"]}`),
+		Sequence:  1,
+		EventName: "GUILD_MEMBERS_CHUNK",
+	})
+	if err != nil {
+		t.Fatal("Failed to handle chunk:", err)
+	}
+
+	chunk, ok := (<-g.Events).(*GuildMembersChunkEvent)
+	if !ok {
+		t.Fatal("Event is not a GuildMembersChunkEvent")
+	}
+
+	if chunk.Nonce != "abc" || len(chunk.NotFound) != 1 || chunk.NotFound[0] != 2 {
+		t.Fatalf("Unexpected chunk: %#v", chunk)
+	}
+
+	err = HandleOP(g, &OP{
+		Code:      DispatchOP,
+		Data:      json.Raw(`{"foo":"bar"}`),
+		Sequence:  2,
+		EventName: "SOME_NEW_EVENT",
+	})
+	if err != nil {
+		t.Fatal("Failed to handle an unknown event:", err)
+	}
+
+	raw, ok := (<-g.Events).(*RawEvent)
+	if !ok {
+		t.Fatal("Unknown event is not a RawEvent")
+	}
+
+	if raw.Name != "SOME_NEW_EVENT" || string(raw.Data) != `{"foo":"bar"}` {
+		t.Fatalf("Unexpected RawEvent: %#v", raw)
+	}
+
+	if seq := g.Sequence.Get(); seq != 2 {
+		t.Fatal("Unexpected sequence:", seq)
+	}
+}
+
+func TestRequestGuildMembersData(t *testing.T) {
+	var tests = []struct {
+		data   RequestGuildMembersData
+		expect string
+	}{{
+		RequestGuildMembersData{GuildID: []discord.Snowflake{1}, Nonce: "a"},
+		`{"guild_id":["1"],"query":"","limit":0,"nonce":"a"}`,
+	}, {
+		RequestGuildMembersData{
+			GuildID: []discord.Snowflake{1},
+			UserIDs: []discord.Snowflake{2},
+			Query:   "ignored",
+		},
+		`{"guild_id":["1"],"user_ids":["2"],"limit":0}`,
+	}}
+
+	for _, test := range tests {
+		b, err := (json.Default{}).Marshal(test.data)
+		if err != nil {
+			t.Fatal("Failed to marshal:", err)
+		}
+
+		if string(b) != test.expect {
+			t.Fatal("Unexpected JSON:", string(b))
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package gateway
+
+import "reflect"
+
+// Intents is a bitflag of the event groups the Gateway should send. Events
+// not in any of the given intents are not sent at all.
+//
+// https://discordapp.com/developers/docs/topics/gateway#gateway-intents
+type Intents uint32
+
+const (
+	IntentGuilds Intents = 1 << iota
+	IntentGuildMembers
+	IntentGuildBans
+	IntentGuildEmojis
+	IntentGuildIntegrations
+	IntentGuildWebhooks
+	IntentGuildInvites
+	IntentGuildVoiceStates
+	IntentGuildPresences
+	IntentGuildMessages
+	IntentGuildMessageReactions
+	IntentGuildMessageTyping
+	IntentDirectMessages
+	IntentDirectMessageReactions
+	IntentDirectMessageTyping
+)
+
+// PrivilegedIntents are the intents that have to be enabled for the bot in
+// the Developer Portal. Identifying with them otherwise closes the Gateway with
+// DisallowedIntentsCode.
+const PrivilegedIntents = IntentGuildMembers | IntentGuildPresences
+
+// AllIntents has every intent, including the privileged ones.
+const AllIntents = IntentGuilds | IntentGuildMembers | IntentGuildBans |
+	IntentGuildEmojis | IntentGuildIntegrations | IntentGuildWebhooks |
+	IntentGuildInvites | IntentGuildVoiceStates | IntentGuildPresences |
+	IntentGuildMessages | IntentGuildMessageReactions |
+	IntentGuildMessageTyping | IntentDirectMessages |
+	IntentDirectMessageReactions | IntentDirectMessageTyping
+
+// Has returns true if i has all of the given intents.
+func (i Intents) Has(intents Intents) bool {
+	return i&intents == intents
+}
+
+// EventIntents maps each event name to the intents that would have Discord
+// send it. An event sent regardless of intents, such as READY, isn't in the
+// map.
+var EventIntents = map[string]Intents{
+	"CHANNEL_CREATE":      IntentGuilds,
+	"CHANNEL_UPDATE":      IntentGuilds,
+	"CHANNEL_DELETE":      IntentGuilds,
+	"CHANNEL_PINS_UPDATE": IntentGuilds | IntentDirectMessages,
+
+	"GUILD_CREATE": IntentGuilds,
+	"GUILD_UPDATE": IntentGuilds,
+	"GUILD_DELETE": IntentGuilds,
+
+	"GUILD_BAN_ADD":    IntentGuildBans,
+	"GUILD_BAN_REMOVE": IntentGuildBans,
+
+	"GUILD_EMOJIS_UPDATE":       IntentGuildEmojis,
+	"GUILD_INTEGRATIONS_UPDATE": IntentGuildIntegrations,
+
+	"GUILD_MEMBER_ADD":    IntentGuildMembers,
+	"GUILD_MEMBER_REMOVE": IntentGuildMembers,
+	"GUILD_MEMBER_UPDATE": IntentGuildMembers,
+
+	"GUILD_ROLE_CREATE": IntentGuilds,
+	"GUILD_ROLE_UPDATE": IntentGuilds,
+	"GUILD_ROLE_DELETE": IntentGuilds,
+
+	"INVITE_CREATE": IntentGuildInvites,
+	"INVITE_DELETE": IntentGuildInvites,
+
+	"MESSAGE_CREATE":      IntentGuildMessages | IntentDirectMessages,
+	"MESSAGE_UPDATE":      IntentGuildMessages | IntentDirectMessages,
+	"MESSAGE_DELETE":      IntentGuildMessages | IntentDirectMessages,
+	"MESSAGE_DELETE_BULK": IntentGuildMessages,
+
+	"MESSAGE_REACTION_ADD": IntentGuildMessageReactions |
+		IntentDirectMessageReactions,
+	"MESSAGE_REACTION_REMOVE": IntentGuildMessageReactions |
+		IntentDirectMessageReactions,
+	"MESSAGE_REACTION_REMOVE_ALL": IntentGuildMessageReactions |
+		IntentDirectMessageReactions,
+	"MESSAGE_REACTION_REMOVE_EMOJI": IntentGuildMessageReactions |
+		IntentDirectMessageReactions,
+
+	"PRESENCE_UPDATE": IntentGuildPresences,
+	"TYPING_START":    IntentGuildMessageTyping | IntentDirectMessageTyping,
+
+	"VOICE_STATE_UPDATE": IntentGuildVoiceStates,
+	"WEBHOOKS_UPDATE":    IntentGuildWebhooks,
+}
+
+// DerivedIntents returns the intents needed for events of the given types,
+// which are usually the argument types of event handlers. Each type is either
+// a pointer to an event, or an interface that adds the intents of every event
+// implementing it. interface{} is skipped, as it would need every intent.
+//
+// The intents should be set on the Identifier before the Gateway is opened.
+func DerivedIntents(types []reflect.Type) Intents {
+	var intents Intents
+
+	for name, fn := range EventCreator {
+		evIntents, ok := EventIntents[name]
+		if !ok {
+			continue
+		}
+
+		evT := reflect.TypeOf(fn())
+
+		for _, t := range types {
+			if t.Kind() == reflect.Interface {
+				if t.NumMethod() > 0 && evT.Implements(t) {
+					intents |= evIntents
+					break
+				}
+				continue
+			}
+
+			if t == evT {
+				intents |= evIntents
+				break
+			}
+		}
+	}
+
+	return intents
+}
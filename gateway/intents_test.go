@@ -0,0 +1,41 @@
+// +build unit
+
+package gateway
+
+import (
+	"reflect"
+	"testing"
+)
+
+// eventNamer is implemented by no event, so it shouldn't add any intent.
+type eventNamer interface {
+	EventName() string
+}
+
+func TestDerivedIntents(t *testing.T) {
+	if intents := DerivedIntents(nil); intents != 0 {
+		t.Fatal("Unexpected intents without types:", intents)
+	}
+
+	// A catch-all type shouldn't add every intent.
+	types := []reflect.Type{
+		reflect.TypeOf((*interface{})(nil)).Elem(),
+		reflect.TypeOf((*eventNamer)(nil)).Elem(),
+	}
+
+	if intents := DerivedIntents(types); intents != 0 {
+		t.Fatal("Unexpected intents for interfaces:", intents)
+	}
+
+	types = append(types,
+		reflect.TypeOf((*MessageCreateEvent)(nil)),
+		reflect.TypeOf((*GuildBanAddEvent)(nil)),
+		reflect.TypeOf((*ReadyEvent)(nil)),
+	)
+
+	expect := IntentGuildMessages | IntentDirectMessages | IntentGuildBans
+
+	if intents := DerivedIntents(types); intents != expect {
+		t.Fatalf("Unexpected intents %b, expected %b", intents, expect)
+	}
+}
@@ -127,7 +127,8 @@ func HandleOP(g *Gateway, op *OP) error {
 		// Discord expects us to sleep for no reason
 		time.Sleep(time.Duration(rand.Intn(5)+1) * time.Second)
 
-		// Invalid session, respond with Identify.
+		// Invalid session, forget it and respond with Identify.
+		g.resetSession()
 		return g.Identify()
 
 	case HelloOP:
@@ -143,7 +144,12 @@ func HandleOP(g *Gateway, op *OP) error {
 		// Check if we know the event
 		fn, ok := EventCreator[op.EventName]
 		if !ok {
-			return errors.New("Unknown event: " + op.EventName)
+			// Send the unknown event as-is, rather than dropping it.
+			g.Events <- &RawEvent{
+				Name: op.EventName,
+				Data: op.Data,
+			}
+			return nil
 		}
 
 		// Make a new pointer to the event
@@ -0,0 +1,168 @@
+package gateway
+
+import (
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/diamondburned/arikawa/internal/json"
+	"github.com/pkg/errors"
+)
+
+// ResumeState is the state needed to resume a Gateway session.
+type ResumeState struct {
+	SessionID string `json:"session_id"`
+	Sequence  int64  `json:"seq"`
+}
+
+// ResumePersister saves the resume state of a Gateway, so that the session
+// could be resumed after the process restarts instead of identifying again.
+//
+// Save is called every Gateway's PersistInterval while the state changes, and
+// when the Gateway is closed.
+type ResumePersister interface {
+	// Load returns the saved state, or nil if there is none.
+	Load() (*ResumeState, error)
+	Save(ResumeState) error
+	// Clear removes the saved state. It's called when Discord invalidates the
+	// session.
+	Clear() error
+}
+
+// DefaultPersistInterval is the default PersistInterval for new Gateways. A
+// shorter interval loses fewer sequence numbers on a crash, at the cost of
+// more writes.
+var DefaultPersistInterval = 5 * time.Second
+
+// FilePersister is the default ResumePersister, which saves the state as a
+// JSON file. Each Gateway, including each shard, needs its own file.
+type FilePersister struct {
+	Path string
+
+	mut sync.Mutex
+}
+
+var _ ResumePersister = (*FilePersister)(nil)
+
+func NewFilePersister(path string) *FilePersister {
+	return &FilePersister{Path: path}
+}
+
+func (p *FilePersister) Load() (*ResumeState, error) {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+
+	b, err := ioutil.ReadFile(p.Path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+
+		return nil, errors.Wrap(err, "Failed to read resume state")
+	}
+
+	var s *ResumeState
+	if err := (json.Default{}).Unmarshal(b, &s); err != nil {
+		return nil, errors.Wrap(err, "Failed to decode resume state")
+	}
+
+	return s, nil
+}
+
+// Save writes the state into a temporary file, then renames it over the old
+// file, so a crash while saving doesn't leave a broken file.
+func (p *FilePersister) Save(s ResumeState) error {
+	b, err := (json.Default{}).Marshal(s)
+	if err != nil {
+		return errors.Wrap(err, "Failed to encode resume state")
+	}
+
+	p.mut.Lock()
+	defer p.mut.Unlock()
+
+	tmp := p.Path + ".tmp"
+
+	if err := ioutil.WriteFile(tmp, b, 0600); err != nil {
+		return errors.Wrap(err, "Failed to write resume state")
+	}
+
+	if err := os.Rename(tmp, p.Path); err != nil {
+		return errors.Wrap(err, "Failed to replace resume state")
+	}
+
+	return nil
+}
+
+func (p *FilePersister) Clear() error {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+
+	if err := os.Remove(p.Path); err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "Failed to remove resume state")
+	}
+
+	return nil
+}
+
+// loadResumeState loads the persisted state if the Gateway doesn't have a
+// session yet, so that Open tries to resume first.
+func (g *Gateway) loadResumeState() {
+	if g.Persister == nil || g.SessionID != "" {
+		return
+	}
+
+	s, err := g.Persister.Load()
+	if err != nil {
+		g.ErrorLog(errors.Wrap(err, "Failed to load resume state"))
+		return
+	}
+
+	if s == nil || s.SessionID == "" || s.Sequence == 0 {
+		return
+	}
+
+	g.SessionID = s.SessionID
+	g.Sequence.Set(s.Sequence)
+	g.persisted = *s
+}
+
+// saveResumeState saves the current session, if there's one and it changed
+// since the last save.
+func (g *Gateway) saveResumeState() {
+	if g.Persister == nil || g.SessionID == "" {
+		return
+	}
+
+	s := ResumeState{
+		SessionID: g.SessionID,
+		Sequence:  g.Sequence.Get(),
+	}
+
+	if s == g.persisted {
+		return
+	}
+
+	if err := g.Persister.Save(s); err != nil {
+		g.ErrorLog(errors.Wrap(err, "Failed to save resume state"))
+		return
+	}
+
+	g.persisted = s
+}
+
+// resetSession forgets the current session, so that the next Identify starts
+// a new one.
+func (g *Gateway) resetSession() {
+	g.SessionID = ""
+	g.Sequence.Set(0)
+	g.persisted = ResumeState{}
+
+	if g.Persister == nil {
+		return
+	}
+
+	if err := g.Persister.Clear(); err != nil {
+		g.ErrorLog(errors.Wrap(err, "Failed to clear resume state"))
+	}
+}
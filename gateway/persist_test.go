@@ -0,0 +1,128 @@
+// +build unit
+
+package gateway
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/diamondburned/arikawa/internal/json"
+)
+
+func TestFilePersister(t *testing.T) {
+	dir, err := ioutil.TempDir("", "arikawa-persist")
+	if err != nil {
+		t.Fatal("Failed to create temp dir:", err)
+	}
+	defer os.RemoveAll(dir)
+
+	p := NewFilePersister(filepath.Join(dir, "session.json"))
+
+	s, err := p.Load()
+	if err != nil || s != nil {
+		t.Fatal("Unexpected state before Save:", s, err)
+	}
+
+	if err := p.Save(ResumeState{SessionID: "abc", Sequence: 42}); err != nil {
+		t.Fatal("Failed to save:", err)
+	}
+
+	s, err = p.Load()
+	if err != nil {
+		t.Fatal("Failed to load:", err)
+	}
+
+	if s == nil || s.SessionID != "abc" || s.Sequence != 42 {
+		t.Fatalf("Unexpected state: %#v", s)
+	}
+
+	if err := p.Clear(); err != nil {
+		t.Fatal("Failed to clear:", err)
+	}
+
+	// Clearing twice should be fine.
+	if err := p.Clear(); err != nil {
+		t.Fatal("Failed to clear again:", err)
+	}
+
+	if s, err := p.Load(); err != nil || s != nil {
+		t.Fatal("Unexpected state after Clear:", s, err)
+	}
+}
+
+type memoryPersister struct {
+	state *ResumeState
+	saves int
+}
+
+func (p *memoryPersister) Load() (*ResumeState, error) { return p.state, nil }
+func (p *memoryPersister) Clear() error                { p.state = nil; return nil }
+
+func (p *memoryPersister) Save(s ResumeState) error {
+	p.state = &s
+	p.saves++
+	return nil
+}
+
+func TestGatewayPersister(t *testing.T) {
+	g, err := NewCustomGateway("wss://gateway.discord.gg", "Bot token", json.Default{})
+	if err != nil {
+		t.Fatal("Failed to create Gateway:", err)
+	}
+
+	p := &memoryPersister{
+		state: &ResumeState{SessionID: "old", Sequence: 10},
+	}
+	g.Persister = p
+
+	g.loadResumeState()
+
+	if g.SessionID != "old" || g.Sequence.Get() != 10 {
+		t.Fatal("Resume state not loaded:", g.SessionID, g.Sequence.Get())
+	}
+
+	err = HandleOP(g, &OP{
+		Code:      DispatchOP,
+		Data:      json.Raw(`{"session_id":"new"}`),
+		Sequence:  1,
+		EventName: "READY",
+	})
+	if err != nil {
+		t.Fatal("Failed to handle Ready:", err)
+	}
+
+	<-g.Events
+
+	// Events shouldn't be saved as they come.
+	if p.saves != 0 {
+		t.Fatal("State was saved on dispatch")
+	}
+
+	g.saveResumeState()
+
+	if p.state == nil || p.state.SessionID != "new" || p.state.Sequence != 1 {
+		t.Fatalf("Unexpected saved state: %#v", p.state)
+	}
+
+	// An unchanged state shouldn't be saved again.
+	g.saveResumeState()
+
+	if p.saves != 1 {
+		t.Fatal("Unchanged state was saved again:", p.saves)
+	}
+
+	g.resetSession()
+
+	if g.SessionID != "" || g.Sequence.Get() != 0 || p.state != nil {
+		t.Fatal("Session not reset")
+	}
+
+	// A reset session shouldn't be saved.
+	g.saveResumeState()
+
+	if p.state != nil {
+		t.Fatal("Empty session was saved")
+	}
+}
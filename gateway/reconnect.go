@@ -0,0 +1,72 @@
+package gateway
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// ReconnectPolicy controls how many times and how often Open retries
+// connecting to the Gateway.
+type ReconnectPolicy struct {
+	// MaxAttempts is the number of tries before Open gives up with
+	// ErrWSMaxTries. 0 or less retries forever.
+	MaxAttempts int
+
+	// BaseDelay is the delay before the first retry, which doubles for every
+	// retry after, up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+// DefaultReconnectPolicy is the default policy for new Gateways. It retries
+// forever, like before ReconnectPolicy was added.
+var DefaultReconnectPolicy = ReconnectPolicy{
+	MaxAttempts: 0,
+	BaseDelay:   time.Second,
+	MaxDelay:    2 * time.Minute,
+}
+
+// Delay returns the delay before the given retry, starting from 0. Half of the
+// delay is random, so that shards don't reconnect all at once.
+func (p ReconnectPolicy) Delay(retry int) time.Duration {
+	d := p.BaseDelay
+	if d <= 0 {
+		return 0
+	}
+
+	for i := 0; i < retry && (p.MaxDelay <= 0 || d < p.MaxDelay); i++ {
+		// Stop before the delay overflows without a MaxDelay.
+		if d > math.MaxInt64/2 {
+			d = math.MaxInt64
+			break
+		}
+
+		d *= 2
+	}
+
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// maxTriesError is returned by OpenContext when MaxAttempts is reached. It
+// wraps the error of the last try, and matches ErrWSMaxTries with errors.Is.
+type maxTriesError struct {
+	err error
+}
+
+func (err *maxTriesError) Error() string {
+	return ErrWSMaxTries.Error() + ": " + err.err.Error()
+}
+
+func (err *maxTriesError) Is(target error) bool {
+	return target == ErrWSMaxTries
+}
+
+func (err *maxTriesError) Unwrap() error {
+	return err.err
+}
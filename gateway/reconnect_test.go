@@ -0,0 +1,183 @@
+// +build unit
+
+package gateway
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/diamondburned/arikawa/internal/json"
+	"github.com/pkg/errors"
+	"golang.org/x/time/rate"
+	"nhooyr.io/websocket"
+)
+
+func TestReconnectPolicyDelay(t *testing.T) {
+	p := ReconnectPolicy{
+		BaseDelay: time.Second,
+		MaxDelay:  10 * time.Second,
+	}
+
+	var tests = []struct {
+		retry    int
+		min, max time.Duration
+	}{
+		{0, 500 * time.Millisecond, time.Second},
+		{1, time.Second, 2 * time.Second},
+		{2, 2 * time.Second, 4 * time.Second},
+		{10, 5 * time.Second, 10 * time.Second},
+		{1000, 5 * time.Second, 10 * time.Second},
+	}
+
+	for _, test := range tests {
+		for i := 0; i < 100; i++ {
+			d := p.Delay(test.retry)
+			if d < test.min || d > test.max {
+				t.Fatalf("Delay %v for retry %d not within [%v, %v]",
+					d, test.retry, test.min, test.max)
+			}
+		}
+	}
+
+	if d := (ReconnectPolicy{}).Delay(5); d != 0 {
+		t.Fatal("Unexpected delay without BaseDelay:", d)
+	}
+}
+
+// newUnreachableGateway returns a Gateway pointing to a closed port, with no
+// dial limits.
+func newUnreachableGateway(t *testing.T) *Gateway {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("Failed to listen:", err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	g, err := NewCustomGateway("ws://"+addr, "Bot token", json.Default{})
+	if err != nil {
+		t.Fatal("Failed to create Gateway:", err)
+	}
+
+	g.WS.DialLimiter = rate.NewLimiter(rate.Inf, 1)
+	g.ErrorLog = func(error) {}
+
+	return g
+}
+
+func TestOpenContextMaxTries(t *testing.T) {
+	g := newUnreachableGateway(t)
+	g.ReconnectPolicy = ReconnectPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   time.Millisecond,
+		MaxDelay:    time.Millisecond,
+	}
+
+	var errs int
+	g.ErrorLog = func(error) { errs++ }
+
+	err := g.OpenContext(context.Background())
+	if !errors.Is(err, ErrWSMaxTries) {
+		t.Fatal("Unexpected error:", err)
+	}
+
+	if errs != 3 {
+		t.Fatal("Unexpected number of attempts:", errs)
+	}
+}
+
+func TestOpenContextCancel(t *testing.T) {
+	g := newUnreachableGateway(t)
+	g.ReconnectPolicy = ReconnectPolicy{
+		BaseDelay: time.Hour,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	done := make(chan error)
+	go func() { done <- g.OpenContext(ctx) }()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatal("Unexpected error:", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("OpenContext didn't return after the context expired")
+	}
+}
+
+func TestReconnectPolicyDelayOverflow(t *testing.T) {
+	p := ReconnectPolicy{BaseDelay: time.Second}
+
+	for _, retry := range []int{40, 63, 64, 1000} {
+		if d := p.Delay(retry); d <= 0 {
+			t.Fatalf("Delay %v for retry %d overflowed", d, retry)
+		}
+	}
+}
+
+func TestOpenContextHelloCancel(t *testing.T) {
+	// The server never sends Hello.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			t.Error("Failed to accept:", err)
+			return
+		}
+		defer c.Close(websocket.StatusInternalError, "")
+
+		// Read until the client closes the connection.
+		for {
+			if _, _, err := c.Read(r.Context()); err != nil {
+				return
+			}
+		}
+	}))
+	defer srv.Close()
+
+	g := newTestGatewayClient(t, strings.Replace(srv.URL, "http://", "ws://", 1))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	done := make(chan error)
+	go func() { done <- g.OpenContext(ctx) }()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatal("Unexpected error:", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("OpenContext didn't return while waiting for Hello")
+	}
+}
+
+func TestOpenContextMaxTriesCloseError(t *testing.T) {
+	closeUnknown := func(c *websocket.Conn, op *OP) {
+		c.Close(websocket.StatusCode(UnknownErrorCode), "")
+	}
+
+	srv := newTestGateway(t, closeUnknown, closeUnknown, closeUnknown)
+	defer srv.Close()
+
+	g := newTestGatewayClient(t, srv.URL())
+	g.ErrorLog = func(error) {}
+
+	err := g.OpenContext(context.Background())
+	if !errors.Is(err, ErrWSMaxTries) {
+		t.Fatal("Unexpected error:", err)
+	}
+
+	var closeErr *CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != UnknownErrorCode {
+		t.Fatal("Last error is not a CloseError:", err)
+	}
+}
@@ -1,5 +1,15 @@
 package gateway
 
+import (
+	"sync"
+	"time"
+
+	"github.com/diamondburned/arikawa/discord"
+	"github.com/diamondburned/arikawa/internal/json"
+	"github.com/pkg/errors"
+	"golang.org/x/time/rate"
+)
+
 type Shard [2]int
 
 func DefaultShard() *Shard {
@@ -14,3 +24,195 @@ func (s Shard) ShardID() int {
 func (s Shard) NumShards() int {
 	return s[1]
 }
+
+// ShardID returns the ID of the shard that receives events for the given
+// guild, out of numShards.
+func ShardID(guildID discord.Snowflake, numShards int) int {
+	if numShards < 1 {
+		return 0
+	}
+
+	return int((uint64(guildID) >> 22) % uint64(numShards))
+}
+
+// ShardManager manages a Gateway for each shard. All shards send their events
+// into the same Events channel.
+//
+// Shards are identified in buckets of max_concurrency from the session start
+// limit: shards in the same bucket identify one after another, while the
+// buckets identify concurrently.
+type ShardManager struct {
+	Shards []*Gateway
+
+	// Events receives events from all shards. This channel should be read
+	// from while Open is running, as the shards that are already opened will
+	// send their events here.
+	Events chan Event
+
+	ErrorLog func(err error) // default to log.Println
+	FatalLog func(err error) // called when a shard can't reconnect and resume
+
+	maxConcurrency int
+}
+
+// NewShardManager creates a new ShardManager with the number of shards
+// recommended by Discord and the default stdlib JSON driver. The token has to
+// be prefixed with "Bot ".
+func NewShardManager(token string) (*ShardManager, error) {
+	return NewShardManagerWithDriver(token, json.Default{})
+}
+
+// NewShardManagerWithDriver creates a new ShardManager with the number of
+// shards recommended by Discord.
+func NewShardManagerWithDriver(
+	token string, driver json.Driver) (*ShardManager, error) {
+
+	d, err := BotURL(token)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to get bot gateway endpoint")
+	}
+
+	return NewCustomShardManager(*d, token, driver)
+}
+
+// NewCustomShardManager creates a new ShardManager from the given BotData. The
+// number of shards is taken from data.Shards. If data.StartLimit is nil, only
+// one shard is identified at a time.
+func NewCustomShardManager(
+	data BotData, token string, driver json.Driver) (*ShardManager, error) {
+
+	if data.Shards < 1 {
+		data.Shards = 1
+	}
+
+	m := &ShardManager{
+		Shards:         make([]*Gateway, data.Shards),
+		Events:         make(chan Event, WSBuffer*data.Shards),
+		ErrorLog:       WSError,
+		FatalLog:       WSFatal,
+		maxConcurrency: 1,
+	}
+
+	global := rate.NewLimiter(rate.Every(24*time.Hour), 1000)
+
+	if l := data.StartLimit; l != nil {
+		if l.MaxConcurrency > 1 {
+			m.maxConcurrency = l.MaxConcurrency
+		}
+
+		if l.Total > 0 {
+			global = rate.NewLimiter(
+				rate.Every(24*time.Hour/time.Duration(l.Total)), l.Total)
+
+			// Take away the sessions that were already started today.
+			if used := l.Total - l.Remaining; used > 0 {
+				global.ReserveN(time.Now(), used)
+			}
+		}
+	}
+
+	buckets := make([]*rate.Limiter, m.maxConcurrency)
+	for i := range buckets {
+		buckets[i] = rate.NewLimiter(rate.Every(5*time.Second), 1)
+	}
+
+	for id := range m.Shards {
+		g, err := NewCustomGateway(data.URL, token, driver)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to create shard %d", id)
+		}
+
+		g.Events = m.Events
+		g.Identifier.SetShard(id, data.Shards)
+		g.Identifier.IdentifyShortLimit = buckets[id%m.maxConcurrency]
+		g.Identifier.IdentifyGlobalLimit = global
+
+		id := id
+		g.ErrorLog = func(err error) {
+			m.ErrorLog(errors.Wrapf(err, "Shard %d", id))
+		}
+		g.FatalLog = func(err error) {
+			m.FatalLog(errors.Wrapf(err, "Shard %d", id))
+		}
+
+		m.Shards[id] = g
+	}
+
+	return m, nil
+}
+
+// MaxConcurrency returns the number of shards that can identify at the same
+// time.
+func (m *ShardManager) MaxConcurrency() int {
+	return m.maxConcurrency
+}
+
+// ShardID returns the ID of the shard that receives events for the given
+// guild.
+func (m *ShardManager) ShardID(guildID discord.Snowflake) int {
+	return ShardID(guildID, len(m.Shards))
+}
+
+// ForGuild returns the shard that receives events for the given guild. Guild
+// specific commands, such as RequestGuildMembers, should be sent over this
+// shard.
+func (m *ShardManager) ForGuild(guildID discord.Snowflake) *Gateway {
+	return m.Shards[m.ShardID(guildID)]
+}
+
+// Open opens all shards. It returns after all shards have been opened, which
+// could take a while for bots with many shards.
+func (m *ShardManager) Open() error {
+	var wg sync.WaitGroup
+	var opened = make([]bool, len(m.Shards))
+	var errs = make([]error, len(m.Shards))
+
+	for bucket := 0; bucket < m.maxConcurrency; bucket++ {
+		wg.Add(1)
+
+		go func(bucket int) {
+			defer wg.Done()
+
+			for id := bucket; id < len(m.Shards); id += m.maxConcurrency {
+				if err := m.Shards[id].Open(); err != nil {
+					errs[id] = errors.Wrapf(err, "Failed to open shard %d", id)
+					return
+				}
+
+				opened[id] = true
+			}
+		}(bucket)
+	}
+
+	wg.Wait()
+
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+
+		// Close the shards that were opened.
+		for id, ok := range opened {
+			if ok {
+				m.Shards[id].Close()
+			}
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// Close closes all shards. The first error is returned.
+func (m *ShardManager) Close() error {
+	var err error
+
+	for id, g := range m.Shards {
+		if gerr := g.Close(); gerr != nil && err == nil {
+			err = errors.Wrapf(gerr, "Failed to close shard %d", id)
+		}
+	}
+
+	return err
+}
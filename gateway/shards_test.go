@@ -0,0 +1,83 @@
+// +build unit
+
+package gateway
+
+import (
+	"testing"
+
+	"github.com/diamondburned/arikawa/discord"
+	"github.com/diamondburned/arikawa/internal/json"
+)
+
+func TestShardID(t *testing.T) {
+	var tests = []struct {
+		guildID discord.Snowflake
+		shards  int
+		shardID int
+	}{
+		{0, 1, 0},
+		{1 << 22, 2, 1},
+		{2 << 22, 2, 0},
+		{7<<22 | 12345, 5, 2},
+		{7 << 22, 0, 0},
+	}
+
+	for _, test := range tests {
+		if id := ShardID(test.guildID, test.shards); id != test.shardID {
+			t.Errorf("Unexpected shard %d for guild %d out of %d, expected %d",
+				id, test.guildID, test.shards, test.shardID)
+		}
+	}
+}
+
+func TestNewCustomShardManager(t *testing.T) {
+	m, err := NewCustomShardManager(BotData{
+		URL:    "wss://gateway.discord.gg",
+		Shards: 4,
+		StartLimit: &SessionStartLimit{
+			Total:          1000,
+			Remaining:      999,
+			MaxConcurrency: 2,
+		},
+	}, "Bot token", json.Default{})
+	if err != nil {
+		t.Fatal("Failed to create ShardManager:", err)
+	}
+
+	if len(m.Shards) != 4 {
+		t.Fatal("Unexpected number of shards:", len(m.Shards))
+	}
+
+	if m.MaxConcurrency() != 2 {
+		t.Fatal("Unexpected max concurrency:", m.MaxConcurrency())
+	}
+
+	for id, g := range m.Shards {
+		if g.Events != m.Events {
+			t.Errorf("Shard %d doesn't send into the shared Events", id)
+		}
+
+		if s := *g.Identifier.Shard; s.ShardID() != id || s.NumShards() != 4 {
+			t.Errorf("Unexpected shard %v for shard %d", s, id)
+		}
+
+		// Shards in the same bucket share the same limiter.
+		bucket := m.Shards[id%2].Identifier.IdentifyShortLimit
+		if g.Identifier.IdentifyShortLimit != bucket {
+			t.Errorf("Shard %d doesn't share the limiter of bucket %d", id, id%2)
+		}
+
+		global := m.Shards[0].Identifier.IdentifyGlobalLimit
+		if g.Identifier.IdentifyGlobalLimit != global {
+			t.Errorf("Shard %d doesn't share the global limiter", id)
+		}
+	}
+
+	if m.Shards[0].Identifier.IdentifyShortLimit == m.Shards[1].Identifier.IdentifyShortLimit {
+		t.Error("Shards in different buckets share the same limiter")
+	}
+
+	if g := m.ForGuild(3 << 22); g != m.Shards[3] {
+		t.Error("Guild is not routed to shard 3")
+	}
+}
@@ -113,6 +113,23 @@ func (h *Handler) ChanFor(fn func(interface{}) bool) <-chan interface{} {
 	return recv
 }
 
+// EventTypes returns the argument types of all added handlers, in the order
+// they were added. These are either pointers to events or interfaces.
+func (h *Handler) EventTypes() []reflect.Type {
+	h.hmutex.RLock()
+	defer h.hmutex.RUnlock()
+
+	var types = make([]reflect.Type, 0, len(h.horders))
+
+	for _, order := range h.horders {
+		if handler, ok := h.handlers[order]; ok {
+			types = append(types, handler.event)
+		}
+	}
+
+	return types
+}
+
 func (h *Handler) AddHandler(handler interface{}) (rm func()) {
 	rm, err := h.addHandler(handler)
 	if err != nil {
@@ -235,3 +235,23 @@ func BenchmarkReflect(b *testing.B) {
 		h.call(msgV)
 	}
 }
+
+func TestEventTypes(t *testing.T) {
+	h := New()
+
+	h.AddHandler(func(*gateway.MessageCreateEvent) {})
+	rm := h.AddHandler(func(*gateway.ReadyEvent) {})
+	h.AddHandler(func(interface{}) {})
+	rm()
+
+	types := h.EventTypes()
+
+	expect := []reflect.Type{
+		reflect.TypeOf((*gateway.MessageCreateEvent)(nil)),
+		reflect.TypeOf((*interface{})(nil)).Elem(),
+	}
+
+	if !reflect.DeepEqual(types, expect) {
+		t.Fatalf("Unexpected types: %v", types)
+	}
+}
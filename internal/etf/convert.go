@@ -0,0 +1,443 @@
+package etf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"math/big"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	errUnexpectedEnd = errors.New("Unexpected end of term")
+	errArityTooLarge = errors.New("Term arity is larger than the remaining data")
+)
+
+// reader reads the parts of a term.
+type reader struct {
+	data []byte
+}
+
+func (r *reader) read(n int) ([]byte, error) {
+	if n < 0 || len(r.data) < n {
+		return nil, errUnexpectedEnd
+	}
+
+	b := r.data[:n]
+	r.data = r.data[n:]
+
+	return b, nil
+}
+
+func (r *reader) uint8() (int, error) {
+	b, err := r.read(1)
+	if err != nil {
+		return 0, err
+	}
+	return int(b[0]), nil
+}
+
+func (r *reader) uint16() (int, error) {
+	b, err := r.read(2)
+	if err != nil {
+		return 0, err
+	}
+	return int(binary.BigEndian.Uint16(b)), nil
+}
+
+func (r *reader) uint32() (int, error) {
+	b, err := r.read(4)
+	if err != nil {
+		return 0, err
+	}
+	return int(binary.BigEndian.Uint32(b)), nil
+}
+
+// arity checks the arity of a list, tuple or map before anything is
+// allocated for it. Every element takes at least a byte, so an arity larger
+// than the remaining data can only come from a malformed term.
+func (r *reader) arity(n int) error {
+	if n < 0 || n > len(r.data) {
+		return errArityTooLarge
+	}
+	return nil
+}
+
+// converter writes terms as JSON.
+type converter struct {
+	reader
+}
+
+func (d *converter) term(buf *bytes.Buffer) error {
+	tag, err := d.uint8()
+	if err != nil {
+		return err
+	}
+
+	switch tag {
+	case smallIntegerExt:
+		i, err := d.uint8()
+		if err != nil {
+			return err
+		}
+		buf.WriteString(strconv.Itoa(i))
+
+	case integerExt:
+		b, err := d.read(4)
+		if err != nil {
+			return err
+		}
+		buf.WriteString(strconv.Itoa(int(int32(binary.BigEndian.Uint32(b)))))
+
+	case newFloatExt:
+		b, err := d.read(8)
+		if err != nil {
+			return err
+		}
+		return writeFloat(buf, math.Float64frombits(binary.BigEndian.Uint64(b)))
+
+	case floatExt:
+		b, err := d.read(31)
+		if err != nil {
+			return err
+		}
+
+		// The float is a null-padded string.
+		if i := bytes.IndexByte(b, 0); i > -1 {
+			b = b[:i]
+		}
+
+		f, err := strconv.ParseFloat(string(bytes.TrimSpace(b)), 64)
+		if err != nil {
+			return errors.Wrap(err, "Failed to parse float")
+		}
+		return writeFloat(buf, f)
+
+	case smallBigExt:
+		n, err := d.uint8()
+		if err != nil {
+			return err
+		}
+		return d.big(buf, n)
+
+	case largeBigExt:
+		n, err := d.uint32()
+		if err != nil {
+			return err
+		}
+		return d.big(buf, n)
+
+	case atomExt, atomUTF8Ext:
+		n, err := d.uint16()
+		if err != nil {
+			return err
+		}
+		return d.atom(buf, n)
+
+	case smallAtomExt, smallAtomUTF8Ext:
+		n, err := d.uint8()
+		if err != nil {
+			return err
+		}
+		return d.atom(buf, n)
+
+	case binaryExt:
+		n, err := d.uint32()
+		if err != nil {
+			return err
+		}
+
+		b, err := d.read(n)
+		if err != nil {
+			return err
+		}
+		writeString(buf, b)
+
+	case stringExt:
+		// Strings are lists of small integers.
+		n, err := d.uint16()
+		if err != nil {
+			return err
+		}
+
+		b, err := d.read(n)
+		if err != nil {
+			return err
+		}
+
+		buf.WriteByte('[')
+		for i, c := range b {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			buf.WriteString(strconv.Itoa(int(c)))
+		}
+		buf.WriteByte(']')
+
+	case nilExt:
+		buf.WriteString("[]")
+
+	case listExt:
+		n, err := d.uint32()
+		if err != nil {
+			return err
+		}
+
+		if err := d.array(buf, n); err != nil {
+			return err
+		}
+
+		// Proper lists end with an empty list, which is dropped.
+		if len(d.data) > 0 && d.data[0] == nilExt {
+			d.data = d.data[1:]
+			return nil
+		}
+
+		return errors.New("Improper lists are not supported")
+
+	case smallTupleExt:
+		n, err := d.uint8()
+		if err != nil {
+			return err
+		}
+		return d.array(buf, n)
+
+	case largeTupleExt:
+		n, err := d.uint32()
+		if err != nil {
+			return err
+		}
+		return d.array(buf, n)
+
+	case mapExt:
+		n, err := d.uint32()
+		if err != nil {
+			return err
+		}
+		return d.object(buf, n)
+
+	case compressedExt:
+		size, err := d.uint32()
+		if err != nil {
+			return err
+		}
+
+		z, err := zlib.NewReader(bytes.NewReader(d.data))
+		if err != nil {
+			return errors.Wrap(err, "Failed to read compressed term")
+		}
+
+		b, err := ioutil.ReadAll(z)
+		if err != nil {
+			return errors.Wrap(err, "Failed to inflate compressed term")
+		}
+
+		if len(b) != size {
+			return errors.New("Compressed term has the wrong size")
+		}
+
+		// The compressed term is the rest of the data.
+		d.data = b
+		return d.term(buf)
+
+	default:
+		return fmt.Errorf("Unsupported term tag %d", tag)
+	}
+
+	return nil
+}
+
+func (d *converter) array(buf *bytes.Buffer, n int) error {
+	buf.WriteByte('[')
+
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+
+		if err := d.term(buf); err != nil {
+			return err
+		}
+	}
+
+	buf.WriteByte(']')
+	return nil
+}
+
+func (d *converter) object(buf *bytes.Buffer, n int) error {
+	buf.WriteByte('{')
+
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+
+		if err := d.key(buf); err != nil {
+			return errors.Wrap(err, "Failed to decode map key")
+		}
+
+		buf.WriteByte(':')
+
+		if err := d.term(buf); err != nil {
+			return err
+		}
+	}
+
+	buf.WriteByte('}')
+	return nil
+}
+
+// key writes a map key, which has to be a string in JSON. Atoms, binaries and
+// integers are supported.
+func (d *converter) key(buf *bytes.Buffer) error {
+	if len(d.data) == 0 {
+		return errUnexpectedEnd
+	}
+
+	switch tag := d.data[0]; tag {
+	case atomExt, atomUTF8Ext, smallAtomExt, smallAtomUTF8Ext:
+		d.data = d.data[1:]
+
+		var n int
+		var err error
+
+		if tag == atomExt || tag == atomUTF8Ext {
+			n, err = d.uint16()
+		} else {
+			n, err = d.uint8()
+		}
+		if err != nil {
+			return err
+		}
+
+		// Atoms such as nil are written as their names rather than as JSON
+		// literals, as keys have to be strings.
+		b, err := d.read(n)
+		if err != nil {
+			return err
+		}
+
+		writeString(buf, b)
+		return nil
+
+	case binaryExt:
+		return d.term(buf)
+
+	case smallIntegerExt, integerExt, smallBigExt, largeBigExt:
+		var key bytes.Buffer
+		if err := d.term(&key); err != nil {
+			return err
+		}
+
+		writeString(buf, key.Bytes())
+		return nil
+
+	default:
+		return fmt.Errorf("Unsupported map key tag %d", d.data[0])
+	}
+}
+
+func (d *converter) atom(buf *bytes.Buffer, n int) error {
+	b, err := d.read(n)
+	if err != nil {
+		return err
+	}
+
+	switch string(b) {
+	case "nil", "null":
+		buf.WriteString("null")
+	case "true":
+		buf.WriteString("true")
+	case "false":
+		buf.WriteString("false")
+	default:
+		writeString(buf, b)
+	}
+
+	return nil
+}
+
+// big writes a little-endian big integer with n digits. Snowflakes are sent
+// this way.
+func (d *converter) big(buf *bytes.Buffer, n int) error {
+	sign, err := d.uint8()
+	if err != nil {
+		return err
+	}
+
+	b, err := d.read(n)
+	if err != nil {
+		return err
+	}
+
+	if n <= 8 {
+		var u uint64
+		for i := n - 1; i >= 0; i-- {
+			u = u<<8 | uint64(b[i])
+		}
+
+		if sign != 0 {
+			buf.WriteByte('-')
+		}
+		buf.WriteString(strconv.FormatUint(u, 10))
+		return nil
+	}
+
+	// Reverse the digits into big-endian for big.Int.
+	be := make([]byte, n)
+	for i := range b {
+		be[n-1-i] = b[i]
+	}
+
+	i := new(big.Int).SetBytes(be)
+	if sign != 0 {
+		i.Neg(i)
+	}
+
+	buf.WriteString(i.String())
+	return nil
+}
+
+func writeFloat(buf *bytes.Buffer, f float64) error {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return errors.New("Unsupported float value " + strconv.FormatFloat(f, 'g', -1, 64))
+	}
+
+	buf.WriteString(strconv.FormatFloat(f, 'g', -1, 64))
+	return nil
+}
+
+const hex = "0123456789abcdef"
+
+// writeString writes b as a JSON string.
+func writeString(buf *bytes.Buffer, b []byte) {
+	buf.WriteByte('"')
+
+	for len(b) > 0 {
+		r, size := utf8.DecodeRune(b)
+
+		switch {
+		case r == '"' || r == '\\':
+			buf.WriteByte('\\')
+			buf.WriteByte(byte(r))
+		case r < 0x20:
+			buf.WriteString(`\u00`)
+			buf.WriteByte(hex[r>>4])
+			buf.WriteByte(hex[r&0xF])
+		case r == utf8.RuneError && size == 1:
+			buf.WriteString(`\ufffd`)
+		default:
+			buf.Write(b[:size])
+		}
+
+		b = b[size:]
+	}
+
+	buf.WriteByte('"')
+}
@@ -0,0 +1,981 @@
+package etf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding"
+	"encoding/base64"
+	"encoding/binary"
+	stdjson "encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"math/big"
+	"reflect"
+	"strconv"
+
+	"github.com/diamondburned/arikawa/internal/json"
+	"github.com/pkg/errors"
+)
+
+var (
+	unmarshalerType     = reflect.TypeOf((*stdjson.Unmarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// decodeState decodes terms directly into values, following the rules of
+// encoding/json. Types that implement json.Unmarshaler are given the term
+// converted into JSON, except for json.Raw, which keeps the term as-is.
+type decodeState struct {
+	reader
+
+	// buf holds the JSON given to Unmarshalers. It's reused, as Unmarshalers
+	// have to copy the data they keep.
+	buf bytes.Buffer
+	// err is the first type mismatch. Decoding continues after one, like in
+	// encoding/json.
+	err error
+}
+
+// unmarshal decodes a term, starting with Version, into v.
+func unmarshal(data []byte, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return &stdjson.InvalidUnmarshalError{Type: reflect.TypeOf(v)}
+	}
+
+	if len(data) == 0 || data[0] != Version {
+		return ErrNotETF
+	}
+
+	data, err := inflate(data[1:])
+	if err != nil {
+		return err
+	}
+
+	d := decodeState{reader: reader{data: data}}
+
+	if err := d.value(rv); err != nil {
+		return err
+	}
+
+	if len(d.data) > 0 {
+		return errors.New("Trailing data after term")
+	}
+
+	return d.err
+}
+
+// inflate returns the compressed term inside data, or data if it's not
+// compressed.
+func inflate(data []byte) ([]byte, error) {
+	if len(data) == 0 || data[0] != compressedExt {
+		return data, nil
+	}
+
+	r := reader{data: data[1:]}
+
+	size, err := r.uint32()
+	if err != nil {
+		return nil, err
+	}
+
+	z, err := zlib.NewReader(bytes.NewReader(r.data))
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to read compressed term")
+	}
+
+	// Don't inflate past the size the term claims to have.
+	b, err := ioutil.ReadAll(io.LimitReader(z, int64(size)+1))
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to inflate compressed term")
+	}
+
+	if len(b) != size {
+		return nil, errors.New("Compressed term has the wrong size")
+	}
+
+	return b, nil
+}
+
+func (d *decodeState) saveError(err error) {
+	if d.err == nil {
+		d.err = err
+	}
+}
+
+func (d *decodeState) typeError(what string, t reflect.Type) {
+	d.saveError(&stdjson.UnmarshalTypeError{Value: what, Type: t})
+}
+
+// value decodes the next term into v. An invalid v skips the term.
+func (d *decodeState) value(v reflect.Value) error {
+	if len(d.data) == 0 {
+		return errUnexpectedEnd
+	}
+
+	if !v.IsValid() {
+		return d.skip()
+	}
+
+	null := d.null()
+
+	u, tu, v := indirect(v, null)
+	if u != nil {
+		return d.unmarshaler(u)
+	}
+
+	if tu != nil {
+		if d.data[0] != binaryExt {
+			d.typeError("term", reflect.TypeOf(tu))
+			return d.skip()
+		}
+
+		b, err := d.binary()
+		if err != nil {
+			return err
+		}
+
+		return tu.UnmarshalText(b)
+	}
+
+	if null {
+		switch v.Kind() {
+		case reflect.Interface, reflect.Ptr, reflect.Map, reflect.Slice:
+			v.Set(reflect.Zero(v.Type()))
+		}
+
+		return d.skip()
+	}
+
+	switch tag := d.data[0]; tag {
+	case smallIntegerExt, integerExt, smallBigExt, largeBigExt:
+		return d.integer(v)
+
+	case newFloatExt, floatExt:
+		return d.float(v)
+
+	case atomExt, atomUTF8Ext, smallAtomExt, smallAtomUTF8Ext:
+		return d.atom(v)
+
+	case binaryExt:
+		return d.string(v)
+
+	case stringExt:
+		return d.byteList(v)
+
+	case nilExt, listExt, smallTupleExt, largeTupleExt:
+		return d.list(v)
+
+	case mapExt:
+		return d.object(v)
+
+	default:
+		return fmt.Errorf("Unsupported term tag %d", tag)
+	}
+}
+
+// indirect walks down v, allocating pointers as needed, until it gets to a
+// non-pointer. If it finds an Unmarshaler or a TextUnmarshaler, it stops and
+// returns that. If null is true, it stops at the last pointer so it can be set
+// to nil. This is taken from encoding/json.
+func indirect(v reflect.Value, null bool) (
+	stdjson.Unmarshaler, encoding.TextUnmarshaler, reflect.Value) {
+
+	v0 := v
+	haveAddr := false
+
+	// If v is a named type and is addressable, start with its address, so
+	// that if the type has pointer methods, we find them.
+	if v.Kind() != reflect.Ptr && v.Type().Name() != "" && v.CanAddr() {
+		haveAddr = true
+		v = v.Addr()
+	}
+
+	for {
+		// Load value from interface, but only if the result will be usefully
+		// addressable.
+		if v.Kind() == reflect.Interface && !v.IsNil() {
+			e := v.Elem()
+			if e.Kind() == reflect.Ptr && !e.IsNil() && (!null || e.Elem().Kind() == reflect.Ptr) {
+				haveAddr = false
+				v = e
+				continue
+			}
+		}
+
+		if v.Kind() != reflect.Ptr {
+			break
+		}
+
+		if null && v.CanSet() {
+			break
+		}
+
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+
+		if v.Type().NumMethod() > 0 && v.CanInterface() {
+			if u, ok := v.Interface().(stdjson.Unmarshaler); ok {
+				return u, nil, reflect.Value{}
+			}
+			if !null {
+				if u, ok := v.Interface().(encoding.TextUnmarshaler); ok {
+					return nil, u, reflect.Value{}
+				}
+			}
+		}
+
+		if haveAddr {
+			v = v0 // restore original value after round-trip Value.Addr().Elem()
+			haveAddr = false
+		} else {
+			v = v.Elem()
+		}
+	}
+
+	return nil, nil, v
+}
+
+// null returns true if the next term is the nil atom.
+func (d *decodeState) null() bool {
+	var name []byte
+
+	switch {
+	case len(d.data) > 3 && (d.data[0] == atomExt || d.data[0] == atomUTF8Ext):
+		n := int(binary.BigEndian.Uint16(d.data[1:3]))
+		if len(d.data) < 3+n {
+			return false
+		}
+		name = d.data[3 : 3+n]
+
+	case len(d.data) > 2 && (d.data[0] == smallAtomExt || d.data[0] == smallAtomUTF8Ext):
+		n := int(d.data[1])
+		if len(d.data) < 2+n {
+			return false
+		}
+		name = d.data[2 : 2+n]
+
+	default:
+		return false
+	}
+
+	return string(name) == "nil" || string(name) == "null"
+}
+
+func (d *decodeState) unmarshaler(u stdjson.Unmarshaler) error {
+	// Raw values keep the term, so they could be decoded later without going
+	// through JSON.
+	if raw, ok := u.(*json.Raw); ok {
+		start := d.data
+
+		if err := d.skip(); err != nil {
+			return err
+		}
+
+		term := start[:len(start)-len(d.data)]
+		*raw = append(append((*raw)[:0], Version), term...)
+		return nil
+	}
+
+	c := converter{reader{data: d.data}}
+	d.buf.Reset()
+
+	if err := c.term(&d.buf); err != nil {
+		return err
+	}
+
+	d.data = c.data
+	return u.UnmarshalJSON(d.buf.Bytes())
+}
+
+// number is an integer term.
+type number struct {
+	neg bool
+	abs uint64
+	big *big.Int // non-nil if abs overflows
+}
+
+func (n number) float() float64 {
+	if n.big != nil {
+		f, _ := new(big.Float).SetInt(n.big).Float64()
+		return f
+	}
+
+	if n.neg {
+		return -float64(n.abs)
+	}
+	return float64(n.abs)
+}
+
+func (n number) int64() (int64, bool) {
+	if n.big != nil {
+		return 0, false
+	}
+
+	if n.neg {
+		return -int64(n.abs), n.abs <= 1<<63
+	}
+	return int64(n.abs), n.abs <= math.MaxInt64
+}
+
+func (d *decodeState) readInteger() (number, error) {
+	tag, err := d.uint8()
+	if err != nil {
+		return number{}, err
+	}
+
+	var n int
+
+	switch tag {
+	case smallIntegerExt:
+		i, err := d.uint8()
+		return number{abs: uint64(i)}, err
+
+	case integerExt:
+		b, err := d.read(4)
+		if err != nil {
+			return number{}, err
+		}
+
+		i := int64(int32(binary.BigEndian.Uint32(b)))
+		if i < 0 {
+			return number{neg: true, abs: uint64(-i)}, nil
+		}
+		return number{abs: uint64(i)}, nil
+
+	case smallBigExt:
+		n, err = d.uint8()
+	case largeBigExt:
+		n, err = d.uint32()
+	}
+
+	if err != nil {
+		return number{}, err
+	}
+
+	sign, err := d.uint8()
+	if err != nil {
+		return number{}, err
+	}
+
+	b, err := d.read(n)
+	if err != nil {
+		return number{}, err
+	}
+
+	num := number{neg: sign != 0}
+
+	// Digits are little-endian.
+	if n <= 8 {
+		for i := n - 1; i >= 0; i-- {
+			num.abs = num.abs<<8 | uint64(b[i])
+		}
+		return num, nil
+	}
+
+	be := make([]byte, n)
+	for i := range b {
+		be[n-1-i] = b[i]
+	}
+
+	num.big = new(big.Int).SetBytes(be)
+	if num.neg {
+		num.big.Neg(num.big)
+	}
+
+	return num, nil
+}
+
+func (d *decodeState) integer(v reflect.Value) error {
+	n, err := d.readInteger()
+	if err != nil {
+		return err
+	}
+
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, ok := n.int64()
+		if !ok || v.OverflowInt(i) {
+			d.typeError("number", v.Type())
+			break
+		}
+		v.SetInt(i)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if n.neg || n.big != nil || v.OverflowUint(n.abs) {
+			d.typeError("number", v.Type())
+			break
+		}
+		v.SetUint(n.abs)
+
+	case reflect.Float32, reflect.Float64:
+		v.SetFloat(n.float())
+
+	case reflect.Interface:
+		if v.NumMethod() != 0 {
+			d.typeError("number", v.Type())
+			break
+		}
+		v.Set(reflect.ValueOf(n.float()))
+
+	default:
+		d.typeError("number", v.Type())
+	}
+
+	return nil
+}
+
+func (d *decodeState) float(v reflect.Value) error {
+	tag, err := d.uint8()
+	if err != nil {
+		return err
+	}
+
+	var f float64
+
+	if tag == newFloatExt {
+		b, err := d.read(8)
+		if err != nil {
+			return err
+		}
+
+		f = math.Float64frombits(binary.BigEndian.Uint64(b))
+
+	} else {
+		b, err := d.read(31)
+		if err != nil {
+			return err
+		}
+
+		// The float is a null-padded string.
+		if i := bytes.IndexByte(b, 0); i > -1 {
+			b = b[:i]
+		}
+
+		f, err = strconv.ParseFloat(string(bytes.TrimSpace(b)), 64)
+		if err != nil {
+			return errors.Wrap(err, "Failed to parse float")
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Float32, reflect.Float64:
+		if v.OverflowFloat(f) {
+			d.typeError("number", v.Type())
+			break
+		}
+		v.SetFloat(f)
+
+	case reflect.Interface:
+		if v.NumMethod() != 0 {
+			d.typeError("number", v.Type())
+			break
+		}
+		v.Set(reflect.ValueOf(f))
+
+	default:
+		d.typeError("number", v.Type())
+	}
+
+	return nil
+}
+
+// readAtom reads the name of an atom.
+func (d *decodeState) readAtom() ([]byte, error) {
+	tag, err := d.uint8()
+	if err != nil {
+		return nil, err
+	}
+
+	var n int
+	if tag == atomExt || tag == atomUTF8Ext {
+		n, err = d.uint16()
+	} else {
+		n, err = d.uint8()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return d.read(n)
+}
+
+// atom decodes booleans. Other atoms are decoded as strings.
+func (d *decodeState) atom(v reflect.Value) error {
+	name, err := d.readAtom()
+	if err != nil {
+		return err
+	}
+
+	switch s := string(name); s {
+	case "true", "false":
+		switch {
+		case v.Kind() == reflect.Bool:
+			v.SetBool(s == "true")
+		case v.Kind() == reflect.Interface && v.NumMethod() == 0:
+			v.Set(reflect.ValueOf(s == "true"))
+		default:
+			d.typeError("bool", v.Type())
+		}
+
+	default:
+		d.setString(v, name)
+	}
+
+	return nil
+}
+
+func (d *decodeState) binary() ([]byte, error) {
+	if _, err := d.uint8(); err != nil {
+		return nil, err
+	}
+
+	n, err := d.uint32()
+	if err != nil {
+		return nil, err
+	}
+
+	return d.read(n)
+}
+
+func (d *decodeState) string(v reflect.Value) error {
+	b, err := d.binary()
+	if err != nil {
+		return err
+	}
+
+	d.setString(v, b)
+	return nil
+}
+
+func (d *decodeState) setString(v reflect.Value, b []byte) {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(string(b))
+
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.Uint8 {
+			d.typeError("string", v.Type())
+			break
+		}
+
+		// Bytes are encoded as base64 strings, like in encoding/json.
+		out := make([]byte, base64.StdEncoding.DecodedLen(len(b)))
+
+		n, err := base64.StdEncoding.Decode(out, b)
+		if err != nil {
+			d.saveError(err)
+			break
+		}
+
+		v.SetBytes(out[:n])
+
+	case reflect.Interface:
+		if v.NumMethod() != 0 {
+			d.typeError("string", v.Type())
+			break
+		}
+		v.Set(reflect.ValueOf(string(b)))
+
+	default:
+		d.typeError("string", v.Type())
+	}
+}
+
+// byteList decodes a string term, which Erlang uses for lists of small
+// integers. It's rare, so it's expanded into a proper list.
+func (d *decodeState) byteList(v reflect.Value) error {
+	if _, err := d.uint8(); err != nil {
+		return err
+	}
+
+	n, err := d.uint16()
+	if err != nil {
+		return err
+	}
+
+	b, err := d.read(n)
+	if err != nil {
+		return err
+	}
+
+	list := make([]byte, 0, 6+len(b)*2)
+	list = append(list, listExt)
+	list = append(list, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+	for _, c := range b {
+		list = append(list, smallIntegerExt, c)
+	}
+	list = append(list, nilExt)
+
+	sub := decodeState{reader: reader{data: list}}
+	if err := sub.list(v); err != nil {
+		return err
+	}
+
+	if sub.err != nil {
+		d.saveError(sub.err)
+	}
+
+	return nil
+}
+
+func (d *decodeState) list(v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+	case reflect.Interface:
+		if v.NumMethod() == 0 {
+			break
+		}
+		fallthrough
+	default:
+		d.typeError("array", v.Type())
+		return d.skip()
+	}
+
+	tag, err := d.uint8()
+	if err != nil {
+		return err
+	}
+
+	var n int
+
+	switch tag {
+	case listExt, largeTupleExt:
+		n, err = d.uint32()
+	case smallTupleExt:
+		n, err = d.uint8()
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if err := d.arity(n); err != nil {
+		return err
+	}
+
+	switch v.Kind() {
+	case reflect.Interface:
+		var list = make([]interface{}, n)
+
+		for i := range list {
+			if err := d.value(reflect.ValueOf(&list[i]).Elem()); err != nil {
+				return err
+			}
+		}
+
+		v.Set(reflect.ValueOf(list))
+
+	case reflect.Slice:
+		if v.IsNil() || v.Cap() < n {
+			v.Set(reflect.MakeSlice(v.Type(), n, n))
+		} else {
+			v.SetLen(n)
+		}
+
+		zero := reflect.Zero(v.Type().Elem())
+
+		for i := 0; i < n; i++ {
+			elem := v.Index(i)
+			elem.Set(zero)
+
+			if err := d.value(elem); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Array:
+		for i := 0; i < n; i++ {
+			var elem reflect.Value
+			if i < v.Len() {
+				elem = v.Index(i)
+			}
+
+			if err := d.value(elem); err != nil {
+				return err
+			}
+		}
+
+		zero := reflect.Zero(v.Type().Elem())
+		for i := n; i < v.Len(); i++ {
+			v.Index(i).Set(zero)
+		}
+	}
+
+	// Proper lists end with an empty list, which is dropped.
+	if tag == listExt {
+		if len(d.data) == 0 || d.data[0] != nilExt {
+			return errors.New("Improper lists are not supported")
+		}
+
+		d.data = d.data[1:]
+	}
+
+	return nil
+}
+
+func (d *decodeState) object(v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Struct:
+	case reflect.Map:
+		// Map keys are strings, so the key type has to be decodable from a
+		// string, like in encoding/json.
+		switch kt := v.Type().Key(); {
+		case reflect.PtrTo(kt).Implements(textUnmarshalerType):
+		case kt.Kind() == reflect.String:
+		case kt.Kind() >= reflect.Int && kt.Kind() <= reflect.Uintptr:
+		default:
+			d.typeError("object", v.Type())
+			return d.skip()
+		}
+	case reflect.Interface:
+		if v.NumMethod() == 0 {
+			break
+		}
+		fallthrough
+	default:
+		d.typeError("object", v.Type())
+		return d.skip()
+	}
+
+	if _, err := d.uint8(); err != nil {
+		return err
+	}
+
+	n, err := d.uint32()
+	if err != nil {
+		return err
+	}
+
+	if err := d.arity(n); err != nil {
+		return err
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		fields := cachedFields(v.Type())
+
+		for i := 0; i < n; i++ {
+			key, err := d.key()
+			if err != nil {
+				return err
+			}
+
+			var fv reflect.Value
+			if f := fields.find(key); f != nil {
+				fv = fieldByIndex(v, f.index)
+			}
+
+			if err := d.value(fv); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Map:
+		t := v.Type()
+
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(t))
+		}
+
+		for i := 0; i < n; i++ {
+			key, err := d.key()
+			if err != nil {
+				return err
+			}
+
+			kv, err := mapKey(t.Key(), key)
+			if err != nil {
+				d.saveError(err)
+			}
+
+			elem := reflect.New(t.Elem()).Elem()
+			if err := d.value(elem); err != nil {
+				return err
+			}
+
+			if kv.IsValid() {
+				v.SetMapIndex(kv, elem)
+			}
+		}
+
+	case reflect.Interface:
+		var m = make(map[string]interface{}, n)
+
+		for i := 0; i < n; i++ {
+			key, err := d.key()
+			if err != nil {
+				return err
+			}
+
+			var elem interface{}
+			if err := d.value(reflect.ValueOf(&elem).Elem()); err != nil {
+				return err
+			}
+
+			m[string(key)] = elem
+		}
+
+		v.Set(reflect.ValueOf(m))
+	}
+
+	return nil
+}
+
+// key reads a map key as a string. Atoms, binaries and integers are
+// supported.
+func (d *decodeState) key() ([]byte, error) {
+	if len(d.data) == 0 {
+		return nil, errUnexpectedEnd
+	}
+
+	switch tag := d.data[0]; tag {
+	case atomExt, atomUTF8Ext, smallAtomExt, smallAtomUTF8Ext:
+		return d.readAtom()
+
+	case binaryExt:
+		return d.binary()
+
+	case smallIntegerExt, integerExt, smallBigExt, largeBigExt:
+		n, err := d.readInteger()
+		if err != nil {
+			return nil, err
+		}
+
+		if n.big != nil {
+			return []byte(n.big.String()), nil
+		}
+
+		var b []byte
+		if n.neg {
+			b = append(b, '-')
+		}
+		return strconv.AppendUint(b, n.abs, 10), nil
+
+	default:
+		return nil, fmt.Errorf("Unsupported map key tag %d", tag)
+	}
+}
+
+func mapKey(t reflect.Type, key []byte) (reflect.Value, error) {
+	if reflect.PtrTo(t).Implements(textUnmarshalerType) {
+		kv := reflect.New(t)
+		if err := kv.Interface().(encoding.TextUnmarshaler).UnmarshalText(key); err != nil {
+			return reflect.Value{}, err
+		}
+		return kv.Elem(), nil
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return reflect.ValueOf(string(key)).Convert(t), nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(string(key), 10, 64)
+		if err != nil || reflect.Zero(t).OverflowInt(i) {
+			return reflect.Value{}, &stdjson.UnmarshalTypeError{Value: "number " + string(key), Type: t}
+		}
+		return reflect.ValueOf(i).Convert(t), nil
+
+	default:
+		u, err := strconv.ParseUint(string(key), 10, 64)
+		if err != nil || reflect.Zero(t).OverflowUint(u) {
+			return reflect.Value{}, &stdjson.UnmarshalTypeError{Value: "number " + string(key), Type: t}
+		}
+		return reflect.ValueOf(u).Convert(t), nil
+	}
+}
+
+// fieldByIndex returns the field with the index, allocating embedded pointers
+// on the way. An invalid Value is returned if the field can't be set.
+func fieldByIndex(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return reflect.Value{}
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+
+		v = v.Field(x)
+	}
+
+	return v
+}
+
+// skip skips the next term.
+func (r *reader) skip() error {
+	tag, err := r.uint8()
+	if err != nil {
+		return err
+	}
+
+	var n int
+
+	switch tag {
+	case smallIntegerExt:
+		n = 1
+	case integerExt:
+		n = 4
+	case newFloatExt:
+		n = 8
+	case floatExt:
+		n = 31
+	case nilExt:
+		n = 0
+
+	case smallBigExt:
+		n, err = r.uint8()
+		n++ // sign
+	case largeBigExt:
+		n, err = r.uint32()
+		n++
+	case atomExt, atomUTF8Ext, stringExt:
+		n, err = r.uint16()
+	case smallAtomExt, smallAtomUTF8Ext:
+		n, err = r.uint8()
+	case binaryExt:
+		n, err = r.uint32()
+
+	case listExt, largeTupleExt, smallTupleExt, mapExt:
+		if tag == smallTupleExt {
+			n, err = r.uint8()
+		} else {
+			n, err = r.uint32()
+		}
+		if err != nil {
+			return err
+		}
+
+		switch tag {
+		case listExt:
+			n++ // tail
+		case mapExt:
+			n *= 2
+		}
+
+		for i := 0; i < n; i++ {
+			if err := r.skip(); err != nil {
+				return err
+			}
+		}
+
+		return nil
+
+	default:
+		return fmt.Errorf("Unsupported term tag %d", tag)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	_, err = r.read(n)
+	return err
+}
@@ -0,0 +1,167 @@
+package etf
+
+import (
+	"bytes"
+	"encoding/binary"
+	stdjson "encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// encode writes a value decoded from JSON with UseNumber as a term. Strings
+// are written as binaries, as Discord expects.
+func encode(buf *bytes.Buffer, v interface{}) error {
+	switch v := v.(type) {
+	case nil:
+		writeAtom(buf, "nil")
+
+	case bool:
+		writeAtom(buf, strconv.FormatBool(v))
+
+	case string:
+		buf.WriteByte(binaryExt)
+		writeUint32(buf, len(v))
+		buf.WriteString(v)
+
+	case stdjson.Number:
+		return encodeNumber(buf, v)
+
+	case []interface{}:
+		if len(v) == 0 {
+			buf.WriteByte(nilExt)
+			return nil
+		}
+
+		buf.WriteByte(listExt)
+		writeUint32(buf, len(v))
+
+		for _, v := range v {
+			if err := encode(buf, v); err != nil {
+				return err
+			}
+		}
+
+		buf.WriteByte(nilExt)
+
+	case map[string]interface{}:
+		buf.WriteByte(mapExt)
+		writeUint32(buf, len(v))
+
+		// Sort the keys so the output is stable.
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			if err := encode(buf, k); err != nil {
+				return err
+			}
+			if err := encode(buf, v[k]); err != nil {
+				return err
+			}
+		}
+
+	default:
+		return fmt.Errorf("Unsupported type %T", v)
+	}
+
+	return nil
+}
+
+func encodeNumber(buf *bytes.Buffer, n stdjson.Number) error {
+	if i, err := strconv.ParseInt(string(n), 10, 64); err == nil {
+		writeInt(buf, i)
+		return nil
+	}
+
+	if u, err := strconv.ParseUint(string(n), 10, 64); err == nil {
+		writeBig(buf, 0, new(big.Int).SetUint64(u))
+		return nil
+	}
+
+	if i, ok := new(big.Int).SetString(string(n), 10); ok {
+		var sign byte
+		if i.Sign() < 0 {
+			sign = 1
+		}
+
+		writeBig(buf, sign, i.Abs(i))
+		return nil
+	}
+
+	f, err := strconv.ParseFloat(string(n), 64)
+	if err != nil {
+		return errors.Wrap(err, "Failed to parse number")
+	}
+
+	buf.WriteByte(newFloatExt)
+
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], math.Float64bits(f))
+	buf.Write(b[:])
+
+	return nil
+}
+
+func writeInt(buf *bytes.Buffer, i int64) {
+	switch {
+	case i >= 0 && i <= math.MaxUint8:
+		buf.WriteByte(smallIntegerExt)
+		buf.WriteByte(byte(i))
+
+	case i >= math.MinInt32 && i <= math.MaxInt32:
+		buf.WriteByte(integerExt)
+
+		var b [4]byte
+		binary.BigEndian.PutUint32(b[:], uint32(int32(i)))
+		buf.Write(b[:])
+
+	default:
+		var sign byte
+		if i < 0 {
+			sign = 1
+		}
+
+		writeBig(buf, sign, new(big.Int).Abs(big.NewInt(i)))
+	}
+}
+
+// writeBig writes the absolute value i with the sign as a big integer.
+func writeBig(buf *bytes.Buffer, sign byte, i *big.Int) {
+	be := i.Bytes()
+	n := len(be)
+
+	if n <= math.MaxUint8 {
+		buf.WriteByte(smallBigExt)
+		buf.WriteByte(byte(n))
+	} else {
+		buf.WriteByte(largeBigExt)
+		writeUint32(buf, n)
+	}
+
+	buf.WriteByte(sign)
+
+	// Digits are little-endian.
+	for i := n - 1; i >= 0; i-- {
+		buf.WriteByte(be[i])
+	}
+}
+
+func writeAtom(buf *bytes.Buffer, atom string) {
+	buf.WriteByte(smallAtomUTF8Ext)
+	buf.WriteByte(byte(len(atom)))
+	buf.WriteString(atom)
+}
+
+func writeUint32(buf *bytes.Buffer, n int) {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], uint32(n))
+	buf.Write(b[:])
+}
@@ -0,0 +1,143 @@
+// Package etf implements the Erlang external term format as a json.Driver.
+//
+// Terms are decoded directly into values, following the same rules as
+// encoding/json. Types with custom UnmarshalJSON methods are given the term
+// converted into JSON, so every type that works with the JSON driver works the
+// same way with this driver; snowflakes sent as integers are given as JSON
+// numbers, which discord.Snowflake already accepts. json.Raw keeps the term,
+// so it could be decoded later with this driver.
+//
+// Values are encoded by converting them from JSON.
+package etf
+
+import (
+	"bytes"
+	stdjson "encoding/json"
+	"io"
+	"io/ioutil"
+
+	"github.com/diamondburned/arikawa/internal/json"
+	"github.com/pkg/errors"
+)
+
+// Version is the first byte of every term.
+const Version = 131
+
+// Term tags, from
+// http://erlang.org/doc/apps/erts/erl_ext_dist.html
+const (
+	newFloatExt      = 70
+	compressedExt    = 80
+	smallIntegerExt  = 97
+	integerExt       = 98
+	floatExt         = 99
+	atomExt          = 100
+	smallTupleExt    = 104
+	largeTupleExt    = 105
+	nilExt           = 106
+	stringExt        = 107
+	listExt          = 108
+	binaryExt        = 109
+	smallBigExt      = 110
+	largeBigExt      = 111
+	smallAtomExt     = 115
+	mapExt           = 116
+	atomUTF8Ext      = 118
+	smallAtomUTF8Ext = 119
+)
+
+var ErrNotETF = errors.New("data is not in the external term format")
+
+// Driver is a json.Driver that encodes into and decodes from the external term
+// format.
+type Driver struct{}
+
+var _ json.BinaryDriver = Driver{}
+
+// Marshal encodes v into the external term format.
+func (Driver) Marshal(v interface{}) ([]byte, error) {
+	b, err := stdjson.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return FromJSON(b)
+}
+
+// Binary returns true, as terms have to be sent as binary messages.
+func (Driver) Binary() bool {
+	return true
+}
+
+// Unmarshal decodes a term into v. Data that doesn't start with Version is
+// decoded as JSON, so json.Raw values made by other drivers could still be
+// decoded.
+func (Driver) Unmarshal(data []byte, v interface{}) error {
+	if len(data) == 0 || data[0] != Version {
+		return stdjson.Unmarshal(data, v)
+	}
+
+	return unmarshal(data, v)
+}
+
+func (d Driver) DecodeStream(r io.Reader, v interface{}) error {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	return d.Unmarshal(b, v)
+}
+
+func (d Driver) EncodeStream(w io.Writer, v interface{}) error {
+	b, err := d.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(b)
+	return err
+}
+
+// ToJSON converts a term, starting with Version, into JSON.
+func ToJSON(data []byte) ([]byte, error) {
+	if len(data) == 0 || data[0] != Version {
+		return nil, ErrNotETF
+	}
+
+	d := converter{reader{data: data[1:]}}
+
+	var buf bytes.Buffer
+	buf.Grow(len(data) * 2)
+
+	if err := d.term(&buf); err != nil {
+		return nil, err
+	}
+
+	if len(d.data) > 0 {
+		return nil, errors.New("Trailing data after term")
+	}
+
+	return buf.Bytes(), nil
+}
+
+// FromJSON converts JSON into a term, starting with Version.
+func FromJSON(data []byte) ([]byte, error) {
+	dec := stdjson.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, errors.Wrap(err, "Failed to decode JSON")
+	}
+
+	var buf bytes.Buffer
+	buf.Grow(len(data))
+	buf.WriteByte(Version)
+
+	if err := encode(&buf, v); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
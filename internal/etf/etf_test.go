@@ -0,0 +1,499 @@
+// +build unit
+
+package etf
+
+import (
+	"bytes"
+	"compress/zlib"
+	stdjson "encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/diamondburned/arikawa/discord"
+	"github.com/diamondburned/arikawa/internal/json"
+)
+
+// payload is a subset of gateway.OP, as gateway can't be imported.
+type payload struct {
+	Code      int      `json:"op"`
+	Data      json.Raw `json:"d,omitempty"`
+	Sequence  int64    `json:"s,omitempty"`
+	EventName string   `json:"t,omitempty"`
+}
+
+// ready is a subset of gateway.ReadyEvent.
+type ready struct {
+	Version   int          `json:"v"`
+	User      discord.User `json:"user"`
+	SessionID string       `json:"session_id"`
+
+	PrivateChannels []discord.Channel `json:"private_channels"`
+	Guilds          []discord.Guild   `json:"guilds"`
+
+	Trace []string `json:"_trace"`
+}
+
+// readPayload reads a payload from testdata. The payloads are in the form
+// Discord sends them with encoding=etf: maps with atom keys, strings as
+// binaries, and snowflakes as either binaries or big integers.
+func readPayload(t testing.TB, name string) []byte {
+	b, err := ioutil.ReadFile(filepath.Join("testdata", name+".etf"))
+	if err != nil {
+		t.Fatal("Failed to read payload:", err)
+	}
+	return b
+}
+
+func unmarshalPayload(t testing.TB, name string) payload {
+	var op payload
+	if err := (Driver{}).Unmarshal(readPayload(t, name), &op); err != nil {
+		t.Fatal("Failed to unmarshal:", err)
+	}
+
+	// The data should still be a term.
+	if len(op.Data) == 0 || op.Data[0] != Version {
+		t.Fatalf("Data is not a term: %v", op.Data)
+	}
+
+	return op
+}
+
+func TestUnmarshalHello(t *testing.T) {
+	op := unmarshalPayload(t, "hello")
+
+	if op.Code != 10 || op.Sequence != 0 || op.EventName != "" {
+		t.Fatalf("Unexpected payload: %#v", op)
+	}
+
+	var hello struct {
+		HeartbeatInterval discord.Milliseconds `json:"heartbeat_interval"`
+		Trace             []string             `json:"_trace"`
+	}
+
+	if err := (Driver{}).Unmarshal(op.Data, &hello); err != nil {
+		t.Fatal("Failed to unmarshal data:", err)
+	}
+
+	if hello.HeartbeatInterval != 41250 {
+		t.Fatal("Unexpected heartbeat interval:", hello.HeartbeatInterval)
+	}
+
+	if len(hello.Trace) != 1 {
+		t.Fatal("Unexpected trace:", hello.Trace)
+	}
+}
+
+func TestUnmarshalHeartbeatAck(t *testing.T) {
+	op := unmarshalPayload(t, "heartbeat_ack")
+
+	if op.Code != 11 {
+		t.Fatalf("Unexpected payload: %#v", op)
+	}
+
+	var v *struct{}
+	if err := (Driver{}).Unmarshal(op.Data, &v); err != nil || v != nil {
+		t.Fatal("Unexpected nil data:", v, err)
+	}
+}
+
+func TestUnmarshalReady(t *testing.T) {
+	op := unmarshalPayload(t, "ready")
+
+	if op.Code != 0 || op.Sequence != 1 || op.EventName != "READY" {
+		t.Fatalf("Unexpected payload: %#v", op)
+	}
+
+	var r ready
+	if err := (Driver{}).Unmarshal(op.Data, &r); err != nil {
+		t.Fatal("Failed to unmarshal ready:", err)
+	}
+
+	if r.Version != 6 || r.SessionID != "a7f2b8a0cd8e2d4f0fd0b8e1e8c2d9e4" {
+		t.Fatalf("Unexpected ready: %#v", r)
+	}
+
+	if r.User.ID != 669343813316100097 || !r.User.Bot || r.User.Avatar != "" {
+		t.Fatalf("Unexpected user: %#v", r.User)
+	}
+
+	if r.PrivateChannels == nil || len(r.PrivateChannels) != 0 {
+		t.Fatal("Unexpected private channels:", r.PrivateChannels)
+	}
+
+	if len(r.Guilds) != 2 || r.Guilds[1].ID != 589911580398370820 || !r.Guilds[1].Unavailable {
+		t.Fatalf("Unexpected guilds: %#v", r.Guilds)
+	}
+}
+
+func TestUnmarshalMessage(t *testing.T) {
+	op := unmarshalPayload(t, "message_create")
+
+	if op.Code != 0 || op.Sequence != 3 || op.EventName != "MESSAGE_CREATE" {
+		t.Fatalf("Unexpected payload: %#v", op)
+	}
+
+	var msg discord.Message
+	if err := (Driver{}).Unmarshal(op.Data, &msg); err != nil {
+		t.Fatal("Failed to unmarshal message:", err)
+	}
+
+	// The guild ID is sent as a big integer.
+	if msg.ID != 684528426959700008 || msg.GuildID != 361910177961738244 {
+		t.Fatalf("Unexpected snowflakes: %d, %d", msg.ID, msg.GuildID)
+	}
+
+	if msg.Author.ID != 170132746042081280 || msg.Author.Username != "diamondburned" {
+		t.Fatalf("Unexpected author: %#v", msg.Author)
+	}
+
+	if msg.Content != "hello \"world\"\n<@669343813316100097>" || msg.TTS {
+		t.Fatalf("Unexpected content: %q", msg.Content)
+	}
+
+	if msg.EditedTimestamp != nil || msg.Timestamp.Time().Year() != 2020 {
+		t.Fatalf("Unexpected timestamps: %v, %v", msg.Timestamp, msg.EditedTimestamp)
+	}
+
+	if len(msg.Mentions) != 1 || msg.Mentions[0].Member == nil || !msg.Mentions[0].Bot {
+		t.Fatalf("Unexpected mentions: %#v", msg.Mentions)
+	}
+
+	if msg.Member == nil || len(msg.Member.RoleIDs) != 2 || msg.Member.RoleIDs[1] != 654416102917767188 {
+		t.Fatalf("Unexpected member: %#v", msg.Member)
+	}
+}
+
+// TestUnmarshalMatchesJSON checks that decoding directly gives the same values
+// as decoding the term converted into JSON.
+func TestUnmarshalMatchesJSON(t *testing.T) {
+	var tests = []struct {
+		name string
+		new  func() interface{}
+	}{
+		{"hello", func() interface{} { return new(map[string]interface{}) }},
+		{"ready", func() interface{} { return new(ready) }},
+		{"message_create", func() interface{} { return new(discord.Message) }},
+	}
+
+	for _, test := range tests {
+		op := unmarshalPayload(t, test.name)
+
+		direct := test.new()
+		if err := (Driver{}).Unmarshal(op.Data, direct); err != nil {
+			t.Fatal("Failed to unmarshal:", err)
+		}
+
+		j, err := ToJSON(op.Data)
+		if err != nil {
+			t.Fatal("Failed to convert to JSON:", err)
+		}
+
+		viaJSON := test.new()
+		if err := stdjson.Unmarshal(j, viaJSON); err != nil {
+			t.Fatal("Failed to unmarshal JSON:", err)
+		}
+
+		if !reflect.DeepEqual(direct, viaJSON) {
+			t.Errorf("Mismatch for %s:\n%#v\n%#v", test.name, direct, viaJSON)
+		}
+	}
+}
+
+func TestUnmarshalTypeError(t *testing.T) {
+	// {a: "b", c: 1}
+	var term = []byte{
+		Version, mapExt, 0, 0, 0, 2,
+		smallAtomUTF8Ext, 1, 'a', binaryExt, 0, 0, 0, 1, 'b',
+		smallAtomUTF8Ext, 1, 'c', smallIntegerExt, 1,
+	}
+
+	var v struct {
+		A int `json:"a"`
+		C int `json:"c"`
+	}
+
+	err := (Driver{}).Unmarshal(term, &v)
+	if _, ok := err.(*stdjson.UnmarshalTypeError); !ok {
+		t.Fatal("Unexpected error:", err)
+	}
+
+	// Decoding should continue after the mismatch.
+	if v.C != 1 {
+		t.Fatal("Field after the mismatch wasn't decoded:", v.C)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, name := range []string{"hello", "heartbeat_ack", "ready", "message_create"} {
+		j, err := ToJSON(readPayload(t, name))
+		if err != nil {
+			t.Fatal("Failed to convert to JSON:", err)
+		}
+
+		b, err := FromJSON(j)
+		if err != nil {
+			t.Fatal("Failed to convert from JSON:", err)
+		}
+
+		// Keys are written as binaries, so compare the JSON instead.
+		j2, err := ToJSON(b)
+		if err != nil {
+			t.Fatal("Failed to convert the encoded term to JSON:", err)
+		}
+
+		var v1, v2 interface{}
+		stdjson.Unmarshal(j, &v1)
+		stdjson.Unmarshal(j2, &v2)
+
+		if !jsonEqual(v1, v2) {
+			t.Fatalf("Round trip mismatch for %s:\n%s\n%s", name, j, j2)
+		}
+	}
+}
+
+func jsonEqual(v1, v2 interface{}) bool {
+	b1, _ := stdjson.Marshal(v1)
+	b2, _ := stdjson.Marshal(v2)
+	return bytes.Equal(b1, b2)
+}
+
+func TestMarshal(t *testing.T) {
+	var tests = []struct {
+		v    interface{}
+		term []byte
+	}{
+		{nil, []byte{Version, smallAtomUTF8Ext, 3, 'n', 'i', 'l'}},
+		{0, []byte{Version, smallIntegerExt, 0}},
+		{-1, []byte{Version, integerExt, 0xFF, 0xFF, 0xFF, 0xFF}},
+		{1 << 20, []byte{Version, integerExt, 0, 0x10, 0, 0}},
+		{"hi", []byte{Version, binaryExt, 0, 0, 0, 2, 'h', 'i'}},
+		{[]int{}, []byte{Version, nilExt}},
+		{[]int{1}, []byte{Version, listExt, 0, 0, 0, 1, smallIntegerExt, 1, nilExt}},
+		{
+			uint64(682341200427155457),
+			[]byte{Version, smallBigExt, 8, 0, 0x01, 0x00, 0x13, 0xE3, 0xB4, 0x29, 0x78, 0x09},
+		},
+	}
+
+	for _, test := range tests {
+		b, err := (Driver{}).Marshal(test.v)
+		if err != nil {
+			t.Fatal("Failed to marshal:", err)
+		}
+
+		if !bytes.Equal(b, test.term) {
+			t.Errorf("Unexpected term for %v: %v, expected %v", test.v, b, test.term)
+		}
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	var tests = [][]byte{
+		{Version},
+		{Version, binaryExt, 0, 0, 0, 10, 'a'},
+		{Version, 255},
+		{Version, smallIntegerExt, 1, 2},
+		{Version, listExt, 0, 0, 0, 1, smallIntegerExt, 1, smallIntegerExt, 2},
+	}
+
+	for _, test := range tests {
+		var v interface{}
+		if err := (Driver{}).Unmarshal(test, &v); err == nil {
+			t.Errorf("Expected error unmarshaling %v", test)
+		}
+	}
+}
+
+func TestUnmarshalOversizedArity(t *testing.T) {
+	// A READY payload with the arity of its data map corrupted to
+	// 0x92000002, which used to be allocated up front.
+	b := readPayload(t, "ready")
+
+	i := bytes.Index(b, []byte{smallAtomUTF8Ext, 1, 'd', mapExt})
+	if i == -1 {
+		t.Fatal("Data map not found in ready")
+	}
+
+	b = append([]byte(nil), b...)
+	copy(b[i+4:], []byte{0x92, 0x00, 0x00, 0x02})
+
+	var v interface{}
+	if err := (Driver{}).Unmarshal(b, &v); err == nil {
+		t.Fatal("Expected error unmarshaling corrupted ready")
+	}
+
+	d := append([]byte{Version}, b[i+3:]...)
+
+	if err := (Driver{}).Unmarshal(d, &v); err == nil {
+		t.Fatal("Expected error unmarshaling corrupted ready data")
+	}
+
+	var r ready
+	if err := (Driver{}).Unmarshal(d, &r); err == nil {
+		t.Fatal("Expected error unmarshaling corrupted ready data into a struct")
+	}
+}
+
+func TestUnmarshalOversizedLengths(t *testing.T) {
+	var max = []byte{0xFF, 0xFF, 0xFF, 0xFF}
+
+	var terms = [][]byte{
+		append([]byte{Version, listExt}, max...),
+		append([]byte{Version, largeTupleExt}, max...),
+		append([]byte{Version, mapExt}, max...),
+		append([]byte{Version, binaryExt}, max...),
+		append(append([]byte{Version, largeBigExt}, max...), 0),
+		{Version, smallTupleExt, 0xFF},
+		{Version, smallBigExt, 0xFF, 0},
+		{Version, stringExt, 0xFF, 0xFF},
+		{Version, atomUTF8Ext, 0xFF, 0xFF},
+		append([]byte{Version, compressedExt}, max...),
+		append(append([]byte{Version, listExt, 0, 0, 0, 1, mapExt}, max...), nilExt),
+	}
+
+	for _, term := range terms {
+		for _, v := range decodeTargets() {
+			if err := (Driver{}).Unmarshal(term, v); err == nil {
+				t.Errorf("Expected error unmarshaling %v into %T", term, v)
+			}
+		}
+
+		if _, err := ToJSON(term); err == nil {
+			t.Errorf("Expected error converting %v", term)
+		}
+	}
+}
+
+func TestUnmarshalCompressedSize(t *testing.T) {
+	var buf bytes.Buffer
+	buf.Write([]byte{Version, compressedExt, 0, 0, 0, 5})
+
+	// The term claims to inflate into 5 bytes, but inflates into a lot more.
+	z := zlib.NewWriter(&buf)
+	z.Write([]byte{binaryExt, 0, 0x10, 0, 0})
+	z.Write(make([]byte, 0x100000))
+	z.Close()
+
+	var v interface{}
+	if err := (Driver{}).Unmarshal(buf.Bytes(), &v); err == nil {
+		t.Fatal("Expected error unmarshaling a term larger than its size")
+	}
+}
+
+func TestUnmarshalTruncated(t *testing.T) {
+	for _, name := range fixtures {
+		b := readPayload(t, name)
+
+		for i := 1; i < len(b); i++ {
+			for _, v := range decodeTargets() {
+				if err := unmarshalSafe(b[:i], v); err == nil {
+					t.Errorf("Expected error unmarshaling %s truncated to %d bytes into %T",
+						name, i, v)
+				}
+			}
+
+			if _, err := ToJSON(b[:i]); err == nil {
+				t.Errorf("Expected error converting %s truncated to %d bytes", name, i)
+			}
+		}
+	}
+}
+
+func TestUnmarshalCorrupted(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+
+	for _, name := range fixtures {
+		payload := readPayload(t, name)
+
+		for i := 0; i < 2000; i++ {
+			b := append([]byte(nil), payload...)
+
+			// Corrupt a few bytes, keeping the version so the term gets
+			// decoded.
+			for j := r.Intn(4); j >= 0; j-- {
+				b[1+r.Intn(len(b)-1)] = byte(r.Intn(256))
+			}
+
+			// Errors are fine, panics and hangs are not.
+			for _, v := range decodeTargets() {
+				if err := unmarshalSafe(b, v); errors.Is(err, errPanicked) {
+					t.Fatalf("Unmarshaling %v into %T: %v", b, v, err)
+				}
+			}
+
+			if _, err := convertSafe(b); errors.Is(err, errPanicked) {
+				t.Fatalf("Converting %v: %v", b, err)
+			}
+		}
+	}
+}
+
+var fixtures = []string{"hello", "heartbeat_ack", "ready", "message_create"}
+
+var errPanicked = errors.New("panicked")
+
+// decodeTargets returns the values that fuzzed terms are decoded into.
+func decodeTargets() []interface{} {
+	return []interface{}{
+		new(interface{}),
+		new(payload),
+		new(ready),
+		new(discord.Message),
+		new([]int),
+		new(map[string]int),
+	}
+}
+
+func unmarshalSafe(b []byte, v interface{}) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%w: %v", errPanicked, r)
+		}
+	}()
+
+	return (Driver{}).Unmarshal(b, v)
+}
+
+func convertSafe(b []byte) (j []byte, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%w: %v", errPanicked, r)
+		}
+	}()
+
+	return ToJSON(b)
+}
+
+func BenchmarkUnmarshalMessage(b *testing.B) {
+	data := unmarshalPayload(b, "message_create").Data
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		var msg discord.Message
+		if err := (Driver{}).Unmarshal(data, &msg); err != nil {
+			b.Fatal("Failed to unmarshal:", err)
+		}
+	}
+}
+
+func BenchmarkUnmarshalMessageJSON(b *testing.B) {
+	data := unmarshalPayload(b, "message_create").Data
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		j, err := ToJSON(data)
+		if err != nil {
+			b.Fatal("Failed to convert:", err)
+		}
+
+		var msg discord.Message
+		if err := stdjson.Unmarshal(j, &msg); err != nil {
+			b.Fatal("Failed to unmarshal:", err)
+		}
+	}
+}
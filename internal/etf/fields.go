@@ -0,0 +1,181 @@
+package etf
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// field is a struct field that could be decoded into.
+type field struct {
+	name  string
+	index []int
+
+	depth  int
+	tagged bool
+}
+
+// structFields are the fields of a struct, found the same way as in
+// encoding/json, including the ones promoted from embedded structs.
+type structFields struct {
+	list   []field
+	byName map[string]*field
+}
+
+// find returns the field with the name, or the first one with the name in a
+// different case, like encoding/json.
+func (fs *structFields) find(name []byte) *field {
+	if f, ok := fs.byName[string(name)]; ok {
+		return f
+	}
+
+	for i := range fs.list {
+		if bytes.EqualFold([]byte(fs.list[i].name), name) {
+			return &fs.list[i]
+		}
+	}
+
+	return nil
+}
+
+var fieldCache sync.Map // map[reflect.Type]*structFields
+
+func cachedFields(t reflect.Type) *structFields {
+	if fs, ok := fieldCache.Load(t); ok {
+		return fs.(*structFields)
+	}
+
+	fs, _ := fieldCache.LoadOrStore(t, typeFields(t))
+	return fs.(*structFields)
+}
+
+func typeFields(t reflect.Type) *structFields {
+	var all []field
+	collectFields(t, nil, 0, map[reflect.Type]bool{}, &all)
+
+	// Keep the dominant field for each name: the shallowest one, or the only
+	// tagged one of the shallowest. Ambiguous names are dropped.
+	var names []string
+	var byName = map[string][]field{}
+
+	for _, f := range all {
+		if _, ok := byName[f.name]; !ok {
+			names = append(names, f.name)
+		}
+		byName[f.name] = append(byName[f.name], f)
+	}
+
+	fs := &structFields{}
+
+	for _, name := range names {
+		if f, ok := dominantField(byName[name]); ok {
+			fs.list = append(fs.list, f)
+		}
+	}
+
+	fs.byName = make(map[string]*field, len(fs.list))
+	for i := range fs.list {
+		fs.byName[fs.list[i].name] = &fs.list[i]
+	}
+
+	return fs
+}
+
+func dominantField(fields []field) (field, bool) {
+	var depth = fields[0].depth
+	for _, f := range fields {
+		if f.depth < depth {
+			depth = f.depth
+		}
+	}
+
+	var dominant []field
+	var tagged []field
+
+	for _, f := range fields {
+		if f.depth != depth {
+			continue
+		}
+
+		dominant = append(dominant, f)
+		if f.tagged {
+			tagged = append(tagged, f)
+		}
+	}
+
+	switch {
+	case len(tagged) == 1:
+		return tagged[0], true
+	case len(tagged) == 0 && len(dominant) == 1:
+		return dominant[0], true
+	default:
+		return field{}, false
+	}
+}
+
+func collectFields(
+	t reflect.Type, index []int, depth int, visited map[reflect.Type]bool, all *[]field) {
+
+	if visited[t] {
+		return
+	}
+
+	visited[t] = true
+	defer delete(visited, t)
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+
+		tag := sf.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name := tag
+		if i := strings.IndexByte(tag, ','); i > -1 {
+			name = tag[:i]
+		}
+
+		fieldIndex := make([]int, len(index)+1)
+		copy(fieldIndex, index)
+		fieldIndex[len(index)] = i
+
+		if sf.Anonymous {
+			ft := sf.Type
+			if ft.Kind() == reflect.Ptr {
+				// Pointers to unexported structs can't be allocated.
+				if sf.PkgPath != "" {
+					continue
+				}
+				ft = ft.Elem()
+			}
+
+			// Untagged embedded structs have their fields promoted.
+			if name == "" && ft.Kind() == reflect.Struct {
+				collectFields(ft, fieldIndex, depth+1, visited, all)
+				continue
+			}
+
+			if sf.PkgPath != "" {
+				continue
+			}
+
+		} else if sf.PkgPath != "" {
+			// Unexported
+			continue
+		}
+
+		f := field{
+			name:   name,
+			index:  fieldIndex,
+			depth:  depth,
+			tagged: name != "",
+		}
+		if f.name == "" {
+			f.name = sf.Name
+		}
+
+		*all = append(*all, f)
+	}
+}
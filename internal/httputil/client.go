@@ -22,7 +22,25 @@ type Client struct {
 	json.Driver
 	SchemaEncoder
 
+	RetryPolicy RetryPolicy
+
+	// Retries overrides RetryPolicy.Retries if it's not 0.
+	//
+	// Deprecated: Use RetryPolicy.Retries instead.
 	Retries uint
+
+	// OnRetry is called before each retry, with the number of the attempt that
+	// failed, starting from 1, and why it failed. The error is an *HTTPError
+	// if Discord returned a failure status.
+	OnRetry func(r *http.Request, attempt uint, delay time.Duration, err error)
+
+	// RateLimited is true if the transport has a rate limiter that already
+	// waits for the rate limit headers of a 429 before the next request. The
+	// retry then doesn't wait for them again.
+	RateLimited bool
+
+	// context is used for requests without an explicit context.
+	context context.Context
 }
 
 var DefaultClient = NewClient()
@@ -34,8 +52,25 @@ func NewClient() Client {
 		},
 		Driver:        json.Default{},
 		SchemaEncoder: &DefaultSchema{},
-		Retries:       Retries,
+		RetryPolicy:   DefaultRetryPolicy(),
+		OnRetry:       func(*http.Request, uint, time.Duration, error) {},
+	}
+}
+
+// WithContext returns a shallow copy of the client, which uses ctx for all
+// requests without an explicit context.
+func (c *Client) WithContext(ctx context.Context) *Client {
+	cpy := *c
+	cpy.context = ctx
+	return &cpy
+}
+
+// Context returns the context given to WithContext, or context.Background.
+func (c *Client) Context() context.Context {
+	if c.context != nil {
+		return c.context
 	}
+	return context.Background()
 }
 
 func (c *Client) MeanwhileMultipart(
@@ -43,7 +78,7 @@ func (c *Client) MeanwhileMultipart(
 	method, url string, opts ...RequestOption) (*http.Response, error) {
 
 	// We want to cancel the request if our bodyWriter fails
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(c.Context())
 	defer cancel()
 
 	r, w := io.Pipe()
@@ -89,6 +124,28 @@ func (c *Client) FastRequest(
 	return r.Body.Close()
 }
 
+// maxAttempts returns the maximum number of attempts for a request, including
+// the first one.
+func (c *Client) maxAttempts() uint {
+	if c.Retries > 0 {
+		return c.Retries
+	}
+	return c.RetryPolicy.Retries
+}
+
+// retryDelay returns how long to wait before the given retry, starting from 0.
+func (c *Client) retryDelay(retry uint, r *http.Response) time.Duration {
+	if c.RateLimited && r != nil && r.StatusCode == http.StatusTooManyRequests {
+		// The rate limiter has taken the headers, so the next request waits
+		// for them anyway.
+		if _, ok := rateLimitDelay(r.Header); ok {
+			return 0
+		}
+	}
+
+	return c.RetryPolicy.Delay(retry, r)
+}
+
 func (c *Client) RequestCtx(ctx context.Context,
 	method, url string, opts ...RequestOption) (*http.Response, error) {
 
@@ -105,17 +162,47 @@ func (c *Client) RequestCtx(ctx context.Context,
 
 	var r *http.Response
 
-	for i := uint(0); i < c.Retries; i++ {
+	for attempt := uint(1); ; attempt++ {
 		r, err = c.Client.Do(req)
-		if err != nil {
-			continue
+		if err == nil && r.StatusCode >= 200 && r.StatusCode <= 299 {
+			break
 		}
 
-		if r.StatusCode < 200 || r.StatusCode > 299 {
-			continue
+		// Only retry if there's an attempt left and the body could be sent
+		// again.
+		if !Retryable(r, err) || attempt >= c.maxAttempts() ||
+			(req.Body != nil && req.GetBody == nil) {
+			break
 		}
 
-		break
+		delay := c.retryDelay(attempt-1, r)
+
+		if c.OnRetry != nil {
+			var retryErr = err
+			if retryErr == nil {
+				retryErr = &HTTPError{Status: r.StatusCode}
+			}
+
+			c.OnRetry(req, attempt, delay, retryErr)
+		}
+
+		// Drain the old response, so that the connection could be reused.
+		if r != nil {
+			io.Copy(ioutil.Discard, r.Body)
+			r.Body.Close()
+		}
+
+		if err := sleepCtx(ctx, delay); err != nil {
+			return nil, RequestError{err}
+		}
+
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, RequestError{err}
+			}
+			req.Body = body
+		}
 	}
 
 	// If all retries failed:
@@ -130,6 +217,8 @@ func (c *Client) RequestCtx(ctx context.Context,
 		}
 
 		b, err := ioutil.ReadAll(r.Body)
+		r.Body.Close()
+
 		if err != nil {
 			return nil, httpErr
 		}
@@ -169,11 +258,27 @@ func (c *Client) RequestCtxJSON(ctx context.Context,
 func (c *Client) Request(
 	method, url string, opts ...RequestOption) (*http.Response, error) {
 
-	return c.RequestCtx(context.Background(), method, url, opts...)
+	return c.RequestCtx(c.Context(), method, url, opts...)
 }
 
 func (c *Client) RequestJSON(
 	to interface{}, method, url string, opts ...RequestOption) error {
 
-	return c.RequestCtxJSON(context.Background(), to, method, url, opts...)
+	return c.RequestCtxJSON(c.Context(), to, method, url, opts...)
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
@@ -0,0 +1,217 @@
+// +build unit
+
+package httputil
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/diamondburned/arikawa/internal/json"
+)
+
+func newTestClient() Client {
+	c := NewClient()
+	c.RetryPolicy.BaseDelay = time.Millisecond
+	c.RetryPolicy.MaxDelay = time.Millisecond
+	return c
+}
+
+func TestRequestRetry(t *testing.T) {
+	var bodies []string
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		bodies = append(bodies, string(b))
+
+		switch len(bodies) {
+		case 1:
+			w.WriteHeader(http.StatusBadGateway)
+		case 2:
+			w.Header().Set("Retry-After", "100")
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer srv.Close()
+
+	c := newTestClient()
+
+	var delays []time.Duration
+	c.OnRetry = func(r *http.Request, attempt uint, delay time.Duration, err error) {
+		delays = append(delays, delay)
+
+		if httpErr, ok := err.(*HTTPError); !ok || httpErr.Status < 429 {
+			t.Errorf("Unexpected error for attempt %d: %v", attempt, err)
+		}
+	}
+
+	sent := time.Now()
+
+	err := c.RequestJSON(nil, "POST", srv.URL, WithJSONBody(json.Default{}, "hi"))
+	if err != nil {
+		t.Fatal("Failed to request:", err)
+	}
+
+	if len(bodies) != 3 {
+		t.Fatal("Unexpected number of attempts:", len(bodies))
+	}
+
+	for _, body := range bodies {
+		if body != `"hi"` {
+			t.Fatalf("Body not replayed: %q", bodies)
+		}
+	}
+
+	if len(delays) != 2 || delays[1] != 100*time.Millisecond {
+		t.Fatal("Unexpected delays:", delays)
+	}
+
+	if time.Since(sent) < 100*time.Millisecond {
+		t.Fatal("Retry-After not waited for")
+	}
+}
+
+func TestRequestRetryRateLimited(t *testing.T) {
+	var attempts int
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+
+		if attempts == 1 {
+			w.Header().Set("Retry-After", "1000")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	c := newTestClient()
+	c.RateLimited = true
+
+	var delays []time.Duration
+	c.OnRetry = func(r *http.Request, attempt uint, delay time.Duration, err error) {
+		delays = append(delays, delay)
+	}
+
+	sent := time.Now()
+
+	if err := c.FastRequest("GET", srv.URL); err != nil {
+		t.Fatal("Failed to request:", err)
+	}
+
+	// The rate limiter waits for Retry-After, so the retry shouldn't.
+	if len(delays) != 1 || delays[0] != 0 {
+		t.Fatal("Unexpected delays:", delays)
+	}
+
+	if since := time.Since(sent); since >= time.Second {
+		t.Fatal("Retry-After waited for by the retry, took", since)
+	}
+}
+
+func TestRequestNoRetry(t *testing.T) {
+	var attempts int
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+
+		if r.URL.Path == "/notfound" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"code":10003,"message":"Unknown Channel"}`))
+			return
+		}
+
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	c := newTestClient()
+
+	_, err := c.Request("GET", srv.URL+"/notfound")
+	if httpErr, ok := err.(*HTTPError); !ok || httpErr.Status != 404 {
+		t.Fatal("Unexpected error:", err)
+	}
+
+	if attempts != 1 {
+		t.Fatal("4xx was retried:", attempts)
+	}
+
+	// A body without GetBody can't be replayed.
+	attempts = 0
+
+	_, err = c.Request("POST", srv.URL, WithBody(ioutil.NopCloser(strings.NewReader("hi"))))
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+
+	if attempts != 1 {
+		t.Fatal("Request with a body that can't be replayed was retried:", attempts)
+	}
+
+	// A 500 is retried until the attempts run out.
+	attempts = 0
+
+	if _, err := c.Request("GET", srv.URL); err == nil {
+		t.Fatal("Expected an error")
+	}
+
+	if attempts != int(c.RetryPolicy.Retries) {
+		t.Fatal("Unexpected number of attempts:", attempts)
+	}
+
+	// The deprecated Retries field overrides the policy.
+	attempts = 0
+	c.Retries = 2
+
+	if _, err := c.Request("GET", srv.URL); err == nil {
+		t.Fatal("Expected an error")
+	}
+
+	if attempts != 2 {
+		t.Fatal("Retries not used for the number of attempts:", attempts)
+	}
+}
+
+func TestWithContext(t *testing.T) {
+	done := make(chan struct{})
+	defer close(done)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Hang until the test is done.
+		select {
+		case <-done:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+
+	c := newTestClient()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	sent := time.Now()
+
+	_, err := c.WithContext(ctx).Request("GET", srv.URL)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatal("Unexpected error:", err)
+	}
+
+	if since := time.Since(sent); since > time.Second {
+		t.Fatal("Request wasn't cancelled, took", since)
+	}
+
+	// The original client shouldn't have the context.
+	if c.Context() != context.Background() {
+		t.Fatal("WithContext changed the original client")
+	}
+}
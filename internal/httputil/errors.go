@@ -2,29 +2,51 @@ package httputil
 
 import (
 	"fmt"
+	"sort"
 	"strconv"
+	"strings"
+
+	"github.com/diamondburned/arikawa/internal/json"
 )
 
 type JSONError struct {
 	error
 }
 
+func (err JSONError) Unwrap() error {
+	return err.error
+}
+
 type RequestError struct {
 	error
 }
 
+func (err RequestError) Unwrap() error {
+	return err.error
+}
+
 type HTTPError struct {
 	Status int    `json:"-"`
 	Body   []byte `json:"-"`
 
 	Code    ErrorCode `json:"code"`
 	Message string    `json:"message,omitempty"`
+
+	// Errors has the errors of each invalid field, usually sent along with a
+	// 400. It's nil if there are none.
+	Errors *ErrorTree `json:"errors,omitempty"`
 }
 
 func (err HTTPError) Error() string {
 	switch {
 	case err.Message != "":
-		return "Discord error: " + err.Message
+		msg := "Discord error: " + err.Message
+
+		if fields := err.FieldErrors(); len(fields) > 0 {
+			msg += " (" + fields[0].Error() + ")"
+		}
+
+		return msg
 
 	case err.Code > 0:
 		return fmt.Sprintf("Discord returned status %d error code %d",
@@ -39,4 +61,145 @@ func (err HTTPError) Error() string {
 	}
 }
 
+// Is returns true if target is the ErrorCode of err, so errors.Is could be
+// used to check for a specific error code. A Code of 0 means Discord didn't
+// send one, so it never matches.
+func (err HTTPError) Is(target error) bool {
+	code, ok := target.(ErrorCode)
+	return ok && err.Code > 0 && err.Code == code
+}
+
+// As sets target to the first field error if target is a *FieldError, so
+// errors.As could be used to get it.
+func (err HTTPError) As(target interface{}) bool {
+	fieldErr, ok := target.(*FieldError)
+	if !ok {
+		return false
+	}
+
+	fields := err.FieldErrors()
+	if len(fields) == 0 {
+		return false
+	}
+
+	*fieldErr = fields[0]
+	return true
+}
+
+// FieldErrors returns the errors of all invalid fields, sorted by their paths.
+func (err HTTPError) FieldErrors() []FieldError {
+	if err.Errors == nil {
+		return nil
+	}
+	return err.Errors.FieldErrors()
+}
+
+// ErrorCode is a JSON error code from Discord. It implements error, so it
+// could be used as the target of errors.Is.
 type ErrorCode uint
+
+func (code ErrorCode) Error() string {
+	return "Discord error code " + strconv.FormatUint(uint64(code), 10)
+}
+
+// FieldError is the error of a single invalid field.
+type FieldError struct {
+	// Path is the path to the field, such as "embed.fields.0.name".
+	Path string `json:"-"`
+
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (err FieldError) Error() string {
+	if err.Path == "" {
+		return err.Message
+	}
+	return err.Path + ": " + err.Message
+}
+
+// ErrorTree is the nested "errors" object, which mirrors the structure of the
+// invalid request body. Each node has the errors of its field and the trees of
+// its children, keyed by the field name or array index.
+type ErrorTree struct {
+	Errors []FieldError
+	Fields map[string]*ErrorTree
+}
+
+func (tree *ErrorTree) UnmarshalJSON(b []byte) error {
+	var fields map[string]json.Raw
+
+	if err := (json.Default{}).Unmarshal(b, &fields); err != nil {
+		return err
+	}
+
+	for name, raw := range fields {
+		if name == "_errors" {
+			if err := (json.Default{}).Unmarshal(raw, &tree.Errors); err != nil {
+				return err
+			}
+			continue
+		}
+
+		var child ErrorTree
+		if err := child.UnmarshalJSON(raw); err != nil {
+			return err
+		}
+
+		if tree.Fields == nil {
+			tree.Fields = map[string]*ErrorTree{}
+		}
+		tree.Fields[name] = &child
+	}
+
+	return nil
+}
+
+// Get returns the tree at the given path, or nil if the path has no errors.
+func (tree *ErrorTree) Get(path ...string) *ErrorTree {
+	for _, name := range path {
+		if tree == nil {
+			return nil
+		}
+		tree = tree.Fields[name]
+	}
+	return tree
+}
+
+// Walk calls fn for each field error in the tree, with the path of its field.
+// Fields are walked in sorted order.
+func (tree *ErrorTree) Walk(fn func(path []string, err FieldError)) {
+	tree.walk(nil, fn)
+}
+
+func (tree *ErrorTree) walk(path []string, fn func([]string, FieldError)) {
+	if tree == nil {
+		return
+	}
+
+	for _, err := range tree.Errors {
+		err.Path = strings.Join(path, ".")
+		fn(path, err)
+	}
+
+	var names = make([]string, 0, len(tree.Fields))
+	for name := range tree.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		// Copy the path, so fn could keep it.
+		childPath := append(path[:len(path):len(path)], name)
+		tree.Fields[name].walk(childPath, fn)
+	}
+}
+
+// FieldErrors returns all field errors in the tree, with their paths set.
+func (tree *ErrorTree) FieldErrors() []FieldError {
+	var errs []FieldError
+	tree.Walk(func(_ []string, err FieldError) {
+		errs = append(errs, err)
+	})
+	return errs
+}
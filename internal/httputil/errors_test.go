@@ -0,0 +1,83 @@
+// +build unit
+
+package httputil
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/diamondburned/arikawa/internal/json"
+)
+
+const invalidFormBody = `{
+	"code": 50035,
+	"message": "Invalid Form Body",
+	"errors": {
+		"content": {
+			"_errors": [{"code": "BASE_TYPE_MAX_LENGTH", "message": "Too long"}]
+		},
+		"embed": {
+			"fields": {
+				"0": {
+					"name": {
+						"_errors": [{"code": "BASE_TYPE_REQUIRED", "message": "Required"}]
+					}
+				}
+			}
+		}
+	}
+}`
+
+func TestHTTPErrorTree(t *testing.T) {
+	var httpErr HTTPError
+	if err := (json.Default{}).Unmarshal([]byte(invalidFormBody), &httpErr); err != nil {
+		t.Fatal("Failed to decode:", err)
+	}
+
+	fields := httpErr.FieldErrors()
+	if len(fields) != 2 {
+		t.Fatal("Unexpected field errors:", fields)
+	}
+
+	if fields[0].Path != "content" || fields[0].Code != "BASE_TYPE_MAX_LENGTH" {
+		t.Fatalf("Unexpected first error: %#v", fields[0])
+	}
+
+	if fields[1].Error() != "embed.fields.0.name: Required" {
+		t.Fatal("Unexpected second error:", fields[1])
+	}
+
+	name := httpErr.Errors.Get("embed", "fields", "0", "name")
+	if name == nil || len(name.Errors) != 1 {
+		t.Fatal("Failed to get the nested tree")
+	}
+
+	if httpErr.Errors.Get("embed", "title") != nil {
+		t.Fatal("Got a tree for a valid field")
+	}
+
+	var err error = &httpErr
+
+	if !errors.Is(err, ErrorCode(50035)) {
+		t.Fatal("errors.Is failed for the error code")
+	}
+
+	if errors.Is(err, ErrorCode(10003)) {
+		t.Fatal("errors.Is matched a different error code")
+	}
+
+	// An error without a JSON body has no code to match.
+	if errors.Is(&HTTPError{Status: 502}, ErrorCode(0)) {
+		t.Fatal("errors.Is matched an error without a code")
+	}
+
+	var fieldErr FieldError
+	if !errors.As(err, &fieldErr) || fieldErr.Path != "content" {
+		t.Fatal("errors.As failed for the field error:", fieldErr)
+	}
+
+	expect := "Discord error: Invalid Form Body (content: Too long)"
+	if err.Error() != expect {
+		t.Fatal("Unexpected error message:", err)
+	}
+}
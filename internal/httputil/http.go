@@ -8,7 +8,7 @@ type TransportWrapper struct {
 	Default http.RoundTripper
 	Pre     func(*http.Request) error
 	Cancel  func(*http.Request, error)
-	Post    func(*http.Response) error
+	Post    func(*http.Request, *http.Response) error
 }
 
 var _ http.RoundTripper = (*TransportWrapper)(nil)
@@ -18,7 +18,7 @@ func NewTransportWrapper() *TransportWrapper {
 		Default: http.DefaultTransport,
 		Pre:     func(*http.Request) error { return nil },
 		Cancel:  func(*http.Request, error) {},
-		Post:    func(*http.Response) error { return nil },
+		Post:    func(*http.Request, *http.Response) error { return nil },
 	}
 }
 
@@ -33,7 +33,7 @@ func (c *TransportWrapper) RoundTrip(req *http.Request) (*http.Response, error)
 		return nil, err
 	}
 
-	if err := c.Post(r); err != nil {
+	if err := c.Post(req, r); err != nil {
 		return nil, err
 	}
 
@@ -1,7 +1,9 @@
 package httputil
 
 import (
+	"bytes"
 	"io"
+	"io/ioutil"
 	"net/http"
 
 	"github.com/diamondburned/arikawa/internal/json"
@@ -43,6 +45,8 @@ func WithSchema(schema SchemaEncoder, v interface{}) RequestOption {
 	}
 }
 
+// WithBody sets the body of the request. The body can't be replayed, so the
+// request isn't retried.
 func WithBody(body io.ReadCloser) RequestOption {
 	return func(r *http.Request) error {
 		// tee := io.TeeReader(body, os.Stderr)
@@ -53,6 +57,8 @@ func WithBody(body io.ReadCloser) RequestOption {
 	}
 }
 
+// WithJSONBody encodes v as the body. The body could be replayed with GetBody,
+// so the request could be retried.
 func WithJSONBody(json json.Driver, v interface{}) RequestOption {
 	if v == nil {
 		return func(*http.Request) error {
@@ -60,21 +66,18 @@ func WithJSONBody(json json.Driver, v interface{}) RequestOption {
 		}
 	}
 
-	var err error
-	var rp, wp = io.Pipe()
-
-	go func() {
-		err = json.EncodeStream(wp, v)
-		wp.Close()
-	}()
-
 	return func(r *http.Request) error {
+		b, err := json.Marshal(v)
 		if err != nil {
 			return err
 		}
 
 		r.Header.Set("Content-Type", "application/json")
-		r.Body = rp
+		r.ContentLength = int64(len(b))
+		r.GetBody = func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(b)), nil
+		}
+		r.Body, _ = r.GetBody()
 		return nil
 	}
 }
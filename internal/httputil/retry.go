@@ -0,0 +1,94 @@
+package httputil
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy controls how failed requests are retried. Only network errors,
+// 429s and 5xx statuses are retried, and only if the request body could be
+// replayed through http.Request's GetBody.
+type RetryPolicy struct {
+	// Retries is the maximum number of attempts, including the first one.
+	Retries uint
+
+	// BaseDelay is the delay before the first retry, doubled for each retry
+	// after. MaxDelay caps the delay. Rate limit headers on a 429 are used
+	// instead, if there are any.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+// DefaultRetryPolicy returns the policy used in NewClient.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		Retries:   Retries,
+		BaseDelay: 500 * time.Millisecond,
+		MaxDelay:  10 * time.Second,
+	}
+}
+
+// Retryable returns true if the request should be retried after getting the
+// given response or error.
+func Retryable(r *http.Response, err error) bool {
+	if err != nil {
+		// The request was cancelled or timed out, so the caller gave up.
+		if errors.Is(err, context.Canceled) ||
+			errors.Is(err, context.DeadlineExceeded) {
+
+			return false
+		}
+		return true
+	}
+
+	return r.StatusCode == http.StatusTooManyRequests || r.StatusCode >= 500
+}
+
+// Delay returns how long to wait before the given retry, starting from 0.
+// The rate limit headers in r are used if r is a 429. Client doesn't use this
+// delay for a 429 if it's RateLimited.
+func (p RetryPolicy) Delay(retry uint, r *http.Response) time.Duration {
+	if r != nil && r.StatusCode == http.StatusTooManyRequests {
+		if d, ok := rateLimitDelay(r.Header); ok {
+			return d
+		}
+	}
+
+	if p.BaseDelay <= 0 {
+		return 0
+	}
+
+	delay := p.BaseDelay
+	for i := uint(0); i < retry && (p.MaxDelay <= 0 || delay < p.MaxDelay); i++ {
+		delay *= 2
+	}
+
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+
+	// Jitter the second half, so that clients don't all retry at once.
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// rateLimitDelay returns the delay from Discord's rate limit headers.
+func rateLimitDelay(h http.Header) (time.Duration, bool) {
+	// Retry-After is in milliseconds with X-RateLimit-Precision.
+	if after := h.Get("Retry-After"); after != "" {
+		if ms, err := strconv.ParseFloat(after, 64); err == nil {
+			return time.Duration(ms * float64(time.Millisecond)), true
+		}
+	}
+
+	if after := h.Get("X-RateLimit-Reset-After"); after != "" {
+		if secs, err := strconv.ParseFloat(after, 64); err == nil {
+			return time.Duration(secs * float64(time.Second)), true
+		}
+	}
+
+	return 0, false
+}
@@ -74,6 +74,14 @@ type Driver interface {
 	EncodeStream(w io.Writer, v interface{}) error
 }
 
+// BinaryDriver is a Driver that encodes into a binary format rather than text,
+// such as the Erlang term format. Websocket connections send its payloads as
+// binary messages.
+type BinaryDriver interface {
+	Driver
+	Binary() bool
+}
+
 type Default struct{}
 
 func (d Default) Marshal(v interface{}) ([]byte, error) {
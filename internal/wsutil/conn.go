@@ -1,8 +1,11 @@
 package wsutil
 
 import (
+	"bufio"
+	"bytes"
 	"compress/zlib"
 	"context"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
@@ -39,14 +42,34 @@ type Connection interface {
 	Close(err error) error
 }
 
-// Conn is the default Websocket connection. It compresses all payloads using
-// zlib.
+// CloseError is sent as the Event error when the server closes the connection
+// with a status code other than a normal closure.
+type CloseError struct {
+	Code   int
+	Reason string
+}
+
+func (err *CloseError) Error() string {
+	if err.Reason == "" {
+		return fmt.Sprintf("Websocket closed with code %d", err.Code)
+	}
+	return fmt.Sprintf("Websocket closed with code %d: %s", err.Code, err.Reason)
+}
+
+// Conn is the default Websocket connection. It inflates binary payloads using
+// zlib, either per message or as a zlib-stream.
 type Conn struct {
 	Conn *websocket.Conn
 	json.Driver
 
+	// ZlibStream, if true, inflates all binary messages with a single zlib
+	// context for the connection, as needed for compress=zlib-stream. The
+	// context is reset on every Dial. This has to be set before Dial.
+	ZlibStream bool
+
 	mut    sync.Mutex
 	events chan Event
+	zlib   *ZlibInflater
 }
 
 var _ Connection = (*Conn)(nil)
@@ -70,8 +93,17 @@ func (c *Conn) Dial(ctx context.Context, addr string) error {
 	c.Conn, _, err = websocket.Dial(ctx, addr, &websocket.DialOptions{
 		HTTPHeader: headers,
 	})
+	if err != nil {
+		return err
+	}
+
 	c.Conn.SetReadLimit(WSReadLimit)
 
+	// A new connection needs a new zlib context.
+	if c.ZlibStream {
+		c.zlib = NewZlibInflater()
+	}
+
 	c.events = make(chan Event)
 	c.readLoop()
 	return err
@@ -83,12 +115,21 @@ func (c *Conn) Listen() <-chan Event {
 
 func (c *Conn) readLoop() {
 	conn := c.Conn
+	inflater := c.zlib
 
 	go func() {
 		defer close(c.events)
 
 		for {
-			b, err := readAll(conn, context.Background())
+			var b []byte
+			var err error
+
+			if inflater != nil {
+				b, err = readStream(conn, inflater, context.Background())
+			} else {
+				b, err = readAll(conn, context.Background())
+			}
+
 			if err != nil {
 				// Is the error an EOF?
 				if stderr.Is(err, io.EOF) {
@@ -96,12 +137,20 @@ func (c *Conn) readLoop() {
 					return
 				}
 
-				// Check if the error is a fatal one
-				if code := websocket.CloseStatus(err); code > -1 {
+				// Check if the connection was closed with a status code
+				var closeErr websocket.CloseError
+				if stderr.As(err, &closeErr) {
 					// Is the exit normal?
-					if code == websocket.StatusNormalClosure {
+					if closeErr.Code == websocket.StatusNormalClosure {
 						return
 					}
+
+					// Keep the status code for the caller.
+					c.events <- Event{nil, &CloseError{
+						Code:   int(closeErr.Code),
+						Reason: closeErr.Reason,
+					}}
+					return
 				}
 
 				// Unusual error; log:
@@ -121,16 +170,21 @@ func readAll(c *websocket.Conn, ctx context.Context) ([]byte, error) {
 	}
 
 	if t == websocket.MessageBinary {
-		// Probably a zlib payload
-		z, err := zlib.NewReader(r)
-		if err != nil {
-			c.CloseRead(ctx)
-			return nil,
-				errors.Wrap(err, "Failed to create a zlib reader")
-		}
+		// Binary payloads could also be uncompressed, such as with ETF.
+		br := bufio.NewReader(r)
+		r = br
+
+		if h, err := br.Peek(2); err == nil && isZlibHeader(h) {
+			z, err := zlib.NewReader(r)
+			if err != nil {
+				c.CloseRead(ctx)
+				return nil,
+					errors.Wrap(err, "Failed to create a zlib reader")
+			}
 
-		defer z.Close()
-		r = z
+			defer z.Close()
+			r = z
+		}
 	}
 
 	b, err := ioutil.ReadAll(r)
@@ -142,9 +196,46 @@ func readAll(c *websocket.Conn, ctx context.Context) ([]byte, error) {
 	return b, nil
 }
 
+// isZlibHeader returns true if the 2 bytes are a valid zlib header using
+// deflate.
+func isZlibHeader(h []byte) bool {
+	return h[0]&0x0F == 8 && (uint16(h[0])<<8|uint16(h[1]))%31 == 0
+}
+
+// readStream reads binary frames until the zlib-stream suffix, then inflates
+// them all at once. Text frames are returned as-is.
+func readStream(
+	c *websocket.Conn, z *ZlibInflater, ctx context.Context) ([]byte, error) {
+
+	var buf []byte
+
+	for {
+		t, b, err := c.Read(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		if t != websocket.MessageBinary {
+			return b, nil
+		}
+
+		buf = append(buf, b...)
+
+		if bytes.HasSuffix(buf, ZlibSuffix) {
+			return z.Inflate(buf)
+		}
+	}
+}
+
 func (c *Conn) Send(ctx context.Context, b []byte) error {
-	// TODO: zlib stream
-	return c.Conn.Write(ctx, websocket.MessageText, b)
+	// Discord only compresses what it sends, so this is never compressed, but
+	// binary encodings such as ETF have to be sent as binary messages.
+	var t = websocket.MessageText
+	if d, ok := c.Driver.(json.BinaryDriver); ok && d.Binary() {
+		t = websocket.MessageBinary
+	}
+
+	return c.Conn.Write(ctx, t, b)
 }
 
 func (c *Conn) Close(err error) error {
@@ -170,6 +261,11 @@ func (c *Conn) close() {
 	<-c.events
 	c.events = nil
 
+	if c.zlib != nil {
+		c.zlib.Close()
+		c.zlib = nil
+	}
+
 	// Set the connection to nil.
 	c.Conn = nil
 }
@@ -0,0 +1,81 @@
+// +build unit
+
+package wsutil
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/diamondburned/arikawa/internal/json"
+	"nhooyr.io/websocket"
+)
+
+type binaryDriver struct {
+	json.Default
+}
+
+func (binaryDriver) Binary() bool { return true }
+
+func TestConnSendMessageType(t *testing.T) {
+	types := make(chan websocket.MessageType, 1)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			t.Error("Failed to accept:", err)
+			return
+		}
+		defer c.Close(websocket.StatusNormalClosure, "")
+
+		typ, _, err := c.Read(r.Context())
+		if err != nil {
+			t.Error("Failed to read:", err)
+			return
+		}
+		types <- typ
+
+		// Wait for the client to close.
+		c.Read(r.Context())
+	}))
+	defer srv.Close()
+
+	var tests = []struct {
+		driver json.Driver
+		typ    websocket.MessageType
+	}{
+		{json.Default{}, websocket.MessageText},
+		{binaryDriver{}, websocket.MessageBinary},
+	}
+
+	for _, test := range tests {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		conn := NewConn(test.driver)
+
+		if err := conn.Dial(ctx, strings.Replace(srv.URL, "http", "ws", 1)); err != nil {
+			t.Fatal("Failed to dial:", err)
+		}
+
+		if err := conn.Send(ctx, []byte("{}")); err != nil {
+			t.Fatal("Failed to send:", err)
+		}
+
+		select {
+		case typ := <-types:
+			if typ != test.typ {
+				t.Errorf("Unexpected message type %v for %T, expected %v", typ, test.driver, test.typ)
+			}
+		case <-ctx.Done():
+			t.Fatal("Timed out waiting for message")
+		}
+
+		if err := conn.Close(nil); err != nil {
+			t.Fatal("Failed to close:", err)
+		}
+	}
+}
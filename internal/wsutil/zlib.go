@@ -0,0 +1,151 @@
+package wsutil
+
+import (
+	"compress/zlib"
+	"io"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// ZlibSuffix is the Z_SYNC_FLUSH suffix that ends every complete message in a
+// zlib-stream.
+var ZlibSuffix = []byte{0x00, 0x00, 0xFF, 0xFF}
+
+var ErrInflaterClosed = errors.New("zlib-stream inflater closed")
+
+// ZlibInflater inflates a zlib-stream, which is a single zlib context shared
+// by all messages of a connection. Each message ends with ZlibSuffix, which
+// flushes all its data out of the context.
+//
+// A new ZlibInflater has to be made for every connection, as the context
+// can't be reused.
+type ZlibInflater struct {
+	input  chan []byte
+	output chan []byte
+	stop   chan struct{}
+	done   chan struct{}
+
+	// Only accessed by the inflating goroutine, until done is closed.
+	in      []byte
+	out     []byte
+	pending bool
+	err     error
+
+	once sync.Once
+}
+
+// NewZlibInflater creates a new ZlibInflater with a fresh zlib context.
+func NewZlibInflater() *ZlibInflater {
+	z := &ZlibInflater{
+		input:  make(chan []byte),
+		output: make(chan []byte),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+
+	go z.inflate()
+
+	return z
+}
+
+// Inflate inflates a complete message, which must end with ZlibSuffix.
+// Messages split across multiple frames must be joined before this is called.
+func (z *ZlibInflater) Inflate(b []byte) ([]byte, error) {
+	select {
+	case z.input <- b:
+	case <-z.done:
+		return nil, z.err
+	}
+
+	select {
+	case b := <-z.output:
+		return b, nil
+	case <-z.done:
+		return nil, z.err
+	}
+}
+
+// Close stops the inflater. Inflate will return ErrInflaterClosed after this.
+func (z *ZlibInflater) Close() error {
+	z.once.Do(func() { close(z.stop) })
+	<-z.done
+	return nil
+}
+
+func (z *ZlibInflater) inflate() {
+	defer close(z.done)
+
+	// This blocks until the first message, as it needs the zlib header.
+	r, err := zlib.NewReader(z)
+
+	// The output is buffered manually, as fill takes it in the middle of a
+	// Read call.
+	var buf [4096]byte
+
+	for err == nil {
+		var n int
+		n, err = r.Read(buf[:])
+		z.out = append(z.out, buf[:n]...)
+	}
+
+	select {
+	case <-z.stop:
+		z.err = ErrInflaterClosed
+	default:
+		z.err = errors.Wrap(err, "Failed to inflate zlib-stream")
+	}
+}
+
+// fill is called by the zlib reader when it needs more data. As every message
+// ends with a sync flush, the reader has returned everything it could from the
+// current message by the time it asks for more.
+func (z *ZlibInflater) fill() error {
+	for len(z.in) == 0 {
+		if z.pending {
+			b := z.out
+			z.out = nil
+			z.pending = false
+
+			select {
+			case z.output <- b:
+			case <-z.stop:
+				return io.EOF
+			}
+		}
+
+		select {
+		case z.in = <-z.input:
+			z.pending = true
+		case <-z.stop:
+			return io.EOF
+		}
+	}
+
+	return nil
+}
+
+// Read implements io.Reader for the zlib reader.
+func (z *ZlibInflater) Read(p []byte) (int, error) {
+	if err := z.fill(); err != nil {
+		return 0, err
+	}
+
+	n := copy(p, z.in)
+	z.in = z.in[n:]
+
+	return n, nil
+}
+
+// ReadByte implements io.ByteReader, which stops the zlib reader from reading
+// ahead into the next message.
+func (z *ZlibInflater) ReadByte() (byte, error) {
+	if err := z.fill(); err != nil {
+		return 0, err
+	}
+
+	c := z.in[0]
+	z.in = z.in[1:]
+
+	return c, nil
+}
@@ -0,0 +1,144 @@
+// +build unit
+
+package wsutil
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/diamondburned/arikawa/internal/json"
+	"nhooyr.io/websocket"
+)
+
+var zlibMessages = []string{
+	`{"op":10,"d":{"heartbeat_interval":41250}}`,
+	`{"op":0,"t":"READY","s":1,"d":{"v":6}}`,
+	`{"op":11}`,
+}
+
+// compressStream compresses the messages into a single zlib-stream, returning
+// the compressed bytes of each message.
+func compressStream(t *testing.T, messages []string) [][]byte {
+	var buf bytes.Buffer
+	var w = zlib.NewWriter(&buf)
+
+	var compressed = make([][]byte, len(messages))
+
+	for i, msg := range messages {
+		if _, err := w.Write([]byte(msg)); err != nil {
+			t.Fatal("Failed to compress:", err)
+		}
+		if err := w.Flush(); err != nil {
+			t.Fatal("Failed to flush:", err)
+		}
+
+		compressed[i] = append([]byte(nil), buf.Bytes()...)
+		buf.Reset()
+
+		if !bytes.HasSuffix(compressed[i], ZlibSuffix) {
+			t.Fatal("Compressed message doesn't end with the suffix")
+		}
+	}
+
+	return compressed
+}
+
+func TestZlibInflater(t *testing.T) {
+	z := NewZlibInflater()
+
+	for i, b := range compressStream(t, zlibMessages) {
+		out, err := z.Inflate(b)
+		if err != nil {
+			t.Fatal("Failed to inflate:", err)
+		}
+
+		if string(out) != zlibMessages[i] {
+			t.Fatalf("Unexpected message %q, expected %q", out, zlibMessages[i])
+		}
+	}
+
+	z.Close()
+
+	if _, err := z.Inflate(nil); err != ErrInflaterClosed {
+		t.Fatal("Unexpected error after Close:", err)
+	}
+}
+
+func TestZlibInflaterCorrupt(t *testing.T) {
+	z := NewZlibInflater()
+	defer z.Close()
+
+	if _, err := z.Inflate([]byte{0xDE, 0xAD, 0x00, 0x00, 0xFF, 0xFF}); err == nil {
+		t.Fatal("Expected an error inflating garbage")
+	}
+}
+
+func TestConnZlibStream(t *testing.T) {
+	// Every connection starts a new zlib-stream.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			t.Error("Failed to accept:", err)
+			return
+		}
+		defer c.Close(websocket.StatusNormalClosure, "")
+
+		ctx := context.Background()
+
+		for i, b := range compressStream(t, zlibMessages) {
+			// Split the second message across two frames.
+			if i == 1 {
+				c.Write(ctx, websocket.MessageBinary, b[:len(b)/2])
+				b = b[len(b)/2:]
+			}
+
+			if err := c.Write(ctx, websocket.MessageBinary, b); err != nil {
+				t.Error("Failed to write:", err)
+				return
+			}
+		}
+
+		// Wait for the client to close.
+		c.Read(ctx)
+	}))
+	defer srv.Close()
+
+	conn := NewConn(json.Default{})
+	conn.ZlibStream = true
+
+	// Dial twice to make sure the context is reset.
+	for i := 0; i < 2; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if err := conn.Dial(ctx, strings.Replace(srv.URL, "http", "ws", 1)); err != nil {
+			t.Fatal("Failed to dial:", err)
+		}
+
+		ch := conn.Listen()
+
+		for _, msg := range zlibMessages {
+			select {
+			case ev := <-ch:
+				if ev.Error != nil {
+					t.Fatal("Unexpected error:", ev.Error)
+				}
+				if string(ev.Data) != msg {
+					t.Fatalf("Unexpected message %q, expected %q", ev.Data, msg)
+				}
+			case <-ctx.Done():
+				t.Fatal("Timed out waiting for message")
+			}
+		}
+
+		if err := conn.Close(nil); err != nil {
+			t.Fatal("Failed to close:", err)
+		}
+	}
+}
@@ -57,6 +57,15 @@ func New(token string) (*Session, error) {
 	return s, nil
 }
 
+// DerivedIntents returns the Gateway intents needed for the events of all
+// added handlers. For more information, refer to gateway.DerivedIntents.
+//
+//    s.Gateway.Identifier.AddIntents(s.DerivedIntents())
+//
+func (s *Session) DerivedIntents() gateway.Intents {
+	return gateway.DerivedIntents(s.Handler.EventTypes())
+}
+
 // Login tries to log in as a normal user account; MFA is optional.
 func Login(email, password, mfa string) (*Session, error) {
 	// Make a scratch HTTP client without a token
@@ -0,0 +1,970 @@
+// Package boltstore provides a state.Store that persists everything in a bbolt
+// database, so the cache survives restarts.
+package boltstore
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sort"
+
+	"github.com/diamondburned/arikawa/discord"
+	"github.com/diamondburned/arikawa/internal/json"
+	"github.com/diamondburned/arikawa/state"
+	"github.com/pkg/errors"
+	bolt "go.etcd.io/bbolt"
+)
+
+// Buckets. Keys are made of big-endian snowflakes, the parent's first, so
+// everything of a guild or a channel could be iterated with its prefix.
+var (
+	selfBucket          = []byte("self")           // "me"
+	guildsBucket        = []byte("guilds")         // guildID, no roles or emojis
+	rolesBucket         = []byte("roles")          // guildID+roleID
+	emojisBucket        = []byte("emojis")         // guildID+emojiID
+	channelsBucket      = []byte("channels")       // guildID+channelID
+	channelGuildsBucket = []byte("channel_guilds") // channelID:guildID
+	membersBucket       = []byte("members")        // guildID+userID
+	presencesBucket     = []byte("presences")      // guildID+userID
+	messagesBucket      = []byte("messages")       // channelID+messageID
+	voiceStatesBucket   = []byte("voice_states")   // guildID+userID
+)
+
+var buckets = [][]byte{
+	selfBucket,
+	guildsBucket,
+	rolesBucket,
+	emojisBucket,
+	channelsBucket,
+	channelGuildsBucket,
+	membersBucket,
+	presencesBucket,
+	messagesBucket,
+	voiceStatesBucket,
+}
+
+var selfKey = []byte("me")
+
+type Options struct {
+	MaxMessages uint // per channel, default 50
+
+	// Bolt is passed into bolt.Open.
+	Bolt *bolt.Options
+}
+
+type Store struct {
+	DB *bolt.DB
+
+	maxMessages int
+}
+
+var (
+	_ state.Store        = (*Store)(nil)
+	_ state.StoreBatcher = (*Store)(nil)
+)
+
+// Open opens or creates the database file. The Store should be closed after
+// use, as the file is locked while it's open.
+func Open(path string, opts *Options) (*Store, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	db, err := bolt.Open(path, 0600, opts.Bolt)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to open the database")
+	}
+
+	s, err := New(db, opts)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// New makes a Store from an opened database. The buckets are created if they
+// don't exist.
+func New(db *bolt.DB, opts *Options) (*Store, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	s := &Store{
+		DB:          db,
+		maxMessages: int(opts.MaxMessages),
+	}
+
+	if s.maxMessages == 0 {
+		s.maxMessages = 50
+	}
+
+	err := db.Update(func(tx *bolt.Tx) error {
+		for _, name := range buckets {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to create buckets")
+	}
+
+	return s, nil
+}
+
+func (s *Store) Close() error {
+	return s.DB.Close()
+}
+
+func (s *Store) Reset() error {
+	err := s.DB.Update(func(tx *bolt.Tx) error {
+		for _, name := range buckets {
+			err := tx.DeleteBucket(name)
+			if err != nil && err != bolt.ErrBucketNotFound {
+				return err
+			}
+
+			if _, err := tx.CreateBucket(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return errors.Wrap(err, "Failed to reset the database")
+	}
+
+	return nil
+}
+
+//// Helpers
+
+// key makes a key from the snowflakes.
+func key(ids ...discord.Snowflake) []byte {
+	var k = make([]byte, 8*len(ids))
+	for i, id := range ids {
+		binary.BigEndian.PutUint64(k[i*8:], uint64(id))
+	}
+	return k
+}
+
+func get(tx *bolt.Tx, bucket, k []byte, v interface{}) error {
+	b := tx.Bucket(bucket).Get(k)
+	if b == nil {
+		return state.ErrStoreNotFound
+	}
+
+	if err := decode(b, v); err != nil {
+		return errors.Wrapf(err, "Failed to decode from %s", bucket)
+	}
+
+	return nil
+}
+
+func put(tx *bolt.Tx, bucket, k []byte, v interface{}) error {
+	b, err := (json.Default{}).Marshal(v)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to encode into %s", bucket)
+	}
+
+	return tx.Bucket(bucket).Put(k, b)
+}
+
+// remove deletes the key, or returns ErrStoreNotFound if there's none.
+func remove(tx *bolt.Tx, bucket, k []byte) error {
+	b := tx.Bucket(bucket)
+	if b.Get(k) == nil {
+		return state.ErrStoreNotFound
+	}
+
+	return b.Delete(k)
+}
+
+// each calls fn with the values of all keys with the prefix, in order.
+func each(tx *bolt.Tx, bucket, prefix []byte, fn func(v []byte) error) error {
+	c := tx.Bucket(bucket).Cursor()
+
+	for k, v := c.Seek(prefix); bytes.HasPrefix(k, prefix); k, v = c.Next() {
+		if err := fn(v); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// removePrefix deletes all keys with the prefix.
+func removePrefix(tx *bolt.Tx, bucket, prefix []byte) error {
+	c := tx.Bucket(bucket).Cursor()
+
+	for k, _ := c.Seek(prefix); bytes.HasPrefix(k, prefix); k, _ = c.Seek(prefix) {
+		if err := c.Delete(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func decode(b []byte, v interface{}) error {
+	return (json.Default{}).Unmarshal(b, v)
+}
+
+// getAll decodes the values with the prefix, appending them with add. It
+// returns ErrStoreNotFound if there are none.
+func (s *Store) getAll(bucket, prefix []byte, add func(b []byte) error) error {
+	var found bool
+
+	err := s.DB.View(func(tx *bolt.Tx) error {
+		return each(tx, bucket, prefix, func(v []byte) error {
+			found = true
+			return add(v)
+		})
+	})
+	if err != nil {
+		return errors.Wrapf(err, "Failed to decode from %s", bucket)
+	}
+
+	if !found {
+		return state.ErrStoreNotFound
+	}
+
+	return nil
+}
+
+////
+
+func (s *Store) Me() (*discord.User, error) {
+	var u discord.User
+
+	err := s.DB.View(func(tx *bolt.Tx) error {
+		return get(tx, selfBucket, selfKey, &u)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &u, nil
+}
+
+func (s *Store) MyselfSet(me *discord.User) error {
+	return s.DB.Update(func(tx *bolt.Tx) error {
+		return put(tx, selfBucket, selfKey, me)
+	})
+}
+
+////
+
+func (s *Store) Channel(id discord.Snowflake) (*discord.Channel, error) {
+	var ch discord.Channel
+
+	err := s.DB.View(func(tx *bolt.Tx) error {
+		guildID := tx.Bucket(channelGuildsBucket).Get(key(id))
+		if guildID == nil {
+			return state.ErrStoreNotFound
+		}
+
+		// Copy the guild ID, as it belongs to the database.
+		k := append(append([]byte{}, guildID...), key(id)...)
+
+		return get(tx, channelsBucket, k, &ch)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &ch, nil
+}
+
+func (s *Store) Channels(guildID discord.Snowflake) ([]discord.Channel, error) {
+	var chs []discord.Channel
+
+	err := s.getAll(channelsBucket, key(guildID), func(b []byte) error {
+		var ch discord.Channel
+		if err := decode(b, &ch); err != nil {
+			return err
+		}
+
+		chs = append(chs, ch)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(chs, func(i, j int) bool {
+		return chs[i].Position < chs[j].Position
+	})
+
+	return chs, nil
+}
+
+func (s *Store) PrivateChannels() ([]discord.Channel, error) {
+	// Private channels are under the guild ID 0.
+	chs, err := s.Channels(0)
+	if err != nil && err != state.ErrStoreNotFound {
+		return nil, err
+	}
+
+	sort.Slice(chs, func(i, j int) bool {
+		// Latest first
+		return chs[i].LastMessageID > chs[j].LastMessageID
+	})
+
+	return chs, nil
+}
+
+func (s *Store) ChannelSet(channel *discord.Channel) error {
+	var guildID discord.Snowflake
+
+	switch channel.Type {
+	case discord.DirectMessage, discord.GroupDM:
+		// Private channels are under 0.
+	default:
+		guildID = channel.GuildID
+	}
+
+	var k = key(guildID, channel.ID)
+
+	return s.DB.Update(func(tx *bolt.Tx) error {
+		// The channel could've moved from another guild, so the old one is
+		// found by its guild.
+		var oldKey = k
+
+		if oldGuildID := tx.Bucket(channelGuildsBucket).Get(key(channel.ID)); oldGuildID != nil {
+			// Copy the guild ID, as it belongs to the database.
+			oldKey = append(append([]byte{}, oldGuildID...), key(channel.ID)...)
+		}
+
+		if channel.Permissions == nil {
+			var old discord.Channel
+
+			switch err := get(tx, channelsBucket, oldKey, &old); err {
+			case nil:
+				// Also from discordgo.
+				channel.Permissions = old.Permissions
+			case state.ErrStoreNotFound:
+			default:
+				return err
+			}
+		}
+
+		if !bytes.Equal(oldKey, k) {
+			if err := tx.Bucket(channelsBucket).Delete(oldKey); err != nil {
+				return err
+			}
+		}
+
+		if err := put(tx, channelsBucket, k, channel); err != nil {
+			return err
+		}
+
+		return tx.Bucket(channelGuildsBucket).Put(key(channel.ID), key(guildID))
+	})
+}
+
+func (s *Store) ChannelRemove(channel *discord.Channel) error {
+	return s.DB.Update(func(tx *bolt.Tx) error {
+		guildID := tx.Bucket(channelGuildsBucket).Get(key(channel.ID))
+		if guildID == nil {
+			return state.ErrStoreNotFound
+		}
+
+		// Copy the key, as it's only valid until the bucket is modified.
+		k := append(append([]byte{}, guildID...), key(channel.ID)...)
+
+		if err := remove(tx, channelsBucket, k); err != nil {
+			return err
+		}
+
+		return tx.Bucket(channelGuildsBucket).Delete(key(channel.ID))
+	})
+}
+
+////
+
+func (s *Store) Emoji(
+	guildID, emojiID discord.Snowflake) (*discord.Emoji, error) {
+
+	var e discord.Emoji
+
+	err := s.DB.View(func(tx *bolt.Tx) error {
+		if err := guildExists(tx, guildID); err != nil {
+			return err
+		}
+
+		return get(tx, emojisBucket, key(guildID, emojiID), &e)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &e, nil
+}
+
+func (s *Store) Emojis(guildID discord.Snowflake) ([]discord.Emoji, error) {
+	var es []discord.Emoji
+
+	err := s.DB.View(func(tx *bolt.Tx) error {
+		if err := guildExists(tx, guildID); err != nil {
+			return err
+		}
+
+		return each(tx, emojisBucket, key(guildID), func(b []byte) error {
+			var e discord.Emoji
+			if err := decode(b, &e); err != nil {
+				return errors.Wrap(err, "Failed to decode emoji")
+			}
+
+			es = append(es, e)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return es, nil
+}
+
+func (s *Store) EmojiSet(
+	guildID discord.Snowflake, emojis []discord.Emoji) error {
+
+	return s.DB.Update(func(tx *bolt.Tx) error {
+		if err := guildExists(tx, guildID); err != nil {
+			return err
+		}
+
+		for i := range emojis {
+			e := &emojis[i]
+			if err := put(tx, emojisBucket, key(guildID, e.ID), e); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+////
+
+func guildExists(tx *bolt.Tx, guildID discord.Snowflake) error {
+	if tx.Bucket(guildsBucket).Get(key(guildID)) == nil {
+		return state.ErrStoreNotFound
+	}
+	return nil
+}
+
+// guild gets the guild with its roles and emojis.
+func guild(tx *bolt.Tx, b []byte) (*discord.Guild, error) {
+	var g discord.Guild
+	if err := decode(b, &g); err != nil {
+		return nil, errors.Wrap(err, "Failed to decode guild")
+	}
+
+	err := each(tx, rolesBucket, key(g.ID), func(b []byte) error {
+		var r discord.Role
+		if err := decode(b, &r); err != nil {
+			return errors.Wrap(err, "Failed to decode role")
+		}
+
+		g.Roles = append(g.Roles, r)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = each(tx, emojisBucket, key(g.ID), func(b []byte) error {
+		var e discord.Emoji
+		if err := decode(b, &e); err != nil {
+			return errors.Wrap(err, "Failed to decode emoji")
+		}
+
+		g.Emojis = append(g.Emojis, e)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &g, nil
+}
+
+func (s *Store) Guild(id discord.Snowflake) (*discord.Guild, error) {
+	var g *discord.Guild
+
+	err := s.DB.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(guildsBucket).Get(key(id))
+		if b == nil {
+			return state.ErrStoreNotFound
+		}
+
+		var err error
+		g, err = guild(tx, b)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return g, nil
+}
+
+func (s *Store) Guilds() ([]discord.Guild, error) {
+	var gs []discord.Guild
+
+	err := s.DB.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(guildsBucket).ForEach(func(_, b []byte) error {
+			g, err := guild(tx, b)
+			if err != nil {
+				return err
+			}
+
+			gs = append(gs, *g)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(gs) == 0 {
+		return nil, state.ErrStoreNotFound
+	}
+
+	sort.Slice(gs, func(i, j int) bool {
+		return gs[i].ID > gs[j].ID
+	})
+
+	return gs, nil
+}
+
+func (s *Store) GuildSet(g *discord.Guild) error {
+	// Roles and emojis are stored separately.
+	var gd = *g
+	gd.Roles = nil
+	gd.Emojis = nil
+
+	return s.DB.Update(func(tx *bolt.Tx) error {
+		if err := put(tx, guildsBucket, key(g.ID), &gd); err != nil {
+			return err
+		}
+
+		// Keep the old ones if the guild doesn't have them.
+		if g.Roles != nil {
+			if err := removePrefix(tx, rolesBucket, key(g.ID)); err != nil {
+				return err
+			}
+
+			for i := range g.Roles {
+				r := &g.Roles[i]
+				if err := put(tx, rolesBucket, key(g.ID, r.ID), r); err != nil {
+					return err
+				}
+			}
+		}
+
+		if g.Emojis != nil {
+			if err := removePrefix(tx, emojisBucket, key(g.ID)); err != nil {
+				return err
+			}
+
+			for i := range g.Emojis {
+				e := &g.Emojis[i]
+				if err := put(tx, emojisBucket, key(g.ID, e.ID), e); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+}
+
+// GuildRemove removes the guild along with everything in it.
+func (s *Store) GuildRemove(id discord.Snowflake) error {
+	var prefix = key(id)
+
+	return s.DB.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(guildsBucket).Delete(prefix); err != nil {
+			return err
+		}
+
+		// The channels are only known by their keys, which end with their IDs.
+		var channelIDs [][]byte
+
+		c := tx.Bucket(channelsBucket).Cursor()
+		for k, _ := c.Seek(prefix); bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+			channelIDs = append(channelIDs, append([]byte{}, k[len(prefix):]...))
+		}
+
+		for _, channelID := range channelIDs {
+			if err := tx.Bucket(channelGuildsBucket).Delete(channelID); err != nil {
+				return err
+			}
+
+			if err := removePrefix(tx, messagesBucket, channelID); err != nil {
+				return err
+			}
+		}
+
+		for _, bucket := range [][]byte{
+			rolesBucket,
+			emojisBucket,
+			channelsBucket,
+			membersBucket,
+			presencesBucket,
+			voiceStatesBucket,
+		} {
+			if err := removePrefix(tx, bucket, prefix); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+////
+
+func (s *Store) Member(
+	guildID, userID discord.Snowflake) (*discord.Member, error) {
+
+	var m discord.Member
+
+	err := s.DB.View(func(tx *bolt.Tx) error {
+		return get(tx, membersBucket, key(guildID, userID), &m)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &m, nil
+}
+
+func (s *Store) Members(guildID discord.Snowflake) ([]discord.Member, error) {
+	var ms []discord.Member
+
+	err := s.getAll(membersBucket, key(guildID), func(b []byte) error {
+		var m discord.Member
+		if err := decode(b, &m); err != nil {
+			return err
+		}
+
+		ms = append(ms, m)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ms, nil
+}
+
+func (s *Store) MemberSet(
+	guildID discord.Snowflake, member *discord.Member) error {
+
+	return s.DB.Update(func(tx *bolt.Tx) error {
+		return put(tx, membersBucket, key(guildID, member.User.ID), member)
+	})
+}
+
+// MembersSet sets all the members in one transaction.
+func (s *Store) MembersSet(
+	guildID discord.Snowflake, members []discord.Member) error {
+
+	return s.DB.Update(func(tx *bolt.Tx) error {
+		for i := range members {
+			m := &members[i]
+			if err := put(tx, membersBucket, key(guildID, m.User.ID), m); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *Store) MemberRemove(guildID, userID discord.Snowflake) error {
+	return s.DB.Update(func(tx *bolt.Tx) error {
+		return remove(tx, membersBucket, key(guildID, userID))
+	})
+}
+
+////
+
+func (s *Store) Message(
+	channelID, messageID discord.Snowflake) (*discord.Message, error) {
+
+	var m discord.Message
+
+	err := s.DB.View(func(tx *bolt.Tx) error {
+		return get(tx, messagesBucket, key(channelID, messageID), &m)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &m, nil
+}
+
+// Messages returns the messages in the channel, latest first.
+func (s *Store) Messages(
+	channelID discord.Snowflake) ([]discord.Message, error) {
+
+	var ms []discord.Message
+
+	err := s.getAll(messagesBucket, key(channelID), func(b []byte) error {
+		var m discord.Message
+		if err := decode(b, &m); err != nil {
+			return err
+		}
+
+		ms = append(ms, m)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The keys are in ascending order, so the oldest message is first.
+	for i, j := 0, len(ms)-1; i < j; i, j = i+1, j-1 {
+		ms[i], ms[j] = ms[j], ms[i]
+	}
+
+	return ms, nil
+}
+
+func (s *Store) MaxMessages() int {
+	return s.maxMessages
+}
+
+func (s *Store) MessageSet(message *discord.Message) error {
+	var prefix = key(message.ChannelID)
+	var k = key(message.ChannelID, message.ID)
+
+	return s.DB.Update(func(tx *bolt.Tx) error {
+		var m discord.Message
+
+		switch err := get(tx, messagesBucket, k, &m); err {
+		case nil:
+			// Check if we already have the message.
+			state.MergeMessage(&m, message)
+			return put(tx, messagesBucket, k, &m)
+		case state.ErrStoreNotFound:
+		default:
+			return err
+		}
+
+		if err := put(tx, messagesBucket, k, message); err != nil {
+			return err
+		}
+
+		// Drop the oldest messages if there are too many.
+		var count int
+		c := tx.Bucket(messagesBucket).Cursor()
+
+		for k, _ := c.Seek(prefix); bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+			count++
+		}
+
+		for ; count > s.maxMessages; count-- {
+			if k, _ := c.Seek(prefix); k != nil {
+				if err := c.Delete(); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+}
+
+func (s *Store) MessageRemove(channelID, messageID discord.Snowflake) error {
+	return s.DB.Update(func(tx *bolt.Tx) error {
+		return remove(tx, messagesBucket, key(channelID, messageID))
+	})
+}
+
+////
+
+func (s *Store) Presence(
+	guildID, userID discord.Snowflake) (*discord.Presence, error) {
+
+	var p discord.Presence
+
+	err := s.DB.View(func(tx *bolt.Tx) error {
+		return get(tx, presencesBucket, key(guildID, userID), &p)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &p, nil
+}
+
+func (s *Store) Presences(
+	guildID discord.Snowflake) ([]discord.Presence, error) {
+
+	var ps []discord.Presence
+
+	err := s.getAll(presencesBucket, key(guildID), func(b []byte) error {
+		var p discord.Presence
+		if err := decode(b, &p); err != nil {
+			return err
+		}
+
+		ps = append(ps, p)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ps, nil
+}
+
+func (s *Store) PresenceSet(
+	guildID discord.Snowflake, presence *discord.Presence) error {
+
+	return s.DB.Update(func(tx *bolt.Tx) error {
+		k := key(guildID, presence.User.ID)
+		return put(tx, presencesBucket, k, presence)
+	})
+}
+
+// PresencesSet sets all the presences in one transaction.
+func (s *Store) PresencesSet(
+	guildID discord.Snowflake, presences []discord.Presence) error {
+
+	return s.DB.Update(func(tx *bolt.Tx) error {
+		for i := range presences {
+			p := &presences[i]
+			if err := put(tx, presencesBucket, key(guildID, p.User.ID), p); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *Store) PresenceRemove(guildID, userID discord.Snowflake) error {
+	return s.DB.Update(func(tx *bolt.Tx) error {
+		return remove(tx, presencesBucket, key(guildID, userID))
+	})
+}
+
+////
+
+func (s *Store) Role(guildID, roleID discord.Snowflake) (*discord.Role, error) {
+	var r discord.Role
+
+	err := s.DB.View(func(tx *bolt.Tx) error {
+		if err := guildExists(tx, guildID); err != nil {
+			return err
+		}
+
+		return get(tx, rolesBucket, key(guildID, roleID), &r)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &r, nil
+}
+
+func (s *Store) Roles(guildID discord.Snowflake) ([]discord.Role, error) {
+	var rs []discord.Role
+
+	err := s.DB.View(func(tx *bolt.Tx) error {
+		if err := guildExists(tx, guildID); err != nil {
+			return err
+		}
+
+		return each(tx, rolesBucket, key(guildID), func(b []byte) error {
+			var r discord.Role
+			if err := decode(b, &r); err != nil {
+				return errors.Wrap(err, "Failed to decode role")
+			}
+
+			rs = append(rs, r)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return rs, nil
+}
+
+func (s *Store) RoleSet(guildID discord.Snowflake, role *discord.Role) error {
+	return s.DB.Update(func(tx *bolt.Tx) error {
+		if err := guildExists(tx, guildID); err != nil {
+			return err
+		}
+
+		return put(tx, rolesBucket, key(guildID, role.ID), role)
+	})
+}
+
+func (s *Store) RoleRemove(guildID, roleID discord.Snowflake) error {
+	return s.DB.Update(func(tx *bolt.Tx) error {
+		return remove(tx, rolesBucket, key(guildID, roleID))
+	})
+}
+
+////
+
+func (s *Store) VoiceState(
+	guildID, userID discord.Snowflake) (*discord.VoiceState, error) {
+
+	var vs discord.VoiceState
+
+	err := s.DB.View(func(tx *bolt.Tx) error {
+		return get(tx, voiceStatesBucket, key(guildID, userID), &vs)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &vs, nil
+}
+
+func (s *Store) VoiceStates(
+	guildID discord.Snowflake) ([]discord.VoiceState, error) {
+
+	var states []discord.VoiceState
+
+	err := s.getAll(voiceStatesBucket, key(guildID), func(b []byte) error {
+		var vs discord.VoiceState
+		if err := decode(b, &vs); err != nil {
+			return err
+		}
+
+		states = append(states, vs)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return states, nil
+}
+
+func (s *Store) VoiceStateSet(
+	guildID discord.Snowflake, voiceState *discord.VoiceState) error {
+
+	return s.DB.Update(func(tx *bolt.Tx) error {
+		k := key(guildID, voiceState.UserID)
+		return put(tx, voiceStatesBucket, k, voiceState)
+	})
+}
+
+func (s *Store) VoiceStateRemove(guildID, userID discord.Snowflake) error {
+	return s.DB.Update(func(tx *bolt.Tx) error {
+		return remove(tx, voiceStatesBucket, key(guildID, userID))
+	})
+}
@@ -0,0 +1,120 @@
+// +build unit
+
+package boltstore
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/diamondburned/arikawa/discord"
+	"github.com/diamondburned/arikawa/state"
+	"github.com/diamondburned/arikawa/state/storetest"
+)
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "arikawa-boltstore")
+	if err != nil {
+		t.Fatal("Failed to create temp dir:", err)
+	}
+	return dir
+}
+
+func TestStore(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	var i int
+
+	storetest.TestStore(t, func(t *testing.T) state.Store {
+		i++
+
+		s, err := Open(filepath.Join(dir, string(rune('a'+i))+".db"), nil)
+		if err != nil {
+			t.Fatal("Failed to open:", err)
+		}
+
+		return s
+	})
+}
+
+func TestStorePersist(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "state.db")
+
+	s, err := Open(path, nil)
+	if err != nil {
+		t.Fatal("Failed to open:", err)
+	}
+
+	s.GuildSet(&discord.Guild{
+		ID:    1,
+		Name:  "guild",
+		Roles: []discord.Role{{ID: 10, Name: "role"}},
+	})
+	s.MemberSet(1, &discord.Member{User: discord.User{ID: 100}, Nick: "nick"})
+
+	if err := s.Close(); err != nil {
+		t.Fatal("Failed to close:", err)
+	}
+
+	s, err = Open(path, nil)
+	if err != nil {
+		t.Fatal("Failed to reopen:", err)
+	}
+	defer s.Close()
+
+	g, err := s.Guild(1)
+	if err != nil {
+		t.Fatal("Guild not persisted:", err)
+	}
+
+	if g.Name != "guild" || len(g.Roles) != 1 || g.Roles[0].Name != "role" {
+		t.Fatalf("Unexpected guild: %#v", g)
+	}
+
+	if m, err := s.Member(1, 100); err != nil || m.Nick != "nick" {
+		t.Fatal("Member not persisted:", m, err)
+	}
+}
+
+func TestStoreBatch(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	s, err := Open(filepath.Join(dir, "state.db"), nil)
+	if err != nil {
+		t.Fatal("Failed to open:", err)
+	}
+	defer s.Close()
+
+	var members = []discord.Member{
+		{User: discord.User{ID: 300}},
+		{User: discord.User{ID: 100}},
+		{User: discord.User{ID: 200}},
+	}
+
+	if err := s.MembersSet(1, members); err != nil {
+		t.Fatal("Failed to set members:", err)
+	}
+
+	if ms, err := s.Members(1); err != nil || len(ms) != 3 || ms[0].User.ID != 100 {
+		t.Fatal("Unexpected members:", ms, err)
+	}
+
+	var presences = []discord.Presence{
+		{User: discord.User{ID: 100}, Status: discord.IdleStatus},
+		{User: discord.User{ID: 200}},
+	}
+
+	if err := s.PresencesSet(1, presences); err != nil {
+		t.Fatal("Failed to set presences:", err)
+	}
+
+	if p, err := s.Presence(1, 100); err != nil || p.Status != discord.IdleStatus {
+		t.Fatal("Unexpected presence:", p, err)
+	}
+}
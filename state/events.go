@@ -0,0 +1,71 @@
+package state
+
+import (
+	"github.com/diamondburned/arikawa/discord"
+	"github.com/diamondburned/arikawa/gateway"
+)
+
+// These events are dispatched by the State after the Gateway update events
+// are applied to the Store. Old is the value in the Store before the update,
+// or nil if it wasn't there. New is the value after the update, which could
+// have fields the partial Gateway event doesn't have.
+type (
+	// MemberUpdateEvent is dispatched after *gateway.GuildMemberUpdateEvent.
+	MemberUpdateEvent struct {
+		GuildID discord.Snowflake
+		Old     *discord.Member
+		New     discord.Member
+	}
+
+	// MessageUpdateEvent is dispatched after *gateway.MessageUpdateEvent.
+	MessageUpdateEvent struct {
+		Old *discord.Message
+		New discord.Message
+	}
+
+	// ChannelUpdateEvent is dispatched after *gateway.ChannelUpdateEvent.
+	ChannelUpdateEvent struct {
+		Old *discord.Channel
+		New discord.Channel
+	}
+
+	// GuildUpdateEvent is dispatched after *gateway.GuildUpdateEvent.
+	GuildUpdateEvent struct {
+		Old *discord.Guild
+		New discord.Guild
+	}
+
+	// RoleUpdateEvent is dispatched after *gateway.GuildRoleUpdateEvent.
+	RoleUpdateEvent struct {
+		GuildID discord.Snowflake
+		Old     *discord.Role
+		New     discord.Role
+	}
+)
+
+// These events are dispatched by the State after Guild Create and Guild Delete,
+// telling apart guilds that are joined or left from guilds that are loaded
+// after Ready or go down.
+type (
+	// GuildJoinEvent is dispatched when the user joins a new guild.
+	GuildJoinEvent struct {
+		*gateway.GuildCreateEvent
+	}
+
+	// GuildAvailableEvent is dispatched when a guild in Ready is loaded, or
+	// when a guild is back after an outage.
+	GuildAvailableEvent struct {
+		*gateway.GuildCreateEvent
+	}
+
+	// GuildUnavailableEvent is dispatched when a guild goes down.
+	GuildUnavailableEvent struct {
+		*gateway.GuildDeleteEvent
+	}
+
+	// GuildLeaveEvent is dispatched when the user leaves or is removed from a
+	// guild.
+	GuildLeaveEvent struct {
+		*gateway.GuildDeleteEvent
+	}
+)
@@ -0,0 +1,332 @@
+package state
+
+import (
+	"container/list"
+	"time"
+
+	"github.com/diamondburned/arikawa/discord"
+)
+
+// lruKey identifies an entry. Entities that are unique by themselves, like
+// guilds and channels, only use ID.
+type lruKey struct {
+	parent discord.Snowflake
+	id     discord.Snowflake
+}
+
+type lruEntry struct {
+	key    lruKey
+	parent discord.Snowflake // used to list entries, e.g. channels in a guild
+	value  interface{}
+	expiry time.Time // zero if it never expires
+}
+
+// lruCache is a least-recently-used cache with an optional TTL. It's not
+// thread-safe, the ExpiryStore locks around it.
+type lruCache struct {
+	ExpiryOptions
+
+	list    *list.List // front is the most recently used
+	items   map[lruKey]*list.Element
+	parents map[discord.Snowflake]map[lruKey]*list.Element
+
+	// missing has the evicted children of each parent. Its list is incomplete
+	// until they're all set or removed again.
+	missing map[discord.Snowflake]map[lruKey]struct{}
+
+	// latest is true if lists only have the latest children, like messages,
+	// so missing children older than all cached ones don't leave a gap.
+	latest bool
+
+	lastSweep time.Time
+	stats     ExpiryStats
+}
+
+func newLRUCache(opts ExpiryOptions) *lruCache {
+	c := &lruCache{ExpiryOptions: opts}
+	c.reset()
+	return c
+}
+
+func (c *lruCache) reset() {
+	c.list = list.New()
+	c.items = map[lruKey]*list.Element{}
+	c.parents = map[discord.Snowflake]map[lruKey]*list.Element{}
+	c.missing = map[discord.Snowflake]map[lruKey]struct{}{}
+}
+
+func (c *lruCache) expired(e *lruEntry, now time.Time) bool {
+	return !e.expiry.IsZero() && !now.Before(e.expiry)
+}
+
+// get returns the value and marks it as recently used. Hits and misses are
+// counted.
+func (c *lruCache) get(key lruKey, now time.Time) (interface{}, bool) {
+	v, ok := c.lookup(key, now)
+	if !ok {
+		c.stats.Misses++
+		return nil, false
+	}
+
+	c.stats.Hits++
+	c.list.MoveToFront(c.items[key])
+	return v, true
+}
+
+// lookup returns the value without touching the stats or the LRU order, which
+// is used to modify an entry in place.
+func (c *lruCache) lookup(key lruKey, now time.Time) (interface{}, bool) {
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+
+	e := el.Value.(*lruEntry)
+	if c.expired(e, now) {
+		c.evict(el)
+		return nil, false
+	}
+
+	return e.value, true
+}
+
+// children returns all values with the given parent, marking them as recently
+// used. A hit is counted if there's any. It returns false if some of the
+// children were evicted, in which case the rest are kept, so that the list is
+// complete again once the evicted ones are set.
+func (c *lruCache) children(
+	parent discord.Snowflake, now time.Time) ([]interface{}, bool) {
+
+	els := c.parents[parent]
+	values := make([]interface{}, 0, len(els))
+
+	for _, el := range els {
+		e := el.Value.(*lruEntry)
+		if c.expired(e, now) {
+			c.evict(el)
+			continue
+		}
+
+		values = append(values, e.value)
+	}
+
+	if c.latest {
+		c.trimMissing(parent)
+	}
+
+	if len(c.missing[parent]) > 0 {
+		c.stats.Misses++
+		return nil, false
+	}
+
+	for _, el := range els {
+		c.list.MoveToFront(el)
+	}
+
+	if len(values) == 0 {
+		c.stats.Misses++
+	} else {
+		c.stats.Hits++
+	}
+
+	return values, true
+}
+
+// set adds or replaces the value, resetting its TTL. The least recently used
+// entries are evicted if the cache is over capacity.
+func (c *lruCache) set(key lruKey, parent discord.Snowflake, v interface{}, now time.Time) {
+	var expiry time.Time
+	if c.TTL > 0 {
+		expiry = now.Add(c.TTL)
+	}
+
+	c.found(key)
+
+	if el, ok := c.items[key]; ok {
+		e := el.Value.(*lruEntry)
+		e.value = v
+		e.expiry = expiry
+		c.list.MoveToFront(el)
+
+		// The entity could've moved, such as a channel to another guild.
+		if e.parent != parent {
+			c.unlinkParent(e)
+			e.parent = parent
+			c.linkParent(el)
+		}
+
+		return
+	}
+
+	el := c.list.PushFront(&lruEntry{
+		key:    key,
+		parent: parent,
+		value:  v,
+		expiry: expiry,
+	})
+	c.items[key] = el
+	c.linkParent(el)
+
+	if c.Capacity > 0 {
+		for c.list.Len() > c.Capacity {
+			c.evict(c.list.Back())
+		}
+	}
+
+	c.sweep(now)
+}
+
+// remove deletes the value. It returns false if there's nothing to remove.
+func (c *lruCache) remove(key lruKey) bool {
+	// An evicted entry that's removed isn't missing from the list anymore.
+	c.found(key)
+
+	el, ok := c.items[key]
+	if !ok {
+		return false
+	}
+
+	c.delete(el)
+	return true
+}
+
+// sweep evicts all expired entries once every TTL, so entries that are never
+// accessed again don't stay in memory.
+func (c *lruCache) sweep(now time.Time) {
+	if c.TTL <= 0 || now.Sub(c.lastSweep) < c.TTL {
+		return
+	}
+
+	c.lastSweep = now
+
+	for el := c.list.Front(); el != nil; {
+		next := el.Next()
+		if c.expired(el.Value.(*lruEntry), now) {
+			c.evict(el)
+		}
+		el = next
+	}
+}
+
+// evict deletes an expired or least recently used entry, which makes the list
+// of its parent incomplete.
+func (c *lruCache) evict(el *list.Element) {
+	c.trim(el)
+
+	e := el.Value.(*lruEntry)
+
+	keys, ok := c.missing[e.parent]
+	if !ok {
+		keys = map[lruKey]struct{}{}
+		c.missing[e.parent] = keys
+	}
+	keys[e.key] = struct{}{}
+}
+
+// found marks an evicted entry as no longer missing from the list of its
+// parent.
+func (c *lruCache) found(key lruKey) {
+	// Keys with a parent are always listed under it. Others, like channels,
+	// have to be looked up.
+	if key.parent != 0 {
+		c.unmiss(key, key.parent)
+		return
+	}
+
+	for parent, keys := range c.missing {
+		if _, ok := keys[key]; ok {
+			c.unmiss(key, parent)
+			return
+		}
+	}
+}
+
+func (c *lruCache) unmiss(key lruKey, parent discord.Snowflake) {
+	keys, ok := c.missing[parent]
+	if !ok {
+		return
+	}
+
+	delete(keys, key)
+
+	if len(keys) == 0 {
+		delete(c.missing, parent)
+	}
+}
+
+// trimMissing forgets the evicted children of parent that are older than all
+// cached ones.
+func (c *lruCache) trimMissing(parent discord.Snowflake) {
+	keys, ok := c.missing[parent]
+	if !ok {
+		return
+	}
+
+	var oldest discord.Snowflake
+	for k := range c.parents[parent] {
+		if oldest == 0 || k.id < oldest {
+			oldest = k.id
+		}
+	}
+
+	for k := range keys {
+		if k.id < oldest {
+			c.unmiss(k, parent)
+		}
+	}
+}
+
+// trim deletes an entry that's dropped on purpose, such as the oldest message
+// of a channel, so the list of its parent is still complete.
+func (c *lruCache) trim(el *list.Element) {
+	c.stats.Evictions++
+	c.delete(el)
+}
+
+func (c *lruCache) delete(el *list.Element) {
+	e := el.Value.(*lruEntry)
+
+	c.list.Remove(el)
+	delete(c.items, e.key)
+	c.unlinkParent(e)
+}
+
+// removeParent deletes all entries with the given parent, along with the ones
+// missing from its list.
+func (c *lruCache) removeParent(parent discord.Snowflake) {
+	for _, el := range c.parents[parent] {
+		c.delete(el)
+	}
+
+	delete(c.missing, parent)
+}
+
+func (c *lruCache) linkParent(el *list.Element) {
+	e := el.Value.(*lruEntry)
+
+	els, ok := c.parents[e.parent]
+	if !ok {
+		els = map[lruKey]*list.Element{}
+		c.parents[e.parent] = els
+	}
+	els[e.key] = el
+}
+
+func (c *lruCache) unlinkParent(e *lruEntry) {
+	els, ok := c.parents[e.parent]
+	if !ok {
+		return
+	}
+
+	delete(els, e.key)
+
+	if len(els) == 0 {
+		delete(c.parents, e.parent)
+	}
+}
+
+func (c *lruCache) Stats() ExpiryStats {
+	stats := c.stats
+	stats.Size = c.list.Len()
+	return stats
+}
@@ -4,6 +4,7 @@ package state
 
 import (
 	"sync"
+	"time"
 
 	"github.com/diamondburned/arikawa/discord"
 	"github.com/diamondburned/arikawa/gateway"
@@ -23,8 +24,10 @@ type State struct {
 
 	// *: State doesn't actually keep track of pinned messages.
 
-	// Ready is not updated by the state.
-	Ready gateway.ReadyEvent
+	// Ready is updated by the relationship, note and settings events of user
+	// accounts. Use ReadyEvent to read it while events are being handled.
+	Ready           gateway.ReadyEvent
+	readyEventMutex sync.RWMutex
 
 	// StateLog logs all errors that come from the state cache. This includes
 	// not found errors. Defaults to a no-op, as state errors aren't that
@@ -36,12 +39,44 @@ type State struct {
 	// It's recommended to set Synchronous to true if you mutate the events.
 	PreHandler *handler.Handler // default nil
 
+	// CacheOnly disables fetching what's missing from the Store with the API.
+	// Getters return the Store errors instead.
+	CacheOnly bool
+
+	// NegativeCacheTTL is how long a 404 from an API fallback is remembered,
+	// so getters for deleted things don't call the API every time. 0 disables
+	// it.
+	NegativeCacheTTL time.Duration
+
 	unhooker func()
 
 	// List of channels with few messages, so it doesn't bother hitting the API
 	// again.
 	fewMessages []discord.Snowflake
 	fewMutex    sync.Mutex
+
+	// Pending RequestMembers calls, keyed by their nonces.
+	memberRequests map[string]*memberRequest
+	memberMutex    sync.Mutex
+	memberNonce    uint64
+
+	// Guilds in Ready that aren't loaded yet, and guilds that are down. ready
+	// is closed once unreadyGuilds is empty.
+	unreadyGuilds     map[discord.Snowflake]struct{}
+	unavailableGuilds map[discord.Snowflake]struct{}
+	ready             chan struct{}
+	readyDone         bool
+	readyMutex        sync.Mutex
+
+	// API fallbacks in flight and cached 404s, keyed by fetchKey.
+	fetching   map[string]*fetchCall
+	missing    map[string]missing
+	fetchMutex sync.Mutex
+
+	// Events made by the State that are waiting to be dispatched.
+	dispatchQueue []interface{}
+	dispatching   bool
+	dispatchMutex sync.Mutex
 }
 
 func NewFromSession(s *session.Session, store Store) (*State, error) {
@@ -69,6 +104,15 @@ func NewWithStore(token string, store Store) (*State, error) {
 	return NewFromSession(s, store)
 }
 
+// ReadyEvent returns a copy of Ready. It's safe to call while events are being
+// handled.
+func (s *State) ReadyEvent() gateway.ReadyEvent {
+	s.readyEventMutex.RLock()
+	defer s.readyEventMutex.RUnlock()
+
+	return s.Ready
+}
+
 // Unhook removes all state handlers from the session handlers.
 func (s *State) Unhook() {
 	s.unhooker()
@@ -157,12 +201,19 @@ func (s *State) Me() (*discord.User, error) {
 		return u, nil
 	}
 
-	u, err = s.Session.Me()
+	v, err := s.fetch("me", func() (interface{}, error) {
+		u, err := s.Session.Me()
+		if err != nil {
+			return nil, err
+		}
+
+		return u, s.Store.MyselfSet(u)
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	return u, s.Store.MyselfSet(u)
+	return v.(*discord.User), nil
 }
 
 ////
@@ -173,12 +224,19 @@ func (s *State) Channel(id discord.Snowflake) (*discord.Channel, error) {
 		return c, nil
 	}
 
-	c, err = s.Session.Channel(id)
+	v, err := s.fetch(fetchKey("channel", id), func() (interface{}, error) {
+		c, err := s.Session.Channel(id)
+		if err != nil {
+			return nil, err
+		}
+
+		return c, s.Store.ChannelSet(c)
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	return c, s.Store.ChannelSet(c)
+	return v.(*discord.Channel), nil
 }
 
 func (s *State) Channels(guildID discord.Snowflake) ([]discord.Channel, error) {
@@ -187,20 +245,27 @@ func (s *State) Channels(guildID discord.Snowflake) ([]discord.Channel, error) {
 		return c, nil
 	}
 
-	c, err = s.Session.Channels(guildID)
-	if err != nil {
-		return nil, err
-	}
+	v, err := s.fetch(fetchKey("channels", guildID), func() (interface{}, error) {
+		c, err := s.Session.Channels(guildID)
+		if err != nil {
+			return nil, err
+		}
 
-	for _, ch := range c {
-		ch := ch
+		for _, ch := range c {
+			ch := ch
 
-		if err := s.Store.ChannelSet(&ch); err != nil {
-			return nil, err
+			if err := s.Store.ChannelSet(&ch); err != nil {
+				return nil, err
+			}
 		}
+
+		return c, nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return c, nil
+	return v.([]discord.Channel), nil
 }
 
 ////
@@ -213,15 +278,11 @@ func (s *State) Emoji(
 		return e, nil
 	}
 
-	es, err := s.Session.Emojis(guildID)
+	es, err := s.fetchEmojis(guildID)
 	if err != nil {
 		return nil, err
 	}
 
-	if err := s.Store.EmojiSet(guildID, es); err != nil {
-		return nil, err
-	}
-
 	for _, e := range es {
 		if e.ID == emojiID {
 			return &e, nil
@@ -237,12 +298,23 @@ func (s *State) Emojis(guildID discord.Snowflake) ([]discord.Emoji, error) {
 		return e, nil
 	}
 
-	es, err := s.Session.Emojis(guildID)
+	return s.fetchEmojis(guildID)
+}
+
+func (s *State) fetchEmojis(guildID discord.Snowflake) ([]discord.Emoji, error) {
+	v, err := s.fetch(fetchKey("emojis", guildID), func() (interface{}, error) {
+		es, err := s.Session.Emojis(guildID)
+		if err != nil {
+			return nil, err
+		}
+
+		return es, s.Store.EmojiSet(guildID, es)
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	return es, s.Store.EmojiSet(guildID, es)
+	return v.([]discord.Emoji), nil
 }
 
 ////
@@ -253,12 +325,19 @@ func (s *State) Guild(id discord.Snowflake) (*discord.Guild, error) {
 		return c, nil
 	}
 
-	c, err = s.Session.Guild(id)
+	v, err := s.fetch(fetchKey("guild", id), func() (interface{}, error) {
+		c, err := s.Session.Guild(id)
+		if err != nil {
+			return nil, err
+		}
+
+		return c, s.Store.GuildSet(c)
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	return c, s.Store.GuildSet(c)
+	return v.(*discord.Guild), nil
 }
 
 // Guilds will only fill a maximum of 100 guilds from the API.
@@ -268,20 +347,27 @@ func (s *State) Guilds() ([]discord.Guild, error) {
 		return c, nil
 	}
 
-	c, err = s.Session.Guilds(MaxFetchGuilds)
-	if err != nil {
-		return nil, err
-	}
+	v, err := s.fetch("guilds", func() (interface{}, error) {
+		c, err := s.Session.Guilds(MaxFetchGuilds)
+		if err != nil {
+			return nil, err
+		}
 
-	for _, ch := range c {
-		ch := ch
+		for _, ch := range c {
+			ch := ch
 
-		if err := s.Store.GuildSet(&ch); err != nil {
-			return nil, err
+			if err := s.Store.GuildSet(&ch); err != nil {
+				return nil, err
+			}
 		}
+
+		return c, nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return c, nil
+	return v.([]discord.Guild), nil
 }
 
 ////
@@ -294,12 +380,19 @@ func (s *State) Member(
 		return m, nil
 	}
 
-	m, err = s.Session.Member(guildID, userID)
+	v, err := s.fetch(fetchKey("member", guildID, userID), func() (interface{}, error) {
+		m, err := s.Session.Member(guildID, userID)
+		if err != nil {
+			return nil, err
+		}
+
+		return m, s.Store.MemberSet(guildID, m)
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	return m, s.Store.MemberSet(guildID, m)
+	return v.(*discord.Member), nil
 }
 
 func (s *State) Members(guildID discord.Snowflake) ([]discord.Member, error) {
@@ -308,21 +401,34 @@ func (s *State) Members(guildID discord.Snowflake) ([]discord.Member, error) {
 		return ms, nil
 	}
 
-	ms, err = s.Session.Members(guildID, MaxFetchMembers)
-	if err != nil {
-		return nil, err
-	}
-
-	for _, m := range ms {
-		if err := s.Store.MemberSet(guildID, &m); err != nil {
+	v, err := s.fetch(fetchKey("members", guildID), func() (interface{}, error) {
+		ms, err := s.Session.Members(guildID, MaxFetchMembers)
+		if err != nil {
 			return nil, err
 		}
-	}
 
-	return ms, s.Gateway.RequestGuildMembers(gateway.RequestGuildMembersData{
-		GuildID:   []discord.Snowflake{guildID},
-		Presences: true,
+		if b, ok := s.Store.(StoreBatcher); ok {
+			if err := b.MembersSet(guildID, ms); err != nil {
+				return nil, err
+			}
+		} else {
+			for _, m := range ms {
+				if err := s.Store.MemberSet(guildID, &m); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		return ms, s.Gateway.RequestGuildMembers(gateway.RequestGuildMembersData{
+			GuildID:   []discord.Snowflake{guildID},
+			Presences: true,
+		})
 	})
+	if err != nil {
+		return nil, err
+	}
+
+	return v.([]discord.Member), nil
 }
 
 ////
@@ -335,19 +441,28 @@ func (s *State) Message(
 		return m, nil
 	}
 
-	m, err = s.Session.Message(channelID, messageID)
+	key := fetchKey("message", channelID, messageID)
+
+	v, err := s.fetch(key, func() (interface{}, error) {
+		m, err := s.Session.Message(channelID, messageID)
+		if err != nil {
+			return nil, err
+		}
+
+		// Fill the GuildID, because Discord doesn't do it for us.
+		c, err := s.Channel(channelID)
+		if err == nil {
+			// If it's 0, it's 0 anyway. We don't need a check here.
+			m.GuildID = c.GuildID
+		}
+
+		return m, s.Store.MessageSet(m)
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	// Fill the GuildID, because Discord doesn't do it for us.
-	c, err := s.Channel(channelID)
-	if err == nil {
-		// If it's 0, it's 0 anyway. We don't need a check here.
-		m.GuildID = c.GuildID
-	}
-
-	return m, s.Store.MessageSet(m)
+	return v.(*discord.Message), nil
 }
 
 // Messages fetches maximum 100 messages from the API, if it has to. There is no
@@ -377,37 +492,53 @@ func (s *State) Messages(channelID discord.Snowflake) ([]discord.Message, error)
 		s.fewMutex.Unlock()
 	}
 
-	ms, err = s.Session.Messages(channelID, 100)
-	if err != nil {
-		return nil, err
+	// Return what the Store has, if it's all we could get.
+	if s.CacheOnly {
+		return ms, err
 	}
 
-	// New messages fetched weirdly does not have GuildID filled. We'll try and
-	// get it for consistency with incoming message creates.
-	var guildID discord.Snowflake
+	v, err := s.fetch(fetchKey("messages", channelID), func() (interface{}, error) {
+		ms, err := s.Session.Messages(channelID, 100)
+		if err != nil {
+			return nil, err
+		}
 
-	// A bit too convoluted, but whatever.
-	c, err := s.Channel(channelID)
-	if err == nil {
-		// If it's 0, it's 0 anyway. We don't need a check here.
-		guildID = c.GuildID
-	}
+		// New messages fetched weirdly does not have GuildID filled. We'll try
+		// and get it for consistency with incoming message creates.
+		var guildID discord.Snowflake
 
-	for i := range ms {
-		// Set the guild ID, fine if it's 0 (it's already 0 anyway).
-		ms[i].GuildID = guildID
+		// A bit too convoluted, but whatever.
+		c, err := s.Channel(channelID)
+		if err == nil {
+			// If it's 0, it's 0 anyway. We don't need a check here.
+			guildID = c.GuildID
+		}
 
-		if err := s.Store.MessageSet(&ms[i]); err != nil {
-			return nil, err
+		for i := range ms {
+			// Set the guild ID, fine if it's 0 (it's already 0 anyway).
+			ms[i].GuildID = guildID
+
+			if err := s.Store.MessageSet(&ms[i]); err != nil {
+				return nil, err
+			}
 		}
+
+		if len(ms) < maxMsgs {
+			// Tiny channel, store this.
+			s.fewMutex.Lock()
+			s.fewMessages = append(s.fewMessages, channelID)
+			s.fewMutex.Unlock()
+		}
+
+		return ms, nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	if len(ms) < maxMsgs {
-		// Tiny channel, store this.
-		s.fewMutex.Lock()
-		s.fewMessages = append(s.fewMessages, channelID)
-		s.fewMutex.Unlock()
+	ms = v.([]discord.Message)
 
+	if len(ms) < maxMsgs {
 		return ms, nil
 	}
 
@@ -440,26 +571,18 @@ func (s *State) Role(
 		return r, nil
 	}
 
-	rs, err := s.Session.Roles(guildID)
+	rs, err := s.fetchRoles(guildID)
 	if err != nil {
 		return nil, err
 	}
 
-	var role *discord.Role
-
 	for _, r := range rs {
-		r := r
-
 		if r.ID == roleID {
-			role = &r
-		}
-
-		if err := s.RoleSet(guildID, &r); err != nil {
-			return role, err
+			return &r, nil
 		}
 	}
 
-	return role, nil
+	return nil, ErrStoreNotFound
 }
 
 func (s *State) Roles(guildID discord.Snowflake) ([]discord.Role, error) {
@@ -468,18 +591,29 @@ func (s *State) Roles(guildID discord.Snowflake) ([]discord.Role, error) {
 		return rs, nil
 	}
 
-	rs, err = s.Session.Roles(guildID)
-	if err != nil {
-		return nil, err
-	}
+	return s.fetchRoles(guildID)
+}
 
-	for _, r := range rs {
-		r := r
+func (s *State) fetchRoles(guildID discord.Snowflake) ([]discord.Role, error) {
+	v, err := s.fetch(fetchKey("roles", guildID), func() (interface{}, error) {
+		rs, err := s.Session.Roles(guildID)
+		if err != nil {
+			return nil, err
+		}
 
-		if err := s.RoleSet(guildID, &r); err != nil {
-			return rs, err
+		for _, r := range rs {
+			r := r
+
+			if err := s.RoleSet(guildID, &r); err != nil {
+				return rs, err
+			}
 		}
+
+		return rs, nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return rs, nil
+	return v.([]discord.Role), nil
 }
@@ -18,12 +18,12 @@ func (s *State) hookSession() error {
 }
 
 func (s *State) onEvent(iface interface{}) {
-	// TODO: voice states
-
 	switch ev := iface.(type) {
 	case *gateway.ReadyEvent:
 		// Set Ready to the state
+		s.readyEventMutex.Lock()
 		s.Ready = *ev
+		s.readyEventMutex.Unlock()
 
 		// Handle guilds
 		for _, g := range ev.Guilds {
@@ -48,18 +48,15 @@ func (s *State) onEvent(iface interface{}) {
 			s.stateErr(err, "Failed to set self in state")
 		}
 
+		s.onReady(ev)
+
 	case *gateway.GuildCreateEvent:
 		if err := s.Store.GuildSet(&ev.Guild); err != nil {
 			s.stateErr(err, "Failed to create guild in state")
 		}
 
-		for _, m := range ev.Members {
-			m := m
-
-			if err := s.Store.MemberSet(ev.Guild.ID, &m); err != nil {
-				s.stateErr(err, "Failed to add a member from guild in state")
-			}
-		}
+		s.setMembers(ev.Guild.ID, ev.Members,
+			"Failed to add a member from guild in state")
 
 		for _, ch := range ev.Channels {
 			ch := ch
@@ -70,31 +67,67 @@ func (s *State) onEvent(iface interface{}) {
 			}
 		}
 
-		for _, p := range ev.Presences {
-			p := p
+		s.setPresences(ev.Guild.ID, ev.Presences,
+			"Failed to add a presence from guild in state")
+
+		for _, vs := range ev.VoiceStates {
+			vs := vs
+			vs.GuildID = ev.Guild.ID // not sent in Guild Create
 
-			if err := s.Store.PresenceSet(ev.Guild.ID, &p); err != nil {
-				s.stateErr(err, "Failed to add a presence from guild in state")
+			if err := s.Store.VoiceStateSet(ev.Guild.ID, &vs); err != nil {
+				s.stateErr(err, "Failed to add a voice state from guild in state")
 			}
 		}
+
+		// The guild and what's in it could have been cached as missing before
+		// the bot joined.
+		s.forget(
+			fetchKey("guild", ev.Guild.ID),
+			fetchKey("channels", ev.Guild.ID),
+			fetchKey("emojis", ev.Guild.ID),
+			fetchKey("members", ev.Guild.ID),
+			fetchKey("roles", ev.Guild.ID),
+		)
+
+		s.dispatch(s.onGuildCreate(ev))
 	case *gateway.GuildUpdateEvent:
+		old, _ := s.Store.Guild(ev.ID)
+
 		if err := s.Store.GuildSet((*discord.Guild)(ev)); err != nil {
 			s.stateErr(err, "Failed to update guild in state")
 		}
+
+		var update = &GuildUpdateEvent{Old: old, New: discord.Guild(*ev)}
+		if g, err := s.Store.Guild(ev.ID); err == nil {
+			update.New = *g
+		}
+
+		s.dispatch(update)
 	case *gateway.GuildDeleteEvent:
 		if err := s.Store.GuildRemove(ev.ID); err != nil {
 			s.stateErr(err, "Failed to delete guild in state")
 		}
 
+		s.dispatch(s.onGuildDelete(ev))
+
 	case *gateway.GuildMemberAddEvent:
 		if err := s.Store.MemberSet(ev.GuildID, &ev.Member); err != nil {
 			s.stateErr(err, "Failed to add a member in state")
 		}
+
+		// The member could have been cached as missing before rejoining.
+		s.forget(fetchKey("member", ev.GuildID, ev.Member.User.ID))
 	case *gateway.GuildMemberUpdateEvent:
 		m, err := s.Store.Member(ev.GuildID, ev.User.ID)
+		var old *discord.Member
+
 		if err != nil {
 			// We can't do much here.
 			m = &discord.Member{}
+		} else {
+			// Copy the old member, as m is updated in place.
+			o := *m
+			old = &o
 		}
 
 		// Update available fields from ev into m
@@ -103,36 +136,45 @@ func (s *State) onEvent(iface interface{}) {
 		if err := s.Store.MemberSet(ev.GuildID, m); err != nil {
 			s.stateErr(err, "Failed to update a member in state")
 		}
+
+		s.dispatch(&MemberUpdateEvent{
+			GuildID: ev.GuildID,
+			Old:     old,
+			New:     *m,
+		})
 	case *gateway.GuildMemberRemoveEvent:
 		if err := s.Store.MemberRemove(ev.GuildID, ev.User.ID); err != nil {
 			s.stateErr(err, "Failed to remove a member in state")
 		}
 
 	case *gateway.GuildMembersChunkEvent:
-		for _, m := range ev.Members {
-			m := m
-
-			if err := s.Store.MemberSet(ev.GuildID, &m); err != nil {
-				s.stateErr(err, "Failed to add a member from chunk in state")
-			}
-		}
+		s.setMembers(ev.GuildID, ev.Members,
+			"Failed to add a member from chunk in state")
+		s.setPresences(ev.GuildID, ev.Presences,
+			"Failed to add a presence from chunk in state")
 
-		for _, p := range ev.Presences {
-			p := p
-
-			if err := s.Store.PresenceSet(ev.GuildID, &p); err != nil {
-				s.stateErr(err, "Failed to add a presence from chunk in state")
-			}
+		if ev.Nonce != "" {
+			s.collectMembers(ev)
 		}
 
 	case *gateway.GuildRoleCreateEvent:
 		if err := s.Store.RoleSet(ev.GuildID, &ev.Role); err != nil {
 			s.stateErr(err, "Failed to add a role in state")
 		}
+
+		s.forget(fetchKey("roles", ev.GuildID))
 	case *gateway.GuildRoleUpdateEvent:
+		old, _ := s.Store.Role(ev.GuildID, ev.Role.ID)
+
 		if err := s.Store.RoleSet(ev.GuildID, &ev.Role); err != nil {
 			s.stateErr(err, "Failed to update a role in state")
 		}
+
+		s.dispatch(&RoleUpdateEvent{
+			GuildID: ev.GuildID,
+			Old:     old,
+			New:     ev.Role,
+		})
 	case *gateway.GuildRoleDeleteEvent:
 		if err := s.Store.RoleRemove(ev.GuildID, ev.RoleID); err != nil {
 			s.stateErr(err, "Failed to remove a role in state")
@@ -147,10 +189,21 @@ func (s *State) onEvent(iface interface{}) {
 		if err := s.Store.ChannelSet((*discord.Channel)(ev)); err != nil {
 			s.stateErr(err, "Failed to create a channel in state")
 		}
+
+		s.forget(fetchKey("channel", ev.ID))
 	case *gateway.ChannelUpdateEvent:
+		old, _ := s.Store.Channel(ev.ID)
+
 		if err := s.Store.ChannelSet((*discord.Channel)(ev)); err != nil {
 			s.stateErr(err, "Failed to update a channel in state")
 		}
+
+		var update = &ChannelUpdateEvent{Old: old, New: discord.Channel(*ev)}
+		if ch, err := s.Store.Channel(ev.ID); err == nil {
+			update.New = *ch
+		}
+
+		s.dispatch(update)
 	case *gateway.ChannelDeleteEvent:
 		if err := s.Store.ChannelRemove((*discord.Channel)(ev)); err != nil {
 			s.stateErr(err, "Failed to remove a channel in state")
@@ -158,14 +211,62 @@ func (s *State) onEvent(iface interface{}) {
 
 		// *gateway.ChannelPinsUpdateEvent is not tracked.
 
+	case *gateway.ChannelRecipientAddEvent:
+		ch, err := s.Store.Channel(ev.ChannelID)
+		if err != nil {
+			s.stateErr(err, "Failed to get a channel for a recipient in state")
+			return
+		}
+
+		// Cap the slice, so append doesn't write into the store's array.
+		ch.DMRecipients = append(
+			ch.DMRecipients[:len(ch.DMRecipients):len(ch.DMRecipients)],
+			ev.User,
+		)
+
+		if err := s.Store.ChannelSet(ch); err != nil {
+			s.stateErr(err, "Failed to add a channel recipient in state")
+		}
+	case *gateway.ChannelRecipientRemoveEvent:
+		ch, err := s.Store.Channel(ev.ChannelID)
+		if err != nil {
+			s.stateErr(err, "Failed to get a channel for a recipient in state")
+			return
+		}
+
+		// Make a new slice, as the old one is shared with the store.
+		recipients := make([]discord.User, 0, len(ch.DMRecipients))
+		for _, u := range ch.DMRecipients {
+			if u.ID != ev.User.ID {
+				recipients = append(recipients, u)
+			}
+		}
+		ch.DMRecipients = recipients
+
+		if err := s.Store.ChannelSet(ch); err != nil {
+			s.stateErr(err, "Failed to remove a channel recipient in state")
+		}
+
+	case *gateway.InviteCreateEvent, *gateway.InviteDeleteEvent:
+		// Invites are not tracked, as the Store has nowhere to keep them.
+
 	case *gateway.MessageCreateEvent:
 		if err := s.Store.MessageSet((*discord.Message)(ev)); err != nil {
 			s.stateErr(err, "Failed to add a message in state")
 		}
 	case *gateway.MessageUpdateEvent:
+		old, _ := s.Store.Message(ev.ChannelID, ev.ID)
+
 		if err := s.Store.MessageSet((*discord.Message)(ev)); err != nil {
 			s.stateErr(err, "Failed to update a message in state")
 		}
+
+		var update = &MessageUpdateEvent{Old: old, New: discord.Message(*ev)}
+		if m, err := s.Store.Message(ev.ChannelID, ev.ID); err == nil {
+			update.New = *m
+		}
+
+		s.dispatch(update)
 	case *gateway.MessageDeleteEvent:
 		if err := s.Store.MessageRemove(ev.ChannelID, ev.ID); err != nil {
 			s.stateErr(err, "Failed to delete a message in state")
@@ -177,15 +278,286 @@ func (s *State) onEvent(iface interface{}) {
 			}
 		}
 
+	case *gateway.MessageReactionAddEvent:
+		s.editMessage(ev.ChannelID, ev.MessageID, func(m *discord.Message) bool {
+			var me = s.isMe(ev.UserID)
+
+			if i := findReaction(m.Reactions, ev.Emoji); i > -1 {
+				m.Reactions[i].Count++
+				m.Reactions[i].Me = m.Reactions[i].Me || me
+			} else {
+				m.Reactions = append(m.Reactions, discord.Reaction{
+					Count: 1,
+					Me:    me,
+					Emoji: ev.Emoji,
+				})
+			}
+
+			return true
+		})
+	case *gateway.MessageReactionRemoveEvent:
+		s.editMessage(ev.ChannelID, ev.MessageID, func(m *discord.Message) bool {
+			var i = findReaction(m.Reactions, ev.Emoji)
+			if i < 0 {
+				return false
+			}
+
+			if m.Reactions[i].Count--; m.Reactions[i].Count <= 0 {
+				m.Reactions = append(m.Reactions[:i], m.Reactions[i+1:]...)
+				return true
+			}
+
+			if s.isMe(ev.UserID) {
+				m.Reactions[i].Me = false
+			}
+
+			return true
+		})
+	case *gateway.MessageReactionRemoveAllEvent:
+		s.editMessage(ev.ChannelID, ev.MessageID, func(m *discord.Message) bool {
+			m.Reactions = m.Reactions[:0]
+			return true
+		})
+	case *gateway.MessageReactionRemoveEmojiEvent:
+		s.editMessage(ev.ChannelID, ev.MessageID, func(m *discord.Message) bool {
+			var i = findReaction(m.Reactions, ev.Emoji)
+			if i < 0 {
+				return false
+			}
+
+			m.Reactions = append(m.Reactions[:i], m.Reactions[i+1:]...)
+			return true
+		})
+
 	case *gateway.PresenceUpdateEvent:
 		if err := s.Store.PresenceSet(
 			ev.GuildID, (*discord.Presence)(ev)); err != nil {
 
 			s.stateErr(err, "Failed to update presence in state")
 		}
+	case *gateway.PresencesReplaceEvent:
+		for _, p := range *ev {
+			p := p
+
+			if err := s.Store.PresenceSet(p.GuildID, &p); err != nil {
+				s.stateErr(err, "Failed to replace a presence in state")
+			}
+		}
+
+	case *gateway.VoiceStateUpdateEvent:
+		vs := (*discord.VoiceState)(ev)
+
+		// A voice state without a channel means the user left.
+		if !vs.ChannelID.Valid() {
+			if err := s.Store.VoiceStateRemove(vs.GuildID, vs.UserID); err != nil {
+				s.stateErr(err, "Failed to remove voice state from state")
+			}
+			return
+		}
+
+		if err := s.Store.VoiceStateSet(vs.GuildID, vs); err != nil {
+			s.stateErr(err, "Failed to update voice state in state")
+		}
+
+	case *gateway.RelationshipAddEvent:
+		s.editReady(func(r *gateway.ReadyEvent) {
+			rs := make([]gateway.Relationship, 0, len(r.Relationships)+1)
+			for _, rel := range r.Relationships {
+				if rel.ID != ev.ID {
+					rs = append(rs, rel)
+				}
+			}
+			r.Relationships = append(rs, gateway.Relationship(*ev))
+		})
+	case *gateway.RelationshipRemoveEvent:
+		s.editReady(func(r *gateway.ReadyEvent) {
+			rs := make([]gateway.Relationship, 0, len(r.Relationships))
+			for _, rel := range r.Relationships {
+				if rel.ID != ev.ID {
+					rs = append(rs, rel)
+				}
+			}
+			r.Relationships = rs
+		})
+
+	case *gateway.UserSettingsUpdateEvent:
+		s.editReady(func(r *gateway.ReadyEvent) {
+			var settings gateway.UserSettings
+			if r.Settings != nil {
+				settings = *r.Settings
+			}
+
+			if err := ev.Update(&settings); err != nil {
+				s.stateErr(err, "Failed to update user settings in state")
+				return
+			}
+
+			r.Settings = &settings
+		})
+	case *gateway.UserGuildSettingsUpdateEvent:
+		s.editReady(func(r *gateway.ReadyEvent) {
+			gs := make([]gateway.UserGuildSettings, 0, len(r.UserGuildSettings)+1)
+			for _, settings := range r.UserGuildSettings {
+				if settings.GuildID != ev.GuildID {
+					gs = append(gs, settings)
+				}
+			}
+			r.UserGuildSettings = append(gs, gateway.UserGuildSettings(*ev))
+		})
+	case *gateway.UserNoteUpdateEvent:
+		s.editReady(func(r *gateway.ReadyEvent) {
+			notes := make(map[discord.Snowflake]string, len(r.Notes)+1)
+			for id, note := range r.Notes {
+				notes[id] = note
+			}
+
+			// An empty note means it was removed.
+			if ev.Note == "" {
+				delete(notes, ev.ID)
+			} else {
+				notes[ev.ID] = ev.Note
+			}
+
+			r.Notes = notes
+		})
+	}
+}
+
+// editReady calls fn with Ready locked. Slices and maps in Ready could be
+// shared with copies returned by ReadyEvent, so fn has to replace them instead
+// of modifying them.
+func (s *State) editReady(fn func(r *gateway.ReadyEvent)) {
+	s.readyEventMutex.Lock()
+	defer s.readyEventMutex.Unlock()
+
+	fn(&s.Ready)
+}
+
+// dispatch queues an event made by the State for the Session handlers. The
+// State handler runs inside Handler.Call, which holds the handlers' read lock,
+// so calling it again there could deadlock with AddHandler. The events are
+// called from another goroutine instead, in order.
+func (s *State) dispatch(ev interface{}) {
+	if s.Session == nil || s.Handler == nil {
+		return
+	}
+
+	s.dispatchMutex.Lock()
+	defer s.dispatchMutex.Unlock()
+
+	s.dispatchQueue = append(s.dispatchQueue, ev)
+
+	if !s.dispatching {
+		s.dispatching = true
+		go s.drainDispatch()
+	}
+}
+
+func (s *State) drainDispatch() {
+	for {
+		s.dispatchMutex.Lock()
+
+		if len(s.dispatchQueue) == 0 {
+			s.dispatchQueue = nil
+			s.dispatching = false
+			s.dispatchMutex.Unlock()
+			return
+		}
+
+		ev := s.dispatchQueue[0]
+		s.dispatchQueue = s.dispatchQueue[1:]
+
+		s.dispatchMutex.Unlock()
+
+		s.Handler.Call(ev)
 	}
 }
 
 func (s *State) stateErr(err error, wrap string) {
 	s.ErrorLog(errors.Wrap(err, wrap))
 }
+
+// setMembers sets the members in one call if the Store is a StoreBatcher.
+func (s *State) setMembers(
+	guildID discord.Snowflake, members []discord.Member, wrap string) {
+
+	if b, ok := s.Store.(StoreBatcher); ok {
+		if err := b.MembersSet(guildID, members); err != nil {
+			s.stateErr(err, wrap)
+		}
+		return
+	}
+
+	for _, m := range members {
+		m := m
+
+		if err := s.Store.MemberSet(guildID, &m); err != nil {
+			s.stateErr(err, wrap)
+		}
+	}
+}
+
+// setPresences sets the presences in one call if the Store is a
+// StoreBatcher.
+func (s *State) setPresences(
+	guildID discord.Snowflake, presences []discord.Presence, wrap string) {
+
+	if b, ok := s.Store.(StoreBatcher); ok {
+		if err := b.PresencesSet(guildID, presences); err != nil {
+			s.stateErr(err, wrap)
+		}
+		return
+	}
+
+	for _, p := range presences {
+		p := p
+
+		if err := s.Store.PresenceSet(guildID, &p); err != nil {
+			s.stateErr(err, wrap)
+		}
+	}
+}
+
+// editMessage calls fn with the message in the store and saves it if fn
+// returns true. Nothing is done if the message isn't in the store. The
+// reactions are copied, so fn could modify them without touching the slice
+// shared with the store.
+func (s *State) editMessage(
+	channelID, messageID discord.Snowflake, fn func(m *discord.Message) bool) {
+
+	m, err := s.Store.Message(channelID, messageID)
+	if err != nil {
+		return
+	}
+
+	m.Reactions = append([]discord.Reaction{}, m.Reactions...)
+
+	if !fn(m) {
+		return
+	}
+
+	if err := s.Store.MessageSet(m); err != nil {
+		s.stateErr(err, "Failed to update reactions in state")
+	}
+}
+
+func (s *State) isMe(userID discord.Snowflake) bool {
+	me, err := s.Store.Me()
+	return err == nil && me.ID == userID
+}
+
+// findReaction returns the index of the reaction with the emoji, or -1.
+func findReaction(reactions []discord.Reaction, emoji discord.Emoji) int {
+	for i, r := range reactions {
+		if r.Emoji.ID != emoji.ID {
+			continue
+		}
+
+		// Unicode emojis don't have IDs.
+		if emoji.ID.Valid() || r.Emoji.Name == emoji.Name {
+			return i
+		}
+	}
+
+	return -1
+}
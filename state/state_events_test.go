@@ -0,0 +1,406 @@
+// +build unit
+
+package state
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/diamondburned/arikawa/discord"
+	"github.com/diamondburned/arikawa/gateway"
+	"github.com/diamondburned/arikawa/handler"
+	"github.com/diamondburned/arikawa/internal/json"
+	"github.com/diamondburned/arikawa/session"
+)
+
+func TestVoiceStateEvents(t *testing.T) {
+	s := &State{
+		Store:    NewDefaultStore(nil),
+		StateLog: func(error) {},
+	}
+
+	s.onEvent(&gateway.GuildCreateEvent{
+		Guild: discord.Guild{ID: 1},
+		VoiceStates: []discord.VoiceState{
+			{ChannelID: 10, UserID: 100},
+			{ChannelID: 10, UserID: 200},
+		},
+	})
+
+	vs, err := s.VoiceState(1, 100)
+	if err != nil {
+		t.Fatal("Voice state not seeded from Guild Create:", err)
+	}
+
+	if vs.GuildID != 1 || vs.ChannelID != 10 {
+		t.Fatalf("Unexpected voice state: %#v", vs)
+	}
+
+	// Moving to another channel.
+	s.onEvent(&gateway.VoiceStateUpdateEvent{
+		GuildID: 1, ChannelID: 20, UserID: 100,
+	})
+
+	if vs, err := s.VoiceState(1, 100); err != nil || vs.ChannelID != 20 {
+		t.Fatal("Voice state not updated:", vs, err)
+	}
+
+	// Leaving voice.
+	s.onEvent(&gateway.VoiceStateUpdateEvent{
+		GuildID: 1, UserID: 200,
+	})
+
+	states, err := s.VoiceStates(1)
+	if err != nil {
+		t.Fatal("Failed to get voice states:", err)
+	}
+
+	if len(states) != 1 || states[0].UserID != 100 {
+		t.Fatal("Unexpected voice states:", states)
+	}
+}
+
+func TestReactionEvents(t *testing.T) {
+	s := &State{
+		Store:    NewDefaultStore(nil),
+		StateLog: func(error) {},
+	}
+
+	s.Store.MyselfSet(&discord.User{ID: 1})
+	s.Store.MessageSet(&discord.Message{ID: 10, ChannelID: 20})
+
+	var thumbs = discord.Emoji{Name: "👍"}
+	var custom = discord.Emoji{ID: 30, Name: "custom"}
+
+	reactions := func() []discord.Reaction {
+		m, err := s.Store.Message(20, 10)
+		if err != nil {
+			t.Fatal("Failed to get message:", err)
+		}
+		return m.Reactions
+	}
+
+	s.onEvent(&gateway.MessageReactionAddEvent{
+		UserID: 2, ChannelID: 20, MessageID: 10, Emoji: thumbs,
+	})
+	s.onEvent(&gateway.MessageReactionAddEvent{
+		UserID: 1, ChannelID: 20, MessageID: 10, Emoji: thumbs,
+	})
+	s.onEvent(&gateway.MessageReactionAddEvent{
+		UserID: 2, ChannelID: 20, MessageID: 10, Emoji: custom,
+	})
+
+	rs := reactions()
+	if len(rs) != 2 || rs[0].Count != 2 || !rs[0].Me || rs[1].Count != 1 || rs[1].Me {
+		t.Fatalf("Unexpected reactions after adding: %#v", rs)
+	}
+
+	s.onEvent(&gateway.MessageReactionRemoveEvent{
+		UserID: 1, ChannelID: 20, MessageID: 10, Emoji: thumbs,
+	})
+
+	rs = reactions()
+	if len(rs) != 2 || rs[0].Count != 1 || rs[0].Me {
+		t.Fatalf("Unexpected reactions after removing: %#v", rs)
+	}
+
+	s.onEvent(&gateway.MessageReactionRemoveEmojiEvent{
+		ChannelID: 20, MessageID: 10, Emoji: thumbs,
+	})
+
+	rs = reactions()
+	if len(rs) != 1 || rs[0].Emoji.ID != 30 {
+		t.Fatalf("Unexpected reactions after removing the emoji: %#v", rs)
+	}
+
+	// Message updates don't have reactions, so they should be kept.
+	s.onEvent(&gateway.MessageUpdateEvent{
+		ID: 10, ChannelID: 20, Content: "edited",
+	})
+
+	if rs := reactions(); len(rs) != 1 {
+		t.Fatalf("Reactions lost after an update: %#v", rs)
+	}
+
+	s.onEvent(&gateway.MessageReactionRemoveAllEvent{
+		ChannelID: 20, MessageID: 10,
+	})
+
+	if rs := reactions(); len(rs) != 0 {
+		t.Fatalf("Unexpected reactions after removing all: %#v", rs)
+	}
+}
+
+// handle handles the event like the State handler does, then waits for the
+// events it dispatched to be handled.
+func TestReadyUserEvents(t *testing.T) {
+	s := &State{
+		Store:    NewDefaultStore(nil),
+		StateLog: func(error) {},
+		Ready: gateway.ReadyEvent{
+			Settings: &gateway.UserSettings{Locale: "en-US", Theme: "dark"},
+			Relationships: []gateway.Relationship{
+				{ID: "100", Type: gateway.FriendRelationship},
+			},
+			UserGuildSettings: []gateway.UserGuildSettings{{GuildID: 1}},
+			Notes:             map[discord.Snowflake]string{100: "a"},
+		},
+	}
+
+	old := s.ReadyEvent()
+
+	// Only the changed settings are sent.
+	var settings gateway.UserSettingsUpdateEvent
+	if err := (json.Default{}).Unmarshal([]byte(`{"theme":"light"}`), &settings); err != nil {
+		t.Fatal("Failed to unmarshal settings:", err)
+	}
+
+	s.onEvent(&settings)
+	s.onEvent(&gateway.RelationshipAddEvent{
+		ID: "200", Type: gateway.IncomingFriendRequest,
+	})
+	s.onEvent(&gateway.RelationshipAddEvent{
+		ID: "200", Type: gateway.FriendRelationship,
+	})
+	s.onEvent(&gateway.RelationshipRemoveEvent{ID: "100"})
+	s.onEvent(&gateway.UserGuildSettingsUpdateEvent{GuildID: 1, Muted: true})
+	s.onEvent(&gateway.UserNoteUpdateEvent{ID: 100})
+	s.onEvent(&gateway.UserNoteUpdateEvent{ID: 200, Note: "b"})
+
+	r := s.ReadyEvent()
+
+	if r.Settings.Theme != "light" || r.Settings.Locale != "en-US" {
+		t.Fatalf("Unexpected settings: %#v", r.Settings)
+	}
+
+	rs := r.Relationships
+	if len(rs) != 1 || rs[0].ID != "200" || rs[0].Type != gateway.FriendRelationship {
+		t.Fatal("Unexpected relationships:", rs)
+	}
+
+	if gs := r.UserGuildSettings; len(gs) != 1 || !gs[0].Muted {
+		t.Fatal("Unexpected guild settings:", gs)
+	}
+
+	if len(r.Notes) != 1 || r.Notes[200] != "b" {
+		t.Fatal("Unexpected notes:", r.Notes)
+	}
+
+	// Copies of Ready shouldn't change.
+	if old.Settings.Theme != "dark" || len(old.Relationships) != 1 ||
+		old.UserGuildSettings[0].Muted || old.Notes[100] != "a" {
+
+		t.Fatalf("Old Ready was modified: %#v", old)
+	}
+}
+
+func handle(s *State, ev interface{}) {
+	s.onEvent(ev)
+
+	for {
+		s.dispatchMutex.Lock()
+		done := !s.dispatching
+		s.dispatchMutex.Unlock()
+
+		if done {
+			return
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestUpdateEvents(t *testing.T) {
+	h := handler.New()
+	h.Synchronous = true
+
+	s := &State{
+		Session:  &session.Session{Handler: h},
+		Store:    NewDefaultStore(nil),
+		StateLog: func(error) {},
+	}
+
+	var member *MemberUpdateEvent
+	h.AddHandler(func(ev *MemberUpdateEvent) { member = ev })
+
+	var message *MessageUpdateEvent
+	h.AddHandler(func(ev *MessageUpdateEvent) { message = ev })
+
+	s.Store.MemberSet(1, &discord.Member{
+		User: discord.User{ID: 100},
+		Nick: "old",
+	})
+
+	handle(s, &gateway.GuildMemberUpdateEvent{
+		GuildID: 1,
+		User:    discord.User{ID: 100},
+		Nick:    "new",
+	})
+
+	if member == nil || member.Old == nil {
+		t.Fatal("Member update not dispatched:", member)
+	}
+
+	if member.Old.Nick != "old" || member.New.Nick != "new" {
+		t.Fatalf("Unexpected member update: %#v", member)
+	}
+
+	s.Store.MessageSet(&discord.Message{
+		ID:        10,
+		ChannelID: 20,
+		Author:    discord.User{ID: 100},
+		Content:   "old",
+	})
+
+	handle(s, &gateway.MessageUpdateEvent{
+		ID:        10,
+		ChannelID: 20,
+		Content:   "new",
+	})
+
+	if message == nil || message.Old == nil {
+		t.Fatal("Message update not dispatched:", message)
+	}
+
+	if message.Old.Content != "old" || message.New.Content != "new" {
+		t.Fatalf("Unexpected message update: %#v", message)
+	}
+
+	// The new message should be merged with the cached one.
+	if message.New.Author.ID != 100 {
+		t.Fatal("New message isn't from the store:", message.New)
+	}
+
+	var channel *ChannelUpdateEvent
+	h.AddHandler(func(ev *ChannelUpdateEvent) { channel = ev })
+
+	// Updates to things not in the store don't have the old value.
+	handle(s, &gateway.ChannelUpdateEvent{ID: 30, GuildID: 1})
+
+	if channel == nil || channel.Old != nil || channel.New.ID != 30 {
+		t.Fatalf("Unexpected channel update: %#v", channel)
+	}
+}
+
+func TestGuildReadiness(t *testing.T) {
+	h := handler.New()
+	h.Synchronous = true
+
+	s := &State{
+		Session:  &session.Session{Handler: h},
+		Store:    NewDefaultStore(nil),
+		StateLog: func(error) {},
+	}
+
+	var events []interface{}
+	h.AddHandler(func(ev interface{}) {
+		switch ev.(type) {
+		case *GuildJoinEvent, *GuildAvailableEvent,
+			*GuildUnavailableEvent, *GuildLeaveEvent:
+
+			events = append(events, ev)
+		}
+	})
+
+	ready := make(chan error)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		ready <- s.WaitReady(ctx)
+	}()
+
+	handle(s, &gateway.ReadyEvent{
+		Guilds: []discord.Guild{
+			{ID: 1, Unavailable: true},
+			{ID: 2, Unavailable: true},
+		},
+	})
+
+	handle(s, &gateway.GuildCreateEvent{Guild: discord.Guild{ID: 1}})
+
+	select {
+	case <-ready:
+		t.Fatal("WaitReady returned before all guilds are loaded")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	// Guild 2 goes down before it's loaded, which shouldn't block WaitReady.
+	handle(s, &gateway.GuildDeleteEvent{ID: 2, Unavailable: true})
+
+	if err := <-ready; err != nil {
+		t.Fatal("Failed to wait for Ready:", err)
+	}
+
+	handle(s, &gateway.GuildCreateEvent{Guild: discord.Guild{ID: 2}})
+	handle(s, &gateway.GuildCreateEvent{Guild: discord.Guild{ID: 3}})
+	handle(s, &gateway.GuildDeleteEvent{ID: 3})
+
+	if len(events) != 5 {
+		t.Fatal("Unexpected events:", events)
+	}
+
+	if ev, ok := events[0].(*GuildAvailableEvent); !ok || ev.ID != 1 {
+		t.Fatalf("Unexpected event: %#v", events[0])
+	}
+	if ev, ok := events[1].(*GuildUnavailableEvent); !ok || ev.ID != 2 {
+		t.Fatalf("Unexpected event: %#v", events[1])
+	}
+	if ev, ok := events[2].(*GuildAvailableEvent); !ok || ev.ID != 2 {
+		t.Fatalf("Unexpected event: %#v", events[2])
+	}
+	if ev, ok := events[3].(*GuildJoinEvent); !ok || ev.ID != 3 {
+		t.Fatalf("Unexpected event: %#v", events[3])
+	}
+	if ev, ok := events[4].(*GuildLeaveEvent); !ok || ev.ID != 3 {
+		t.Fatalf("Unexpected event: %#v", events[4])
+	}
+
+	// WaitReady should return right away after Ready.
+	if err := s.WaitReady(context.Background()); err != nil {
+		t.Fatal("Failed to wait for Ready again:", err)
+	}
+}
+
+func TestDispatchAddHandler(t *testing.T) {
+	h := handler.New()
+	h.Synchronous = true
+
+	s := &State{
+		Session:  &session.Session{Handler: h},
+		Store:    NewDefaultStore(nil),
+		StateLog: func(error) {},
+	}
+
+	// Adding a handler while an event is handled waits for Call to return.
+	h.AddHandler(func(*gateway.GuildUpdateEvent) {
+		go h.AddHandler(func(*gateway.GuildUpdateEvent) {})
+		time.Sleep(10 * time.Millisecond)
+	})
+
+	s.hookSession()
+
+	var update = make(chan *GuildUpdateEvent, 1)
+	h.AddHandler(func(ev *GuildUpdateEvent) { update <- ev })
+
+	var called = make(chan struct{})
+	go func() {
+		h.Call(&gateway.GuildUpdateEvent{ID: 1})
+		close(called)
+	}()
+
+	select {
+	case <-called:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Handler.Call deadlocked")
+	}
+
+	select {
+	case ev := <-update:
+		if ev.New.ID != 1 {
+			t.Fatalf("Unexpected update: %#v", ev)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Guild update not dispatched")
+	}
+}
@@ -0,0 +1,111 @@
+package state
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/diamondburned/arikawa/api"
+	"github.com/diamondburned/arikawa/discord"
+	"github.com/pkg/errors"
+)
+
+// fetchCall is an API fallback in flight. Callers of the same key wait for
+// the first one instead of calling the API again.
+type fetchCall struct {
+	done chan struct{}
+	v    interface{}
+	err  error
+}
+
+// errFetchPanicked is given to the callers waiting for a fetch that panicked.
+var errFetchPanicked = errors.New("API fallback panicked")
+
+// missing is a cached 404.
+type missing struct {
+	err    error
+	expiry time.Time
+}
+
+// fetch calls fn, which fetches what's missing from the Store with the API,
+// unless it's already being fetched or known to be missing. The value is
+// shared between all callers of the same key.
+func (s *State) fetch(key string, fn func() (interface{}, error)) (interface{}, error) {
+	if s.CacheOnly {
+		return nil, ErrStoreNotFound
+	}
+
+	s.fetchMutex.Lock()
+
+	if m, ok := s.missing[key]; ok {
+		if time.Now().Before(m.expiry) {
+			s.fetchMutex.Unlock()
+			return nil, m.err
+		}
+
+		delete(s.missing, key)
+	}
+
+	if call, ok := s.fetching[key]; ok {
+		s.fetchMutex.Unlock()
+		<-call.done
+		return call.v, call.err
+	}
+
+	if s.fetching == nil {
+		s.fetching = map[string]*fetchCall{}
+	}
+
+	call := &fetchCall{done: make(chan struct{})}
+	s.fetching[key] = call
+
+	s.fetchMutex.Unlock()
+
+	// The waiters are released even if fn panics.
+	defer func() {
+		s.fetchMutex.Lock()
+		delete(s.fetching, key)
+
+		if call.err != nil && s.NegativeCacheTTL > 0 && isNotFound(call.err) {
+			if s.missing == nil {
+				s.missing = map[string]missing{}
+			}
+
+			s.missing[key] = missing{
+				err:    call.err,
+				expiry: time.Now().Add(s.NegativeCacheTTL),
+			}
+		}
+
+		s.fetchMutex.Unlock()
+
+		close(call.done)
+	}()
+
+	call.err = errFetchPanicked
+	call.v, call.err = fn()
+
+	return call.v, call.err
+}
+
+// forget removes the keys from the negative cache, which is needed when the
+// Gateway tells that something exists again, like a member rejoining.
+func (s *State) forget(keys ...string) {
+	s.fetchMutex.Lock()
+	for _, key := range keys {
+		delete(s.missing, key)
+	}
+	s.fetchMutex.Unlock()
+}
+
+func isNotFound(err error) bool {
+	var httpErr *api.HTTPError
+	return errors.As(err, &httpErr) && httpErr.Status == http.StatusNotFound
+}
+
+// fetchKey makes a key from the kind of the fetched thing and its IDs.
+func fetchKey(kind string, ids ...discord.Snowflake) string {
+	for _, id := range ids {
+		kind += ":" + id.String()
+	}
+	return kind
+}
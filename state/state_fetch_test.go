@@ -0,0 +1,193 @@
+// +build unit
+
+package state
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/diamondburned/arikawa/api"
+	"github.com/diamondburned/arikawa/discord"
+	"github.com/diamondburned/arikawa/gateway"
+)
+
+func TestFetchSingleflight(t *testing.T) {
+	s := &State{Store: NewDefaultStore(nil)}
+
+	var calls int32
+	var wait = make(chan struct{})
+
+	fn := func() (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		<-wait
+		return "value", nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			v, err := s.fetch("key", fn)
+			if err != nil || v != "value" {
+				t.Error("Unexpected result:", v, err)
+			}
+		}()
+	}
+
+	// Let all callers wait on the first call.
+	time.Sleep(50 * time.Millisecond)
+	close(wait)
+	wg.Wait()
+
+	if calls != 1 {
+		t.Fatal("Unexpected number of calls:", calls)
+	}
+
+	// The call is done, so it should be called again.
+	if s.fetch("key", fn); calls != 2 {
+		t.Fatal("Finished call wasn't forgotten:", calls)
+	}
+}
+
+func TestFetchNegativeCache(t *testing.T) {
+	s := &State{
+		Store:            NewDefaultStore(nil),
+		NegativeCacheTTL: time.Hour,
+	}
+
+	var calls int
+	fn := func() (interface{}, error) {
+		calls++
+		return nil, &api.HTTPError{Status: 404, Code: api.ErrUnknownMember}
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err := s.fetch("member:1:2", fn); !isNotFound(err) {
+			t.Fatal("Unexpected error:", err)
+		}
+	}
+
+	if calls != 1 {
+		t.Fatal("404 wasn't cached:", calls)
+	}
+
+	s.forget("member:1:2")
+
+	if s.fetch("member:1:2", fn); calls != 2 {
+		t.Fatal("404 wasn't forgotten:", calls)
+	}
+
+	// Other errors shouldn't be cached.
+	for i := 0; i < 2; i++ {
+		s.fetch("guild:1", func() (interface{}, error) {
+			calls++
+			return nil, &api.HTTPError{Status: 500}
+		})
+	}
+
+	if calls != 4 {
+		t.Fatal("500 was cached:", calls)
+	}
+}
+
+func TestFetchPanic(t *testing.T) {
+	s := &State{Store: NewDefaultStore(nil)}
+
+	var wait = make(chan struct{})
+	var panicked = make(chan interface{})
+
+	go func() {
+		defer func() { panicked <- recover() }()
+
+		s.fetch("key", func() (interface{}, error) {
+			<-wait
+			panic("oops")
+		})
+	}()
+
+	// Let the second caller wait on the first call.
+	time.Sleep(50 * time.Millisecond)
+
+	var waited = make(chan error)
+	go func() {
+		_, err := s.fetch("key", func() (interface{}, error) { return nil, nil })
+		waited <- err
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	close(wait)
+
+	if p := <-panicked; p != "oops" {
+		t.Fatal("Unexpected panic:", p)
+	}
+
+	select {
+	case err := <-waited:
+		if err != errFetchPanicked {
+			t.Fatal("Unexpected error:", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Waiter blocked after the fetch panicked")
+	}
+
+	if v, err := s.fetch("key", func() (interface{}, error) { return "value", nil }); v != "value" {
+		t.Fatal("Panicked call wasn't forgotten:", v, err)
+	}
+}
+
+func TestForgetOnCreate(t *testing.T) {
+	s := &State{
+		Store:            NewDefaultStore(nil),
+		StateLog:         func(error) {},
+		NegativeCacheTTL: time.Hour,
+	}
+
+	var tests = []struct {
+		key string
+		ev  interface{}
+	}{
+		{"channel:10", &gateway.ChannelCreateEvent{ID: 10, GuildID: 1}},
+		{"guild:1", &gateway.GuildCreateEvent{Guild: discord.Guild{ID: 1}}},
+		{"channels:1", &gateway.GuildCreateEvent{Guild: discord.Guild{ID: 1}}},
+		{"roles:1", &gateway.GuildRoleCreateEvent{GuildID: 1, Role: discord.Role{ID: 20}}},
+	}
+
+	for _, test := range tests {
+		var calls int
+		fn := func() (interface{}, error) {
+			calls++
+			return nil, &api.HTTPError{Status: 404}
+		}
+
+		s.fetch(test.key, fn)
+		s.onEvent(test.ev)
+
+		if s.fetch(test.key, fn); calls != 2 {
+			t.Fatalf("%s wasn't forgotten after %T", test.key, test.ev)
+		}
+	}
+}
+
+func TestCacheOnly(t *testing.T) {
+	// Without a Session, any API call would panic.
+	s := &State{
+		Store:     NewDefaultStore(nil),
+		CacheOnly: true,
+	}
+
+	if _, err := s.Channel(1); err != ErrStoreNotFound {
+		t.Fatal("Unexpected error:", err)
+	}
+
+	if _, err := s.Member(1, 2); err != ErrStoreNotFound {
+		t.Fatal("Unexpected error:", err)
+	}
+
+	if _, err := s.Messages(1); err != ErrStoreNotFound {
+		t.Fatal("Unexpected error:", err)
+	}
+}
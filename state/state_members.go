@@ -0,0 +1,105 @@
+package state
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/diamondburned/arikawa/discord"
+	"github.com/diamondburned/arikawa/gateway"
+	"github.com/pkg/errors"
+)
+
+// memberRequest collects the chunks of a RequestMembers call. Chunks could be
+// handled in any order, so they're counted instead of waiting for the last
+// index.
+type memberRequest struct {
+	mut     sync.Mutex
+	members []discord.Member
+	chunks  map[int]struct{}
+	done    chan struct{}
+}
+
+// RequestMembers requests members through the Gateway and waits for all of
+// their chunks. The members are also added into the Store.
+//
+// If userIDs are given, only those members are requested. Otherwise, members
+// with usernames starting with the query are. An empty query requests every
+// member, which needs the GUILD_MEMBERS intent on large guilds.
+func (s *State) RequestMembers(ctx context.Context,
+	guildID discord.Snowflake, query string,
+	userIDs ...discord.Snowflake) ([]discord.Member, error) {
+
+	nonce := strconv.FormatUint(atomic.AddUint64(&s.memberNonce, 1), 36)
+
+	req := &memberRequest{
+		chunks: map[int]struct{}{},
+		done:   make(chan struct{}),
+	}
+
+	s.memberMutex.Lock()
+	if s.memberRequests == nil {
+		s.memberRequests = map[string]*memberRequest{}
+	}
+	s.memberRequests[nonce] = req
+	s.memberMutex.Unlock()
+
+	defer func() {
+		s.memberMutex.Lock()
+		delete(s.memberRequests, nonce)
+		s.memberMutex.Unlock()
+	}()
+
+	err := s.Gateway.RequestGuildMembers(gateway.RequestGuildMembersData{
+		GuildID: []discord.Snowflake{guildID},
+		UserIDs: userIDs,
+		Query:   query,
+		Nonce:   nonce,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to request members")
+	}
+
+	select {
+	case <-req.done:
+	case <-ctx.Done():
+		return nil, errors.Wrap(ctx.Err(), "Failed to wait for member chunks")
+	}
+
+	req.mut.Lock()
+	defer req.mut.Unlock()
+
+	return req.members, nil
+}
+
+// collectMembers adds the chunk into its RequestMembers call, if there's one.
+func (s *State) collectMembers(ev *gateway.GuildMembersChunkEvent) {
+	s.memberMutex.Lock()
+	req, ok := s.memberRequests[ev.Nonce]
+	s.memberMutex.Unlock()
+
+	if !ok {
+		return
+	}
+
+	req.mut.Lock()
+	defer req.mut.Unlock()
+
+	if _, ok := req.chunks[ev.ChunkIndex]; ok {
+		return
+	}
+
+	req.members = append(req.members, ev.Members...)
+	req.chunks[ev.ChunkIndex] = struct{}{}
+
+	// Discord sends at least 1 chunk, even if nothing is found.
+	count := ev.ChunkCount
+	if count < 1 {
+		count = 1
+	}
+
+	if len(req.chunks) == count {
+		close(req.done)
+	}
+}
@@ -0,0 +1,71 @@
+// +build unit
+
+package state
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/diamondburned/arikawa/discord"
+	"github.com/diamondburned/arikawa/gateway"
+)
+
+func TestCollectMembers(t *testing.T) {
+	s := &State{
+		Store:    NewDefaultStore(nil),
+		StateLog: func(error) {},
+	}
+
+	req := &memberRequest{
+		chunks: map[int]struct{}{},
+		done:   make(chan struct{}),
+	}
+	s.memberRequests = map[string]*memberRequest{"1": req}
+
+	chunk := func(index int, userID discord.Snowflake) *gateway.GuildMembersChunkEvent {
+		return &gateway.GuildMembersChunkEvent{
+			GuildID: 10,
+			Members: []discord.Member{
+				{User: discord.User{ID: userID}},
+			},
+			ChunkIndex: index,
+			ChunkCount: 3,
+			Nonce:      "1",
+		}
+	}
+
+	// Chunks could come in any order, and the last index isn't the last
+	// chunk.
+	var wg sync.WaitGroup
+	for _, ev := range []*gateway.GuildMembersChunkEvent{
+		chunk(2, 3), chunk(0, 1), chunk(2, 3), chunk(1, 2),
+	} {
+		wg.Add(1)
+		go func(ev *gateway.GuildMembersChunkEvent) {
+			s.onEvent(ev)
+			wg.Done()
+		}(ev)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	select {
+	case <-req.done:
+	case <-ctx.Done():
+		t.Fatal("Request wasn't done after all chunks")
+	}
+
+	wg.Wait()
+
+	if len(req.members) != 3 {
+		t.Fatal("Unexpected members:", req.members)
+	}
+
+	// Chunks are also stored.
+	if _, err := s.Store.Member(10, 2); err != nil {
+		t.Fatal("Member not in the store:", err)
+	}
+}
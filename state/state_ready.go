@@ -0,0 +1,109 @@
+package state
+
+import (
+	"context"
+
+	"github.com/diamondburned/arikawa/discord"
+	"github.com/diamondburned/arikawa/gateway"
+)
+
+// WaitReady blocks until Ready is received and all of its guilds are loaded,
+// or until ctx is done. Guilds that go down before they're loaded aren't waited
+// for.
+func (s *State) WaitReady(ctx context.Context) error {
+	s.readyMutex.Lock()
+	ready := s.readyChan()
+	s.readyMutex.Unlock()
+
+	select {
+	case <-ready:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// readyChan returns the channel closed when all guilds are loaded. readyMutex
+// must be locked.
+func (s *State) readyChan() chan struct{} {
+	if s.ready == nil {
+		s.ready = make(chan struct{})
+	}
+	return s.ready
+}
+
+// markReady closes the ready channel if there are no more guilds to wait for.
+// readyMutex must be locked.
+func (s *State) markReady() {
+	if !s.readyDone && len(s.unreadyGuilds) == 0 {
+		close(s.readyChan())
+		s.readyDone = true
+	}
+}
+
+func (s *State) onReady(ev *gateway.ReadyEvent) {
+	s.readyMutex.Lock()
+	defer s.readyMutex.Unlock()
+
+	// A new session, wait for the guilds again.
+	if s.readyDone {
+		s.ready = nil
+		s.readyDone = false
+	}
+
+	s.unreadyGuilds = make(map[discord.Snowflake]struct{}, len(ev.Guilds))
+	s.unavailableGuilds = map[discord.Snowflake]struct{}{}
+
+	for _, g := range ev.Guilds {
+		// User accounts get full guilds in Ready.
+		if g.Unavailable {
+			s.unreadyGuilds[g.ID] = struct{}{}
+		}
+	}
+
+	s.markReady()
+}
+
+// onGuildCreate returns the event to dispatch for the Guild Create.
+func (s *State) onGuildCreate(ev *gateway.GuildCreateEvent) interface{} {
+	s.readyMutex.Lock()
+	defer s.readyMutex.Unlock()
+
+	if _, ok := s.unreadyGuilds[ev.ID]; ok {
+		delete(s.unreadyGuilds, ev.ID)
+		s.markReady()
+
+		return &GuildAvailableEvent{ev}
+	}
+
+	if _, ok := s.unavailableGuilds[ev.ID]; ok {
+		delete(s.unavailableGuilds, ev.ID)
+		return &GuildAvailableEvent{ev}
+	}
+
+	return &GuildJoinEvent{ev}
+}
+
+// onGuildDelete returns the event to dispatch for the Guild Delete.
+func (s *State) onGuildDelete(ev *gateway.GuildDeleteEvent) interface{} {
+	s.readyMutex.Lock()
+	defer s.readyMutex.Unlock()
+
+	// The guild won't be loaded anytime soon.
+	if _, ok := s.unreadyGuilds[ev.ID]; ok {
+		delete(s.unreadyGuilds, ev.ID)
+		s.markReady()
+	}
+
+	if !ev.Unavailable {
+		delete(s.unavailableGuilds, ev.ID)
+		return &GuildLeaveEvent{ev}
+	}
+
+	if s.unavailableGuilds == nil {
+		s.unavailableGuilds = map[discord.Snowflake]struct{}{}
+	}
+	s.unavailableGuilds[ev.ID] = struct{}{}
+
+	return &GuildUnavailableEvent{ev}
+}
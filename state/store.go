@@ -47,6 +47,10 @@ type StoreGetter interface {
 
 	Role(guildID, roleID discord.Snowflake) (*discord.Role, error)
 	Roles(guildID discord.Snowflake) ([]discord.Role, error)
+
+	// These don't get fetched from the API, it's Gateway only.
+	VoiceState(guildID, userID discord.Snowflake) (*discord.VoiceState, error)
+	VoiceStates(guildID discord.Snowflake) ([]discord.VoiceState, error)
 }
 
 type StoreModifier interface {
@@ -74,10 +78,22 @@ type StoreModifier interface {
 	RoleSet(guildID discord.Snowflake, role *discord.Role) error
 	RoleRemove(guildID, roleID discord.Snowflake) error
 
+	VoiceStateSet(guildID discord.Snowflake, state *discord.VoiceState) error
+	VoiceStateRemove(guildID, userID discord.Snowflake) error
+
 	// This should reset all the state to zero/null.
 	Reset() error
 }
 
+// StoreBatcher is an optional interface for Stores where each call is
+// expensive, such as ones that write to disk. The State sets the members and
+// presences of a Guild Create or a member chunk in one call each if the Store
+// implements it.
+type StoreBatcher interface {
+	MembersSet(guildID discord.Snowflake, members []discord.Member) error
+	PresencesSet(guildID discord.Snowflake, presences []discord.Presence) error
+}
+
 // ErrStoreNotFound is an error that a store can use to return when something
 // isn't in the storage. There is no strict restrictions on what uses this (the
 // default one does, though), so be advised.
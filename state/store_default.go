@@ -7,23 +7,27 @@ import (
 	"github.com/diamondburned/arikawa/discord"
 )
 
-// TODO: make an ExpiryStore
-
+// DefaultStore keeps everything in memory. Entities are indexed with maps, so
+// lookups are O(1) even in guilds with a lot of members. Reads can happen
+// concurrently.
 type DefaultStore struct {
 	*DefaultStoreOptions
 
 	self discord.User
 
-	// includes normal and private
 	privates map[discord.Snowflake]*discord.Channel // channelID:channel
 	guilds   map[discord.Snowflake]*discord.Guild   // guildID:guild
 
-	channels  map[discord.Snowflake][]discord.Channel  // guildID:channels
-	members   map[discord.Snowflake][]discord.Member   // guildID:members
-	presences map[discord.Snowflake][]discord.Presence // guildID:presences
-	messages  map[discord.Snowflake][]discord.Message  // channelID:messages
+	channels      map[discord.Snowflake]map[discord.Snowflake]discord.Channel // guildID:channelID:channel
+	channelGuilds map[discord.Snowflake]discord.Snowflake                     // channelID:guildID
+
+	members   map[discord.Snowflake]map[discord.Snowflake]discord.Member   // guildID:userID:member
+	presences map[discord.Snowflake]map[discord.Snowflake]discord.Presence // guildID:userID:presence
+	messages  map[discord.Snowflake][]discord.Message                      // channelID:messages
 
-	mut sync.Mutex
+	voiceStates map[discord.Snowflake]map[discord.Snowflake]discord.VoiceState // guildID:userID:voiceState
+
+	mut sync.RWMutex
 }
 
 type DefaultStoreOptions struct {
@@ -56,25 +60,30 @@ func (s *DefaultStore) Reset() error {
 	s.privates = map[discord.Snowflake]*discord.Channel{}
 	s.guilds = map[discord.Snowflake]*discord.Guild{}
 
-	s.channels = map[discord.Snowflake][]discord.Channel{}
-	s.members = map[discord.Snowflake][]discord.Member{}
-	s.presences = map[discord.Snowflake][]discord.Presence{}
+	s.channels = map[discord.Snowflake]map[discord.Snowflake]discord.Channel{}
+	s.channelGuilds = map[discord.Snowflake]discord.Snowflake{}
+
+	s.members = map[discord.Snowflake]map[discord.Snowflake]discord.Member{}
+	s.presences = map[discord.Snowflake]map[discord.Snowflake]discord.Presence{}
 	s.messages = map[discord.Snowflake][]discord.Message{}
 
+	s.voiceStates = map[discord.Snowflake]map[discord.Snowflake]discord.VoiceState{}
+
 	return nil
 }
 
 ////
 
 func (s *DefaultStore) Me() (*discord.User, error) {
-	s.mut.Lock()
-	defer s.mut.Unlock()
+	s.mut.RLock()
+	defer s.mut.RUnlock()
 
 	if !s.self.ID.Valid() {
 		return nil, ErrStoreNotFound
 	}
 
-	return &s.self, nil
+	u := s.self
+	return &u, nil
 }
 
 func (s *DefaultStore) MyselfSet(me *discord.User) error {
@@ -88,43 +97,57 @@ func (s *DefaultStore) MyselfSet(me *discord.User) error {
 ////
 
 func (s *DefaultStore) Channel(id discord.Snowflake) (*discord.Channel, error) {
-	s.mut.Lock()
-	defer s.mut.Unlock()
+	s.mut.RLock()
+	defer s.mut.RUnlock()
 
-	for _, chs := range s.channels {
-		for _, ch := range chs {
-			if ch.ID == id {
-				return &ch, nil
-			}
-		}
+	if ch, ok := s.privates[id]; ok {
+		c := *ch
+		return &c, nil
 	}
 
-	return nil, ErrStoreNotFound
+	guildID, ok := s.channelGuilds[id]
+	if !ok {
+		return nil, ErrStoreNotFound
+	}
+
+	ch := s.channels[guildID][id]
+	return &ch, nil
 }
 
 func (s *DefaultStore) Channels(
 	guildID discord.Snowflake) ([]discord.Channel, error) {
 
-	s.mut.Lock()
-	defer s.mut.Unlock()
+	s.mut.RLock()
 
 	chs, ok := s.channels[guildID]
 	if !ok {
+		s.mut.RUnlock()
 		return nil, ErrStoreNotFound
 	}
 
-	return append([]discord.Channel{}, chs...), nil
+	var cs = make([]discord.Channel, 0, len(chs))
+	for _, ch := range chs {
+		cs = append(cs, ch)
+	}
+
+	s.mut.RUnlock()
+
+	sort.Slice(cs, func(i, j int) bool {
+		return cs[i].Position < cs[j].Position
+	})
+
+	return cs, nil
 }
 
 func (s *DefaultStore) PrivateChannels() ([]discord.Channel, error) {
-	s.mut.Lock()
+	s.mut.RLock()
 
 	var chs = make([]discord.Channel, 0, len(s.privates))
 	for _, ch := range s.privates {
 		chs = append(chs, *ch)
 	}
 
-	s.mut.Unlock()
+	s.mut.RUnlock()
 
 	sort.Slice(chs, func(i, j int) bool {
 		// Latest first
@@ -143,24 +166,29 @@ func (s *DefaultStore) ChannelSet(channel *discord.Channel) error {
 		s.privates[channel.ID] = channel
 
 	default:
-		chs := s.channels[channel.GuildID]
-
-		for i, ch := range chs {
-			if ch.ID == channel.ID {
-				// Also from discordgo.
-				if channel.Permissions == nil {
-					channel.Permissions = ch.Permissions
-				}
+		chs, ok := s.channels[channel.GuildID]
+		if !ok {
+			chs = map[discord.Snowflake]discord.Channel{}
+			s.channels[channel.GuildID] = chs
+		}
 
-				// Found, just edit
-				chs[i] = *channel
+		// The channel could've moved from another guild.
+		if guildID, ok := s.channelGuilds[channel.ID]; ok && guildID != channel.GuildID {
+			if old, ok := s.channels[guildID][channel.ID]; ok {
+				chs[channel.ID] = old
+				delete(s.channels[guildID], channel.ID)
+			}
+		}
 
-				return nil
+		if ch, ok := chs[channel.ID]; ok {
+			// Also from discordgo.
+			if channel.Permissions == nil {
+				channel.Permissions = ch.Permissions
 			}
 		}
 
-		chs = append(chs, *channel)
-		s.channels[channel.GuildID] = chs
+		chs[channel.ID] = *channel
+		s.channelGuilds[channel.ID] = channel.GuildID
 	}
 
 	return nil
@@ -175,16 +203,14 @@ func (s *DefaultStore) ChannelRemove(channel *discord.Channel) error {
 		return ErrStoreNotFound
 	}
 
-	for i, ch := range chs {
-		if ch.ID == channel.ID {
-			chs = append(chs[:i], chs[i+1:]...)
-			s.channels[channel.GuildID] = chs
-
-			return nil
-		}
+	if _, ok := chs[channel.ID]; !ok {
+		return ErrStoreNotFound
 	}
 
-	return ErrStoreNotFound
+	delete(chs, channel.ID)
+	delete(s.channelGuilds, channel.ID)
+
+	return nil
 }
 
 ////
@@ -192,8 +218,8 @@ func (s *DefaultStore) ChannelRemove(channel *discord.Channel) error {
 func (s *DefaultStore) Emoji(
 	guildID, emojiID discord.Snowflake) (*discord.Emoji, error) {
 
-	s.mut.Lock()
-	defer s.mut.Unlock()
+	s.mut.RLock()
+	defer s.mut.RUnlock()
 
 	gd, ok := s.guilds[guildID]
 	if !ok {
@@ -212,8 +238,8 @@ func (s *DefaultStore) Emoji(
 func (s *DefaultStore) Emojis(
 	guildID discord.Snowflake) ([]discord.Emoji, error) {
 
-	s.mut.Lock()
-	defer s.mut.Unlock()
+	s.mut.RLock()
+	defer s.mut.RUnlock()
 
 	gd, ok := s.guilds[guildID]
 	if !ok {
@@ -234,48 +260,48 @@ func (s *DefaultStore) EmojiSet(
 		return ErrStoreNotFound
 	}
 
-	filtered := emojis[:0]
+	// Copy the slice, as it could be shared with a returned Guild.
+	var es = append([]discord.Emoji{}, gd.Emojis...)
 
 Main:
 	for _, enew := range emojis {
 		// Try and see if this emoji is already in the slice
-		for i, emoji := range gd.Emojis {
+		for i, emoji := range es {
 			if emoji.ID == enew.ID {
 				// If it is, we simply replace it
-				gd.Emojis[i] = enew
+				es[i] = enew
 
 				continue Main
 			}
 		}
 
-		// If not, we add it to the slice that's to be appended.
-		filtered = append(filtered, enew)
+		es = append(es, enew)
 	}
 
-	// Append the new emojis
-	gd.Emojis = append(gd.Emojis, filtered...)
+	gd.Emojis = es
 	return nil
 }
 
 ////
 
 func (s *DefaultStore) Guild(id discord.Snowflake) (*discord.Guild, error) {
-	s.mut.Lock()
-	defer s.mut.Unlock()
+	s.mut.RLock()
+	defer s.mut.RUnlock()
 
 	ch, ok := s.guilds[id]
 	if !ok {
 		return nil, ErrStoreNotFound
 	}
 
-	return ch, nil
+	gd := *ch
+	return &gd, nil
 }
 
 func (s *DefaultStore) Guilds() ([]discord.Guild, error) {
-	s.mut.Lock()
+	s.mut.RLock()
 
 	if len(s.guilds) == 0 {
-		s.mut.Unlock()
+		s.mut.RUnlock()
 		return nil, ErrStoreNotFound
 	}
 
@@ -284,7 +310,7 @@ func (s *DefaultStore) Guilds() ([]discord.Guild, error) {
 		gs = append(gs, *g)
 	}
 
-	s.mut.Unlock()
+	s.mut.RUnlock()
 
 	sort.Slice(gs, func(i, j int) bool {
 		return gs[i].ID > gs[j].ID
@@ -297,24 +323,39 @@ func (s *DefaultStore) GuildSet(guild *discord.Guild) error {
 	s.mut.Lock()
 	defer s.mut.Unlock()
 
+	// Copy the guild, since it's modified in place by RoleSet and EmojiSet.
+	var gd = *guild
+
 	if g, ok := s.guilds[guild.ID]; ok {
 		// preserve state stuff
-		if guild.Roles == nil {
-			guild.Roles = g.Roles
+		if gd.Roles == nil {
+			gd.Roles = g.Roles
 		}
-		if guild.Emojis == nil {
-			guild.Emojis = g.Emojis
+		if gd.Emojis == nil {
+			gd.Emojis = g.Emojis
 		}
 	}
 
-	s.guilds[guild.ID] = guild
+	s.guilds[guild.ID] = &gd
 	return nil
 }
 
+// GuildRemove removes the guild along with everything in it.
 func (s *DefaultStore) GuildRemove(id discord.Snowflake) error {
 	s.mut.Lock()
+	defer s.mut.Unlock()
+
 	delete(s.guilds, id)
-	s.mut.Unlock()
+
+	for channelID := range s.channels[id] {
+		delete(s.channelGuilds, channelID)
+		delete(s.messages, channelID)
+	}
+
+	delete(s.channels, id)
+	delete(s.members, id)
+	delete(s.presences, id)
+	delete(s.voiceStates, id)
 
 	return nil
 }
@@ -324,35 +365,40 @@ func (s *DefaultStore) GuildRemove(id discord.Snowflake) error {
 func (s *DefaultStore) Member(
 	guildID, userID discord.Snowflake) (*discord.Member, error) {
 
-	s.mut.Lock()
-	defer s.mut.Unlock()
+	s.mut.RLock()
+	defer s.mut.RUnlock()
 
-	ms, ok := s.members[guildID]
+	m, ok := s.members[guildID][userID]
 	if !ok {
 		return nil, ErrStoreNotFound
 	}
 
-	for _, m := range ms {
-		if m.User.ID == userID {
-			return &m, nil
-		}
-	}
-
-	return nil, ErrStoreNotFound
+	return &m, nil
 }
 
 func (s *DefaultStore) Members(
 	guildID discord.Snowflake) ([]discord.Member, error) {
 
-	s.mut.Lock()
-	defer s.mut.Unlock()
+	s.mut.RLock()
 
 	ms, ok := s.members[guildID]
 	if !ok {
+		s.mut.RUnlock()
 		return nil, ErrStoreNotFound
 	}
 
-	return append([]discord.Member{}, ms...), nil
+	var members = make([]discord.Member, 0, len(ms))
+	for _, m := range ms {
+		members = append(members, m)
+	}
+
+	s.mut.RUnlock()
+
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].User.ID < members[j].User.ID
+	})
+
+	return members, nil
 }
 
 func (s *DefaultStore) MemberSet(
@@ -361,23 +407,13 @@ func (s *DefaultStore) MemberSet(
 	s.mut.Lock()
 	defer s.mut.Unlock()
 
-	ms := s.members[guildID]
-
-	// Try and see if this member is already in the slice
-	for i, m := range ms {
-		if m.User.ID == member.User.ID {
-			// If it is, we simply replace it
-			ms[i] = *member
-			s.members[guildID] = ms
-
-			return nil
-		}
+	ms, ok := s.members[guildID]
+	if !ok {
+		ms = map[discord.Snowflake]discord.Member{}
+		s.members[guildID] = ms
 	}
 
-	// Append the new member
-	ms = append(ms, *member)
-	s.members[guildID] = ms
-
+	ms[member.User.ID] = *member
 	return nil
 }
 
@@ -390,17 +426,12 @@ func (s *DefaultStore) MemberRemove(guildID, userID discord.Snowflake) error {
 		return ErrStoreNotFound
 	}
 
-	// Try and see if this member is already in the slice
-	for i, m := range ms {
-		if m.User.ID == userID {
-			ms = append(ms, ms[i+1:]...)
-			s.members[guildID] = ms
-
-			return nil
-		}
+	if _, ok := ms[userID]; !ok {
+		return ErrStoreNotFound
 	}
 
-	return ErrStoreNotFound
+	delete(ms, userID)
+	return nil
 }
 
 ////
@@ -408,8 +439,8 @@ func (s *DefaultStore) MemberRemove(guildID, userID discord.Snowflake) error {
 func (s *DefaultStore) Message(
 	channelID, messageID discord.Snowflake) (*discord.Message, error) {
 
-	s.mut.Lock()
-	defer s.mut.Unlock()
+	s.mut.RLock()
+	defer s.mut.RUnlock()
 
 	ms, ok := s.messages[channelID]
 	if !ok {
@@ -428,8 +459,8 @@ func (s *DefaultStore) Message(
 func (s *DefaultStore) Messages(
 	channelID discord.Snowflake) ([]discord.Message, error) {
 
-	s.mut.Lock()
-	defer s.mut.Unlock()
+	s.mut.RLock()
+	defer s.mut.RUnlock()
 
 	ms, ok := s.messages[channelID]
 	if !ok {
@@ -455,56 +486,63 @@ func (s *DefaultStore) MessageSet(message *discord.Message) error {
 	// Check if we already have the message.
 	for i, m := range ms {
 		if m.ID == message.ID {
-			// Thanks, Discord.
-			if message.Content != "" {
-				m.Content = message.Content
-			}
-			if message.EditedTimestamp != nil {
-				m.EditedTimestamp = message.EditedTimestamp
-			}
-			if message.Mentions != nil {
-				m.Mentions = message.Mentions
-			}
-			if message.Embeds != nil {
-				m.Embeds = message.Embeds
-			}
-			if message.Attachments != nil {
-				m.Attachments = message.Attachments
-			}
-			if message.Timestamp.Valid() {
-				m.Timestamp = message.Timestamp
-			}
-			if message.Author.ID.Valid() {
-				m.Author = message.Author
-			}
-
-			ms[i] = m
+			MergeMessage(&ms[i], message)
 			return nil
 		}
 	}
 
 	// Prepend the latest message at the end
 
-	if len(ms) > 0 {
-		var end = s.MaxMessages()
-		if len(ms) < end {
-			end = len(ms)
-		}
+	// Grow the slice if it's not full yet, otherwise the last message is
+	// dropped.
+	if len(ms) < s.MaxMessages() {
+		ms = append(ms, discord.Message{})
+	}
 
+	if len(ms) > 0 {
 		// Copy hack to prepend. This copies the 0th-(end-1)th entries to
 		// 1st-endth.
-		copy(ms[1:end], ms[0:end-1])
+		copy(ms[1:], ms[:len(ms)-1])
 		// Then, set the 0th entry.
 		ms[0] = *message
-
-	} else {
-		ms = append(ms, *message)
 	}
 
 	s.messages[message.ChannelID] = ms
 	return nil
 }
 
+// MergeMessage updates the old message m with the fields set in the new one.
+// Message updates from the Gateway could be partial, so Stores should use this
+// in MessageSet if they already have the message.
+func MergeMessage(m, message *discord.Message) {
+	// Thanks, Discord.
+	if message.Content != "" {
+		m.Content = message.Content
+	}
+	if message.EditedTimestamp != nil {
+		m.EditedTimestamp = message.EditedTimestamp
+	}
+	if message.Mentions != nil {
+		m.Mentions = message.Mentions
+	}
+	if message.Embeds != nil {
+		m.Embeds = message.Embeds
+	}
+	if message.Attachments != nil {
+		m.Attachments = message.Attachments
+	}
+	if message.Timestamp.Valid() {
+		m.Timestamp = message.Timestamp
+	}
+	if message.Author.ID.Valid() {
+		m.Author = message.Author
+	}
+	// An empty slice means all reactions were removed.
+	if message.Reactions != nil {
+		m.Reactions = message.Reactions
+	}
+}
+
 func (s *DefaultStore) MessageRemove(
 	channelID, messageID discord.Snowflake) error {
 
@@ -532,35 +570,40 @@ func (s *DefaultStore) MessageRemove(
 func (s *DefaultStore) Presence(
 	guildID, userID discord.Snowflake) (*discord.Presence, error) {
 
-	s.mut.Lock()
-	defer s.mut.Unlock()
+	s.mut.RLock()
+	defer s.mut.RUnlock()
 
-	ps, ok := s.presences[guildID]
+	p, ok := s.presences[guildID][userID]
 	if !ok {
 		return nil, ErrStoreNotFound
 	}
 
-	for _, p := range ps {
-		if p.User.ID == userID {
-			return &p, nil
-		}
-	}
-
-	return nil, ErrStoreNotFound
+	return &p, nil
 }
 
 func (s *DefaultStore) Presences(
 	guildID discord.Snowflake) ([]discord.Presence, error) {
 
-	s.mut.Lock()
-	defer s.mut.Unlock()
+	s.mut.RLock()
 
 	ps, ok := s.presences[guildID]
 	if !ok {
+		s.mut.RUnlock()
 		return nil, ErrStoreNotFound
 	}
 
-	return append([]discord.Presence{}, ps...), nil
+	var presences = make([]discord.Presence, 0, len(ps))
+	for _, p := range ps {
+		presences = append(presences, p)
+	}
+
+	s.mut.RUnlock()
+
+	sort.Slice(presences, func(i, j int) bool {
+		return presences[i].User.ID < presences[j].User.ID
+	})
+
+	return presences, nil
 }
 
 func (s *DefaultStore) PresenceSet(
@@ -569,19 +612,13 @@ func (s *DefaultStore) PresenceSet(
 	s.mut.Lock()
 	defer s.mut.Unlock()
 
-	ps := s.presences[guildID]
-
-	for i, p := range ps {
-		if p.User.ID == presence.User.ID {
-			ps[i] = *presence
-			s.presences[guildID] = ps
-
-			return nil
-		}
+	ps, ok := s.presences[guildID]
+	if !ok {
+		ps = map[discord.Snowflake]discord.Presence{}
+		s.presences[guildID] = ps
 	}
 
-	ps = append(ps, *presence)
-	s.presences[guildID] = ps
+	ps[presence.User.ID] = *presence
 	return nil
 }
 
@@ -594,16 +631,12 @@ func (s *DefaultStore) PresenceRemove(guildID, userID discord.Snowflake) error {
 		return ErrStoreNotFound
 	}
 
-	for i, p := range ps {
-		if p.User.ID == userID {
-			ps = append(ps[:i], ps[i+1:]...)
-			s.presences[guildID] = ps
-
-			return nil
-		}
+	if _, ok := ps[userID]; !ok {
+		return ErrStoreNotFound
 	}
 
-	return ErrStoreNotFound
+	delete(ps, userID)
+	return nil
 }
 
 ////
@@ -611,8 +644,8 @@ func (s *DefaultStore) PresenceRemove(guildID, userID discord.Snowflake) error {
 func (s *DefaultStore) Role(
 	guildID, roleID discord.Snowflake) (*discord.Role, error) {
 
-	s.mut.Lock()
-	defer s.mut.Unlock()
+	s.mut.RLock()
+	defer s.mut.RUnlock()
 
 	gd, ok := s.guilds[guildID]
 	if !ok {
@@ -631,8 +664,8 @@ func (s *DefaultStore) Role(
 func (s *DefaultStore) Roles(
 	guildID discord.Snowflake) ([]discord.Role, error) {
 
-	s.mut.Lock()
-	defer s.mut.Unlock()
+	s.mut.RLock()
+	defer s.mut.RUnlock()
 
 	gd, ok := s.guilds[guildID]
 	if !ok {
@@ -653,14 +686,18 @@ func (s *DefaultStore) RoleSet(
 		return ErrStoreNotFound
 	}
 
-	for i, r := range gd.Roles {
+	// Copy the slice, as it could be shared with a returned Guild.
+	var rs = append([]discord.Role{}, gd.Roles...)
+
+	for i, r := range rs {
 		if r.ID == role.ID {
-			gd.Roles[i] = *role
+			rs[i] = *role
+			gd.Roles = rs
 			return nil
 		}
 	}
 
-	gd.Roles = append(gd.Roles, *role)
+	gd.Roles = append(rs, *role)
 	return nil
 }
 
@@ -675,10 +712,88 @@ func (s *DefaultStore) RoleRemove(guildID, roleID discord.Snowflake) error {
 
 	for i, r := range gd.Roles {
 		if r.ID == roleID {
-			gd.Roles = append(gd.Roles[:i], gd.Roles[i+1:]...)
+			rs := make([]discord.Role, 0, len(gd.Roles)-1)
+			rs = append(rs, gd.Roles[:i]...)
+			rs = append(rs, gd.Roles[i+1:]...)
+
+			gd.Roles = rs
 			return nil
 		}
 	}
 
 	return ErrStoreNotFound
 }
+
+////
+
+func (s *DefaultStore) VoiceState(
+	guildID, userID discord.Snowflake) (*discord.VoiceState, error) {
+
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+
+	vs, ok := s.voiceStates[guildID][userID]
+	if !ok {
+		return nil, ErrStoreNotFound
+	}
+
+	return &vs, nil
+}
+
+func (s *DefaultStore) VoiceStates(
+	guildID discord.Snowflake) ([]discord.VoiceState, error) {
+
+	s.mut.RLock()
+
+	states, ok := s.voiceStates[guildID]
+	if !ok {
+		s.mut.RUnlock()
+		return nil, ErrStoreNotFound
+	}
+
+	var vss = make([]discord.VoiceState, 0, len(states))
+	for _, vs := range states {
+		vss = append(vss, vs)
+	}
+
+	s.mut.RUnlock()
+
+	sort.Slice(vss, func(i, j int) bool {
+		return vss[i].UserID < vss[j].UserID
+	})
+
+	return vss, nil
+}
+
+func (s *DefaultStore) VoiceStateSet(
+	guildID discord.Snowflake, voiceState *discord.VoiceState) error {
+
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	states, ok := s.voiceStates[guildID]
+	if !ok {
+		states = map[discord.Snowflake]discord.VoiceState{}
+		s.voiceStates[guildID] = states
+	}
+
+	states[voiceState.UserID] = *voiceState
+	return nil
+}
+
+func (s *DefaultStore) VoiceStateRemove(guildID, userID discord.Snowflake) error {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	states, ok := s.voiceStates[guildID]
+	if !ok {
+		return ErrStoreNotFound
+	}
+
+	if _, ok := states[userID]; !ok {
+		return ErrStoreNotFound
+	}
+
+	delete(states, userID)
+	return nil
+}
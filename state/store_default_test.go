@@ -0,0 +1,212 @@
+// +build unit
+
+package state
+
+import (
+	"testing"
+
+	"github.com/diamondburned/arikawa/discord"
+)
+
+func TestDefaultStoreMembers(t *testing.T) {
+	s := NewDefaultStore(nil)
+
+	for i := discord.Snowflake(1); i <= 3; i++ {
+		s.MemberSet(1, &discord.Member{User: discord.User{ID: i}})
+	}
+
+	s.MemberSet(1, &discord.Member{User: discord.User{ID: 2}, Nick: "nick"})
+
+	if m, err := s.Member(1, 2); err != nil || m.Nick != "nick" {
+		t.Fatal("Member not updated:", m, err)
+	}
+
+	if err := s.MemberRemove(1, 1); err != nil {
+		t.Fatal("Failed to remove member:", err)
+	}
+
+	if err := s.MemberRemove(1, 1); err != ErrStoreNotFound {
+		t.Fatal("Member removed twice:", err)
+	}
+
+	ms, err := s.Members(1)
+	if err != nil {
+		t.Fatal("Failed to get members:", err)
+	}
+
+	if len(ms) != 2 {
+		t.Fatal("Unexpected members:", ms)
+	}
+}
+
+func TestDefaultStoreChannels(t *testing.T) {
+	s := NewDefaultStore(nil)
+
+	s.ChannelSet(&discord.Channel{ID: 10, GuildID: 1, Position: 1})
+	s.ChannelSet(&discord.Channel{ID: 20, GuildID: 1, Position: 0})
+	s.ChannelSet(&discord.Channel{ID: 30, Type: discord.DirectMessage})
+
+	if ch, err := s.Channel(10); err != nil || ch.GuildID != 1 {
+		t.Fatal("Unexpected channel:", ch, err)
+	}
+
+	if ch, err := s.Channel(30); err != nil || ch.Type != discord.DirectMessage {
+		t.Fatal("Unexpected private channel:", ch, err)
+	}
+
+	chs, err := s.Channels(1)
+	if err != nil {
+		t.Fatal("Failed to get channels:", err)
+	}
+
+	if len(chs) != 2 || chs[0].ID != 20 || chs[1].ID != 10 {
+		t.Fatal("Unexpected channels:", chs)
+	}
+
+	if err := s.ChannelRemove(&discord.Channel{ID: 10, GuildID: 1}); err != nil {
+		t.Fatal("Failed to remove channel:", err)
+	}
+
+	if _, err := s.Channel(10); err != ErrStoreNotFound {
+		t.Fatal("Channel not removed:", err)
+	}
+}
+
+func TestDefaultStoreMessages(t *testing.T) {
+	s := NewDefaultStore(&DefaultStoreOptions{MaxMessages: 2})
+
+	for i := discord.Snowflake(1); i <= 3; i++ {
+		s.MessageSet(&discord.Message{ID: i, ChannelID: 1})
+	}
+
+	ms, err := s.Messages(1)
+	if err != nil {
+		t.Fatal("Failed to get messages:", err)
+	}
+
+	if len(ms) != 2 || ms[0].ID != 3 || ms[1].ID != 2 {
+		t.Fatal("Unexpected messages:", ms)
+	}
+}
+
+func TestDefaultStoreOrder(t *testing.T) {
+	s := NewDefaultStore(nil)
+
+	for _, id := range []discord.Snowflake{5, 3, 9, 1, 7} {
+		s.MemberSet(1, &discord.Member{User: discord.User{ID: id}})
+		s.PresenceSet(1, &discord.Presence{User: discord.User{ID: id}})
+		s.VoiceStateSet(1, &discord.VoiceState{UserID: id})
+	}
+
+	ms, _ := s.Members(1)
+	ps, _ := s.Presences(1)
+	vs, _ := s.VoiceStates(1)
+
+	for i := 1; i < 5; i++ {
+		if ms[i-1].User.ID > ms[i].User.ID {
+			t.Fatal("Members not sorted:", ms)
+		}
+		if ps[i-1].User.ID > ps[i].User.ID {
+			t.Fatal("Presences not sorted:", ps)
+		}
+		if vs[i-1].UserID > vs[i].UserID {
+			t.Fatal("Voice states not sorted:", vs)
+		}
+	}
+}
+
+func TestDefaultStoreGuildCopy(t *testing.T) {
+	s := NewDefaultStore(nil)
+
+	s.GuildSet(&discord.Guild{
+		ID:     1,
+		Roles:  []discord.Role{{ID: 10, Name: "a"}},
+		Emojis: []discord.Emoji{{ID: 20, Name: "a"}},
+	})
+
+	g, _ := s.Guild(1)
+
+	s.RoleSet(1, &discord.Role{ID: 10, Name: "b"})
+	s.EmojiSet(1, []discord.Emoji{{ID: 20, Name: "b"}})
+	s.RoleRemove(1, 10)
+
+	if g.Roles[0].Name != "a" || g.Emojis[0].Name != "a" {
+		t.Fatal("Returned guild was modified:", g.Roles, g.Emojis)
+	}
+}
+
+func TestDefaultStoreGuildRemove(t *testing.T) {
+	s := NewDefaultStore(nil)
+
+	s.GuildSet(&discord.Guild{ID: 1})
+	s.ChannelSet(&discord.Channel{ID: 10, GuildID: 1})
+	s.MessageSet(&discord.Message{ID: 11, ChannelID: 10})
+	s.MemberSet(1, &discord.Member{User: discord.User{ID: 20}})
+	s.PresenceSet(1, &discord.Presence{User: discord.User{ID: 20}})
+	s.VoiceStateSet(1, &discord.VoiceState{UserID: 20})
+
+	s.GuildRemove(1)
+
+	if len(s.channels) > 0 || len(s.channelGuilds) > 0 || len(s.messages) > 0 ||
+		len(s.members) > 0 || len(s.presences) > 0 || len(s.voiceStates) > 0 {
+
+		t.Fatal("Entities of the removed guild are left behind")
+	}
+}
+
+func newBenchmarkStore(members int) *DefaultStore {
+	s := NewDefaultStore(nil)
+
+	for i := 1; i <= members; i++ {
+		s.MemberSet(1, &discord.Member{
+			User: discord.User{ID: discord.Snowflake(i)},
+		})
+	}
+
+	return s
+}
+
+func BenchmarkDefaultStoreMember(b *testing.B) {
+	s := newBenchmarkStore(100000)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := s.Member(1, discord.Snowflake(i%100000+1)); err != nil {
+			b.Fatal("Failed to get member:", err)
+		}
+	}
+}
+
+func BenchmarkDefaultStoreMemberParallel(b *testing.B) {
+	s := newBenchmarkStore(100000)
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		var i int
+		for pb.Next() {
+			s.Member(1, discord.Snowflake(i%100000+1))
+			i++
+		}
+	})
+}
+
+func BenchmarkDefaultStoreMemberSet(b *testing.B) {
+	s := newBenchmarkStore(100000)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		s.MemberSet(1, &discord.Member{
+			User: discord.User{ID: discord.Snowflake(i%100000 + 1)},
+		})
+	}
+}
+
+func BenchmarkDefaultStorePresenceSet(b *testing.B) {
+	s := NewDefaultStore(nil)
+
+	for i := 0; i < b.N; i++ {
+		s.PresenceSet(1, &discord.Presence{
+			User: discord.User{ID: discord.Snowflake(i%100000 + 1)},
+		})
+	}
+}
@@ -0,0 +1,771 @@
+package state
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/diamondburned/arikawa/discord"
+)
+
+// ExpiryOptions limits how long and how many entities of a kind are kept.
+type ExpiryOptions struct {
+	// TTL is how long an entity is kept after it's last set. 0 means forever.
+	TTL time.Duration
+	// Capacity is the maximum number of entities kept. The least recently
+	// used ones are evicted first. 0 means no limit.
+	Capacity int
+}
+
+type ExpiryStoreOptions struct {
+	Guilds      ExpiryOptions // also contains roles and emojis
+	Channels    ExpiryOptions
+	Members     ExpiryOptions
+	Presences   ExpiryOptions
+	Messages    ExpiryOptions
+	VoiceStates ExpiryOptions
+
+	MaxMessages uint // per channel, default 50
+}
+
+// DefaultExpiryStoreOptions keeps guilds, channels and voice states, which
+// are kept up to date by the Gateway, forever. Members, presences and messages
+// are limited.
+func DefaultExpiryStoreOptions() ExpiryStoreOptions {
+	return ExpiryStoreOptions{
+		Members: ExpiryOptions{
+			TTL:      time.Hour,
+			Capacity: 100000,
+		},
+		Presences: ExpiryOptions{
+			TTL:      10 * time.Minute,
+			Capacity: 100000,
+		},
+		Messages: ExpiryOptions{
+			TTL:      time.Hour,
+			Capacity: 10000,
+		},
+		MaxMessages: 50,
+	}
+}
+
+// ExpiryStats are the counters of an entity kind. Evictions include both
+// expired and over-capacity entities, but not removed ones.
+type ExpiryStats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	Size      int
+}
+
+type ExpiryStoreStats struct {
+	Guilds      ExpiryStats
+	Channels    ExpiryStats
+	Members     ExpiryStats
+	Presences   ExpiryStats
+	Messages    ExpiryStats
+	VoiceStates ExpiryStats
+}
+
+// ExpiryStore is a Store that evicts entities after a TTL or when there are
+// too many of them, unlike DefaultStore, which keeps everything forever. A
+// miss makes the State fetch the entity from the API again, if it can. Lists
+// that had an entity evicted are missed as a whole, rather than returned
+// partially.
+type ExpiryStore struct {
+	ExpiryStoreOptions
+
+	self discord.User
+
+	guilds      *lruCache // guildID:*guild, parent 0
+	channels    *lruCache // channelID:channel, parent guildID or 0 if private
+	members     *lruCache // guildID+userID:member
+	presences   *lruCache // guildID+userID:presence
+	messages    *lruCache // channelID+messageID:message
+	voiceStates *lruCache // guildID+userID:voiceState
+
+	now func() time.Time
+	mut sync.Mutex
+}
+
+var _ Store = (*ExpiryStore)(nil)
+
+func NewExpiryStore(opts *ExpiryStoreOptions) *ExpiryStore {
+	var o = DefaultExpiryStoreOptions()
+	if opts != nil {
+		o = *opts
+	}
+
+	if o.MaxMessages == 0 {
+		o.MaxMessages = 50
+	}
+
+	es := &ExpiryStore{
+		ExpiryStoreOptions: o,
+		now:                time.Now,
+	}
+	es.Reset()
+
+	return es
+}
+
+func (s *ExpiryStore) Reset() error {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	s.self = discord.User{}
+
+	s.guilds = newLRUCache(s.ExpiryStoreOptions.Guilds)
+	s.channels = newLRUCache(s.ExpiryStoreOptions.Channels)
+	s.members = newLRUCache(s.ExpiryStoreOptions.Members)
+	s.presences = newLRUCache(s.ExpiryStoreOptions.Presences)
+	s.messages = newLRUCache(s.ExpiryStoreOptions.Messages)
+	s.messages.latest = true // only the latest MaxMessages are kept
+	s.voiceStates = newLRUCache(s.ExpiryStoreOptions.VoiceStates)
+
+	return nil
+}
+
+// Stats returns the counters since the last Reset.
+func (s *ExpiryStore) Stats() ExpiryStoreStats {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	return ExpiryStoreStats{
+		Guilds:      s.guilds.Stats(),
+		Channels:    s.channels.Stats(),
+		Members:     s.members.Stats(),
+		Presences:   s.presences.Stats(),
+		Messages:    s.messages.Stats(),
+		VoiceStates: s.voiceStates.Stats(),
+	}
+}
+
+////
+
+func (s *ExpiryStore) Me() (*discord.User, error) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	if !s.self.ID.Valid() {
+		return nil, ErrStoreNotFound
+	}
+
+	me := s.self
+	return &me, nil
+}
+
+func (s *ExpiryStore) MyselfSet(me *discord.User) error {
+	s.mut.Lock()
+	s.self = *me
+	s.mut.Unlock()
+
+	return nil
+}
+
+////
+
+func (s *ExpiryStore) Channel(id discord.Snowflake) (*discord.Channel, error) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	v, ok := s.channels.get(lruKey{id: id}, s.now())
+	if !ok {
+		return nil, ErrStoreNotFound
+	}
+
+	ch := v.(discord.Channel)
+	return &ch, nil
+}
+
+func (s *ExpiryStore) Channels(
+	guildID discord.Snowflake) ([]discord.Channel, error) {
+
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	vs, ok := s.channels.children(guildID, s.now())
+	if !ok || len(vs) == 0 {
+		return nil, ErrStoreNotFound
+	}
+
+	var chs = make([]discord.Channel, len(vs))
+	for i, v := range vs {
+		chs[i] = v.(discord.Channel)
+	}
+
+	sort.Slice(chs, func(i, j int) bool {
+		return chs[i].Position < chs[j].Position
+	})
+
+	return chs, nil
+}
+
+func (s *ExpiryStore) PrivateChannels() ([]discord.Channel, error) {
+	s.mut.Lock()
+	vs, ok := s.channels.children(0, s.now())
+	s.mut.Unlock()
+
+	if !ok {
+		return nil, ErrStoreNotFound
+	}
+
+	var chs = make([]discord.Channel, len(vs))
+	for i, v := range vs {
+		chs[i] = v.(discord.Channel)
+	}
+
+	sort.Slice(chs, func(i, j int) bool {
+		// Latest first
+		return chs[i].LastMessageID > chs[j].LastMessageID
+	})
+
+	return chs, nil
+}
+
+func (s *ExpiryStore) ChannelSet(channel *discord.Channel) error {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	var now = s.now()
+	var key = lruKey{id: channel.ID}
+
+	var parent discord.Snowflake
+	switch channel.Type {
+	case discord.DirectMessage, discord.GroupDM:
+		// Private channels are listed under 0.
+	default:
+		parent = channel.GuildID
+
+		if v, ok := s.channels.lookup(key, now); ok {
+			// Also from discordgo.
+			if channel.Permissions == nil {
+				channel.Permissions = v.(discord.Channel).Permissions
+			}
+		}
+	}
+
+	s.channels.set(key, parent, *channel, now)
+	return nil
+}
+
+func (s *ExpiryStore) ChannelRemove(channel *discord.Channel) error {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	if !s.channels.remove(lruKey{id: channel.ID}) {
+		return ErrStoreNotFound
+	}
+
+	return nil
+}
+
+////
+
+func (s *ExpiryStore) Emoji(
+	guildID, emojiID discord.Snowflake) (*discord.Emoji, error) {
+
+	gd, err := s.Guild(guildID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, emoji := range gd.Emojis {
+		if emoji.ID == emojiID {
+			return &emoji, nil
+		}
+	}
+
+	return nil, ErrStoreNotFound
+}
+
+func (s *ExpiryStore) Emojis(
+	guildID discord.Snowflake) ([]discord.Emoji, error) {
+
+	gd, err := s.Guild(guildID)
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]discord.Emoji{}, gd.Emojis...), nil
+}
+
+func (s *ExpiryStore) EmojiSet(
+	guildID discord.Snowflake, emojis []discord.Emoji) error {
+
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	v, ok := s.guilds.lookup(lruKey{id: guildID}, s.now())
+	if !ok {
+		return ErrStoreNotFound
+	}
+
+	gd := v.(*discord.Guild)
+
+	// Copy the slice, as it could be shared with a returned Guild.
+	var es = append([]discord.Emoji{}, gd.Emojis...)
+
+Main:
+	for _, enew := range emojis {
+		// Try and see if this emoji is already in the slice
+		for i, emoji := range es {
+			if emoji.ID == enew.ID {
+				// If it is, we simply replace it
+				es[i] = enew
+
+				continue Main
+			}
+		}
+
+		es = append(es, enew)
+	}
+
+	gd.Emojis = es
+	return nil
+}
+
+////
+
+func (s *ExpiryStore) Guild(id discord.Snowflake) (*discord.Guild, error) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	v, ok := s.guilds.get(lruKey{id: id}, s.now())
+	if !ok {
+		return nil, ErrStoreNotFound
+	}
+
+	gd := *v.(*discord.Guild)
+	return &gd, nil
+}
+
+func (s *ExpiryStore) Guilds() ([]discord.Guild, error) {
+	s.mut.Lock()
+	vs, ok := s.guilds.children(0, s.now())
+
+	if !ok || len(vs) == 0 {
+		s.mut.Unlock()
+		return nil, ErrStoreNotFound
+	}
+
+	var gs = make([]discord.Guild, len(vs))
+	for i, v := range vs {
+		gs[i] = *v.(*discord.Guild)
+	}
+
+	s.mut.Unlock()
+
+	sort.Slice(gs, func(i, j int) bool {
+		return gs[i].ID > gs[j].ID
+	})
+
+	return gs, nil
+}
+
+func (s *ExpiryStore) GuildSet(guild *discord.Guild) error {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	var now = s.now()
+	var key = lruKey{id: guild.ID}
+
+	// Copy the guild, since it's modified in place by RoleSet and EmojiSet.
+	var gd = *guild
+
+	if v, ok := s.guilds.lookup(key, now); ok {
+		old := v.(*discord.Guild)
+
+		// preserve state stuff
+		if gd.Roles == nil {
+			gd.Roles = old.Roles
+		}
+		if gd.Emojis == nil {
+			gd.Emojis = old.Emojis
+		}
+	}
+
+	s.guilds.set(key, 0, &gd, now)
+	return nil
+}
+
+// GuildRemove removes the guild along with everything in it.
+func (s *ExpiryStore) GuildRemove(id discord.Snowflake) error {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	s.guilds.remove(lruKey{id: id})
+
+	for k := range s.channels.parents[id] {
+		s.messages.removeParent(k.id)
+	}
+
+	s.channels.removeParent(id)
+	s.members.removeParent(id)
+	s.presences.removeParent(id)
+	s.voiceStates.removeParent(id)
+
+	return nil
+}
+
+////
+
+func (s *ExpiryStore) Member(
+	guildID, userID discord.Snowflake) (*discord.Member, error) {
+
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	v, ok := s.members.get(lruKey{guildID, userID}, s.now())
+	if !ok {
+		return nil, ErrStoreNotFound
+	}
+
+	m := v.(discord.Member)
+	return &m, nil
+}
+
+func (s *ExpiryStore) Members(
+	guildID discord.Snowflake) ([]discord.Member, error) {
+
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	vs, ok := s.members.children(guildID, s.now())
+	if !ok || len(vs) == 0 {
+		return nil, ErrStoreNotFound
+	}
+
+	var ms = make([]discord.Member, len(vs))
+	for i, v := range vs {
+		ms[i] = v.(discord.Member)
+	}
+
+	sort.Slice(ms, func(i, j int) bool {
+		return ms[i].User.ID < ms[j].User.ID
+	})
+
+	return ms, nil
+}
+
+func (s *ExpiryStore) MemberSet(
+	guildID discord.Snowflake, member *discord.Member) error {
+
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	s.members.set(lruKey{guildID, member.User.ID}, guildID, *member, s.now())
+	return nil
+}
+
+func (s *ExpiryStore) MemberRemove(guildID, userID discord.Snowflake) error {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	if !s.members.remove(lruKey{guildID, userID}) {
+		return ErrStoreNotFound
+	}
+
+	return nil
+}
+
+////
+
+func (s *ExpiryStore) Message(
+	channelID, messageID discord.Snowflake) (*discord.Message, error) {
+
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	v, ok := s.messages.get(lruKey{channelID, messageID}, s.now())
+	if !ok {
+		return nil, ErrStoreNotFound
+	}
+
+	m := v.(discord.Message)
+	return &m, nil
+}
+
+// Messages returns the messages in the channel, latest first, like
+// DefaultStore.
+func (s *ExpiryStore) Messages(
+	channelID discord.Snowflake) ([]discord.Message, error) {
+
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	vs, ok := s.messages.children(channelID, s.now())
+	if !ok || len(vs) == 0 {
+		return nil, ErrStoreNotFound
+	}
+
+	var ms = make([]discord.Message, len(vs))
+	for i, v := range vs {
+		ms[i] = v.(discord.Message)
+	}
+
+	sort.Slice(ms, func(i, j int) bool {
+		return ms[i].ID > ms[j].ID
+	})
+
+	return ms, nil
+}
+
+func (s *ExpiryStore) MaxMessages() int {
+	return int(s.ExpiryStoreOptions.MaxMessages)
+}
+
+func (s *ExpiryStore) MessageSet(message *discord.Message) error {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	var now = s.now()
+	var key = lruKey{message.ChannelID, message.ID}
+
+	// Check if we already have the message.
+	if v, ok := s.messages.lookup(key, now); ok {
+		m := v.(discord.Message)
+		MergeMessage(&m, message)
+
+		s.messages.set(key, message.ChannelID, m, now)
+		return nil
+	}
+
+	s.messages.set(key, message.ChannelID, *message, now)
+
+	// Evict the oldest message in the channel if there are too many.
+	if els := s.messages.parents[message.ChannelID]; len(els) > s.MaxMessages() {
+		var oldest = key
+		for k := range els {
+			if k.id < oldest.id {
+				oldest = k
+			}
+		}
+
+		s.messages.trim(els[oldest])
+	}
+
+	return nil
+}
+
+func (s *ExpiryStore) MessageRemove(
+	channelID, messageID discord.Snowflake) error {
+
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	if !s.messages.remove(lruKey{channelID, messageID}) {
+		return ErrStoreNotFound
+	}
+
+	return nil
+}
+
+////
+
+func (s *ExpiryStore) Presence(
+	guildID, userID discord.Snowflake) (*discord.Presence, error) {
+
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	v, ok := s.presences.get(lruKey{guildID, userID}, s.now())
+	if !ok {
+		return nil, ErrStoreNotFound
+	}
+
+	p := v.(discord.Presence)
+	return &p, nil
+}
+
+func (s *ExpiryStore) Presences(
+	guildID discord.Snowflake) ([]discord.Presence, error) {
+
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	vs, ok := s.presences.children(guildID, s.now())
+	if !ok || len(vs) == 0 {
+		return nil, ErrStoreNotFound
+	}
+
+	var ps = make([]discord.Presence, len(vs))
+	for i, v := range vs {
+		ps[i] = v.(discord.Presence)
+	}
+
+	sort.Slice(ps, func(i, j int) bool {
+		return ps[i].User.ID < ps[j].User.ID
+	})
+
+	return ps, nil
+}
+
+func (s *ExpiryStore) PresenceSet(
+	guildID discord.Snowflake, presence *discord.Presence) error {
+
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	s.presences.set(
+		lruKey{guildID, presence.User.ID}, guildID, *presence, s.now())
+
+	return nil
+}
+
+func (s *ExpiryStore) PresenceRemove(guildID, userID discord.Snowflake) error {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	if !s.presences.remove(lruKey{guildID, userID}) {
+		return ErrStoreNotFound
+	}
+
+	return nil
+}
+
+////
+
+func (s *ExpiryStore) Role(
+	guildID, roleID discord.Snowflake) (*discord.Role, error) {
+
+	gd, err := s.Guild(guildID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, r := range gd.Roles {
+		if r.ID == roleID {
+			return &r, nil
+		}
+	}
+
+	return nil, ErrStoreNotFound
+}
+
+func (s *ExpiryStore) Roles(
+	guildID discord.Snowflake) ([]discord.Role, error) {
+
+	gd, err := s.Guild(guildID)
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]discord.Role{}, gd.Roles...), nil
+}
+
+func (s *ExpiryStore) RoleSet(
+	guildID discord.Snowflake, role *discord.Role) error {
+
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	v, ok := s.guilds.lookup(lruKey{id: guildID}, s.now())
+	if !ok {
+		return ErrStoreNotFound
+	}
+
+	gd := v.(*discord.Guild)
+
+	// Copy the slice, as it could be shared with a returned Guild.
+	var rs = append([]discord.Role{}, gd.Roles...)
+
+	for i, r := range rs {
+		if r.ID == role.ID {
+			rs[i] = *role
+			gd.Roles = rs
+			return nil
+		}
+	}
+
+	gd.Roles = append(rs, *role)
+	return nil
+}
+
+func (s *ExpiryStore) RoleRemove(guildID, roleID discord.Snowflake) error {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	v, ok := s.guilds.lookup(lruKey{id: guildID}, s.now())
+	if !ok {
+		return ErrStoreNotFound
+	}
+
+	gd := v.(*discord.Guild)
+
+	for i, r := range gd.Roles {
+		if r.ID == roleID {
+			rs := make([]discord.Role, 0, len(gd.Roles)-1)
+			rs = append(rs, gd.Roles[:i]...)
+			rs = append(rs, gd.Roles[i+1:]...)
+
+			gd.Roles = rs
+			return nil
+		}
+	}
+
+	return ErrStoreNotFound
+}
+
+////
+
+func (s *ExpiryStore) VoiceState(
+	guildID, userID discord.Snowflake) (*discord.VoiceState, error) {
+
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	v, ok := s.voiceStates.get(lruKey{guildID, userID}, s.now())
+	if !ok {
+		return nil, ErrStoreNotFound
+	}
+
+	vs := v.(discord.VoiceState)
+	return &vs, nil
+}
+
+func (s *ExpiryStore) VoiceStates(
+	guildID discord.Snowflake) ([]discord.VoiceState, error) {
+
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	vs, ok := s.voiceStates.children(guildID, s.now())
+	if !ok || len(vs) == 0 {
+		return nil, ErrStoreNotFound
+	}
+
+	var states = make([]discord.VoiceState, len(vs))
+	for i, v := range vs {
+		states[i] = v.(discord.VoiceState)
+	}
+
+	sort.Slice(states, func(i, j int) bool {
+		return states[i].UserID < states[j].UserID
+	})
+
+	return states, nil
+}
+
+func (s *ExpiryStore) VoiceStateSet(
+	guildID discord.Snowflake, voiceState *discord.VoiceState) error {
+
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	s.voiceStates.set(
+		lruKey{guildID, voiceState.UserID}, guildID, *voiceState, s.now())
+
+	return nil
+}
+
+func (s *ExpiryStore) VoiceStateRemove(guildID, userID discord.Snowflake) error {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	if !s.voiceStates.remove(lruKey{guildID, userID}) {
+		return ErrStoreNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,233 @@
+// +build unit
+
+package state
+
+import (
+	"testing"
+	"time"
+
+	"github.com/diamondburned/arikawa/discord"
+)
+
+func newTestExpiryStore(opts ExpiryStoreOptions) (*ExpiryStore, *time.Time) {
+	var now = time.Unix(0, 0)
+
+	s := NewExpiryStore(&opts)
+	s.now = func() time.Time { return now }
+
+	return s, &now
+}
+
+func TestExpiryStoreTTL(t *testing.T) {
+	s, now := newTestExpiryStore(ExpiryStoreOptions{
+		Members: ExpiryOptions{TTL: time.Minute},
+	})
+
+	s.MemberSet(1, &discord.Member{User: discord.User{ID: 100}})
+
+	*now = now.Add(30 * time.Second)
+	s.MemberSet(1, &discord.Member{User: discord.User{ID: 200}})
+
+	if _, err := s.Member(1, 100); err != nil {
+		t.Fatal("Member expired early:", err)
+	}
+
+	*now = now.Add(45 * time.Second)
+
+	if _, err := s.Member(1, 100); err != ErrStoreNotFound {
+		t.Fatal("Member didn't expire:", err)
+	}
+
+	// The list is missing a member, so it has to be fetched again.
+	if ms, err := s.Members(1); err != ErrStoreNotFound {
+		t.Fatal("Got an incomplete list of members:", ms, err)
+	}
+
+	stats := s.Stats().Members
+	if stats.Hits != 1 || stats.Misses != 2 || stats.Evictions != 1 || stats.Size != 1 {
+		t.Fatalf("Unexpected stats: %#v", stats)
+	}
+
+	// The rest of the list is kept.
+	if _, err := s.Member(1, 200); err != nil {
+		t.Fatal("Member dropped with the incomplete list:", err)
+	}
+
+	// Setting the evicted member again makes the list complete.
+	s.MemberSet(1, &discord.Member{User: discord.User{ID: 100}})
+
+	if ms, err := s.Members(1); err != nil || len(ms) != 2 {
+		t.Fatal("Unexpected members:", ms, err)
+	}
+}
+
+func TestExpiryStoreSweep(t *testing.T) {
+	s, now := newTestExpiryStore(ExpiryStoreOptions{
+		Presences: ExpiryOptions{TTL: time.Minute},
+	})
+
+	for i := discord.Snowflake(1); i <= 10; i++ {
+		s.PresenceSet(1, &discord.Presence{User: discord.User{ID: i}})
+	}
+
+	*now = now.Add(2 * time.Minute)
+
+	// Setting anything should clean up the expired presences, even if
+	// they're never accessed again.
+	s.PresenceSet(2, &discord.Presence{User: discord.User{ID: 1}})
+
+	if stats := s.Stats().Presences; stats.Evictions != 10 || stats.Size != 1 {
+		t.Fatalf("Unexpected stats: %#v", stats)
+	}
+}
+
+func TestExpiryStoreCapacity(t *testing.T) {
+	s, _ := newTestExpiryStore(ExpiryStoreOptions{
+		Guilds: ExpiryOptions{Capacity: 2},
+	})
+
+	s.GuildSet(&discord.Guild{ID: 1})
+	s.GuildSet(&discord.Guild{ID: 2})
+
+	// Use the first guild, so the second one is the least recently used.
+	if _, err := s.Guild(1); err != nil {
+		t.Fatal("Failed to get guild:", err)
+	}
+
+	s.GuildSet(&discord.Guild{ID: 3})
+
+	if _, err := s.Guild(2); err != ErrStoreNotFound {
+		t.Fatal("Least recently used guild not evicted:", err)
+	}
+
+	if stats := s.Stats().Guilds; stats.Evictions != 1 || stats.Size != 2 {
+		t.Fatalf("Unexpected stats: %#v", stats)
+	}
+
+	if gs, err := s.Guilds(); err != ErrStoreNotFound {
+		t.Fatal("Got an incomplete list of guilds:", gs, err)
+	}
+
+	for _, id := range []discord.Snowflake{1, 3} {
+		if _, err := s.Guild(id); err != nil {
+			t.Fatal("Guild dropped with the incomplete list:", err)
+		}
+	}
+
+	// Removing the evicted guild makes the list complete.
+	s.GuildRemove(2)
+
+	if gs, err := s.Guilds(); err != nil || len(gs) != 2 {
+		t.Fatal("Unexpected guilds:", gs, err)
+	}
+}
+
+func TestExpiryStoreMove(t *testing.T) {
+	s, _ := newTestExpiryStore(ExpiryStoreOptions{})
+
+	s.ChannelSet(&discord.Channel{ID: 10, GuildID: 1})
+	s.ChannelSet(&discord.Channel{ID: 20, GuildID: 1})
+	s.ChannelSet(&discord.Channel{ID: 10, GuildID: 2})
+
+	if chs, err := s.Channels(1); err != nil || len(chs) != 1 || chs[0].ID != 20 {
+		t.Fatal("Moved channel still in the old guild:", chs, err)
+	}
+
+	if chs, err := s.Channels(2); err != nil || len(chs) != 1 || chs[0].ID != 10 {
+		t.Fatal("Moved channel not in the new guild:", chs, err)
+	}
+}
+
+func TestExpiryStoreMe(t *testing.T) {
+	s, _ := newTestExpiryStore(ExpiryStoreOptions{})
+	s.MyselfSet(&discord.User{ID: 1, Username: "a"})
+
+	me, _ := s.Me()
+	me.Username = "b"
+
+	if me, _ := s.Me(); me.Username != "a" {
+		t.Fatal("Returned user shares the cached one:", me.Username)
+	}
+}
+
+func TestExpiryStoreRoles(t *testing.T) {
+	s, _ := newTestExpiryStore(ExpiryStoreOptions{})
+
+	s.GuildSet(&discord.Guild{
+		ID:    1,
+		Roles: []discord.Role{{ID: 10, Name: "a"}},
+	})
+
+	g, _ := s.Guild(1)
+
+	s.RoleSet(1, &discord.Role{ID: 10, Name: "b"})
+	s.RoleSet(1, &discord.Role{ID: 20})
+
+	if g.Roles[0].Name != "a" {
+		t.Fatal("Returned guild was modified:", g.Roles)
+	}
+
+	// Roles should be kept if the guild update doesn't have them.
+	s.GuildSet(&discord.Guild{ID: 1})
+
+	if r, err := s.Role(1, 10); err != nil || r.Name != "b" {
+		t.Fatal("Unexpected role:", r, err)
+	}
+
+	if err := s.RoleRemove(1, 10); err != nil {
+		t.Fatal("Failed to remove role:", err)
+	}
+
+	if rs, _ := s.Roles(1); len(rs) != 1 || rs[0].ID != 20 {
+		t.Fatal("Unexpected roles:", rs)
+	}
+}
+
+func TestExpiryStoreMessages(t *testing.T) {
+	s, _ := newTestExpiryStore(ExpiryStoreOptions{MaxMessages: 2})
+
+	s.MessageSet(&discord.Message{ID: 2, ChannelID: 1, Content: "2"})
+	s.MessageSet(&discord.Message{ID: 1, ChannelID: 1, Content: "1"})
+	s.MessageSet(&discord.Message{ID: 3, ChannelID: 1, Content: "3"})
+
+	// Partial update.
+	s.MessageSet(&discord.Message{ID: 2, ChannelID: 1})
+
+	ms, err := s.Messages(1)
+	if err != nil {
+		t.Fatal("Failed to get messages:", err)
+	}
+
+	if len(ms) != 2 || ms[0].ID != 3 || ms[1].ID != 2 || ms[1].Content != "2" {
+		t.Fatal("Unexpected messages:", ms)
+	}
+
+	if err := s.MessageRemove(1, 1); err != ErrStoreNotFound {
+		t.Fatal("Oldest message not evicted:", err)
+	}
+}
+
+func TestExpiryStoreMessagesEvicted(t *testing.T) {
+	s, _ := newTestExpiryStore(ExpiryStoreOptions{
+		MaxMessages: 10,
+		Messages:    ExpiryOptions{Capacity: 2},
+	})
+
+	s.MessageSet(&discord.Message{ID: 1, ChannelID: 1})
+	s.MessageSet(&discord.Message{ID: 2, ChannelID: 1})
+	s.MessageSet(&discord.Message{ID: 3, ChannelID: 1})
+
+	// Only the latest messages are listed, so evicting the oldest one doesn't
+	// leave a gap.
+	if ms, err := s.Messages(1); err != nil || len(ms) != 2 || ms[1].ID != 2 {
+		t.Fatal("Unexpected messages:", ms, err)
+	}
+
+	// Message 3 is now the least recently used.
+	s.Message(1, 2)
+	s.MessageSet(&discord.Message{ID: 4, ChannelID: 1})
+
+	if ms, err := s.Messages(1); err != ErrStoreNotFound {
+		t.Fatal("Got messages with a gap:", ms, err)
+	}
+}
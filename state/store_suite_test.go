@@ -0,0 +1,22 @@
+// +build unit
+
+package state_test
+
+import (
+	"testing"
+
+	"github.com/diamondburned/arikawa/state"
+	"github.com/diamondburned/arikawa/state/storetest"
+)
+
+func TestDefaultStoreSuite(t *testing.T) {
+	storetest.TestStore(t, func(t *testing.T) state.Store {
+		return state.NewDefaultStore(nil)
+	})
+}
+
+func TestExpiryStoreSuite(t *testing.T) {
+	storetest.TestStore(t, func(t *testing.T) state.Store {
+		return state.NewExpiryStore(&state.ExpiryStoreOptions{})
+	})
+}
@@ -0,0 +1,593 @@
+// Package storetest has the behavioral tests every state.Store implementation
+// should pass.
+package storetest
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/diamondburned/arikawa/discord"
+	"github.com/diamondburned/arikawa/state"
+)
+
+// TestStore runs all tests against the Stores made by newStore. Each test gets
+// its own empty Store. The Stores shouldn't limit anything other than
+// MaxMessages.
+func TestStore(t *testing.T, newStore func(t *testing.T) state.Store) {
+	var tests = []struct {
+		name string
+		test func(t *testing.T, s state.Store)
+	}{
+		{"Me", testMe},
+		{"Channels", testChannels},
+		{"ChannelMove", testChannelMove},
+		{"Emojis", testEmojis},
+		{"Guilds", testGuilds},
+		{"GuildRemove", testGuildRemove},
+		{"Members", testMembers},
+		{"Messages", testMessages},
+		{"Presences", testPresences},
+		{"Roles", testRoles},
+		{"VoiceStates", testVoiceStates},
+		{"Reset", testReset},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			test.test(t, newStore(t))
+		})
+	}
+}
+
+func expectNotFound(t *testing.T, err error, what string) {
+	t.Helper()
+
+	if err != state.ErrStoreNotFound {
+		t.Fatalf("Expected ErrStoreNotFound for %s, got %v", what, err)
+	}
+}
+
+func expectNoError(t *testing.T, err error, what string) {
+	t.Helper()
+
+	if err != nil {
+		t.Fatalf("Failed to %s: %v", what, err)
+	}
+}
+
+func sortIDs(ids []discord.Snowflake) []discord.Snowflake {
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+func expectIDs(t *testing.T, got, expected []discord.Snowflake, what string) {
+	t.Helper()
+
+	sortIDs(got)
+	sortIDs(expected)
+
+	if len(got) != len(expected) {
+		t.Fatalf("Unexpected %s: %v, expected %v", what, got, expected)
+	}
+
+	for i := range got {
+		if got[i] != expected[i] {
+			t.Fatalf("Unexpected %s: %v, expected %v", what, got, expected)
+		}
+	}
+}
+
+// expectOrderedIDs checks the IDs in the order they were returned in.
+func expectOrderedIDs(
+	t *testing.T, got, expected []discord.Snowflake, what string) {
+
+	t.Helper()
+
+	if len(got) != len(expected) {
+		t.Fatalf("Unexpected %s: %v, expected %v", what, got, expected)
+	}
+
+	for i := range got {
+		if got[i] != expected[i] {
+			t.Fatalf("Unexpected %s: %v, expected %v", what, got, expected)
+		}
+	}
+}
+
+func testMe(t *testing.T, s state.Store) {
+	_, err := s.Me()
+	expectNotFound(t, err, "empty Me")
+
+	expectNoError(t, s.MyselfSet(&discord.User{ID: 1, Username: "me"}), "set Me")
+
+	u, err := s.Me()
+	expectNoError(t, err, "get Me")
+
+	if u.ID != 1 || u.Username != "me" {
+		t.Fatalf("Unexpected Me: %#v", u)
+	}
+}
+
+func testChannels(t *testing.T, s state.Store) {
+	_, err := s.Channel(10)
+	expectNotFound(t, err, "missing channel")
+
+	_, err = s.Channels(1)
+	expectNotFound(t, err, "missing guild channels")
+
+	var overwrites = []discord.Overwrite{{ID: 1, Type: discord.OverwriteRole}}
+
+	expectNoError(t, s.ChannelSet(&discord.Channel{
+		ID: 10, GuildID: 1, Name: "a", Position: 1, Permissions: overwrites,
+	}), "set channel")
+	expectNoError(t, s.ChannelSet(&discord.Channel{
+		ID: 20, GuildID: 1, Name: "b", Position: 0,
+	}), "set channel")
+	expectNoError(t, s.ChannelSet(&discord.Channel{
+		ID: 30, Type: discord.DirectMessage, LastMessageID: 1,
+	}), "set private channel")
+	expectNoError(t, s.ChannelSet(&discord.Channel{
+		ID: 40, Type: discord.GroupDM, LastMessageID: 2,
+	}), "set private channel")
+
+	// Updates without overwrites should keep them.
+	expectNoError(t, s.ChannelSet(&discord.Channel{
+		ID: 10, GuildID: 1, Name: "c", Position: 1,
+	}), "update channel")
+
+	ch, err := s.Channel(10)
+	expectNoError(t, err, "get channel")
+
+	if ch.Name != "c" || len(ch.Permissions) != 1 {
+		t.Fatalf("Unexpected channel: %#v", ch)
+	}
+
+	ch, err = s.Channel(30)
+	expectNoError(t, err, "get private channel")
+
+	if ch.Type != discord.DirectMessage {
+		t.Fatalf("Unexpected private channel: %#v", ch)
+	}
+
+	chs, err := s.Channels(1)
+	expectNoError(t, err, "get channels")
+
+	if len(chs) != 2 || chs[0].ID != 20 || chs[1].ID != 10 {
+		t.Fatal("Unexpected channels, expected sorted by position:", chs)
+	}
+
+	privates, err := s.PrivateChannels()
+	expectNoError(t, err, "get private channels")
+
+	if len(privates) != 2 || privates[0].ID != 40 || privates[1].ID != 30 {
+		t.Fatal("Unexpected private channels, expected latest first:", privates)
+	}
+
+	expectNoError(t, s.ChannelRemove(&discord.Channel{ID: 10, GuildID: 1}),
+		"remove channel")
+
+	_, err = s.Channel(10)
+	expectNotFound(t, err, "removed channel")
+
+	expectNotFound(t, s.ChannelRemove(&discord.Channel{ID: 10, GuildID: 1}),
+		"removing a removed channel")
+}
+
+func testChannelMove(t *testing.T, s state.Store) {
+	var overwrites = []discord.Overwrite{{ID: 1, Type: discord.OverwriteRole}}
+
+	expectNoError(t, s.ChannelSet(&discord.Channel{
+		ID: 10, GuildID: 1, Permissions: overwrites,
+	}), "set channel")
+	expectNoError(t, s.ChannelSet(&discord.Channel{
+		ID: 20, GuildID: 1,
+	}), "set channel")
+	expectNoError(t, s.ChannelSet(&discord.Channel{
+		ID: 10, GuildID: 2,
+	}), "move channel")
+
+	ch, err := s.Channel(10)
+	expectNoError(t, err, "get moved channel")
+
+	if ch.GuildID != 2 || len(ch.Permissions) != 1 {
+		t.Fatalf("Unexpected moved channel: %#v", ch)
+	}
+
+	chs, err := s.Channels(1)
+	expectNoError(t, err, "get channels of the old guild")
+
+	if len(chs) != 1 || chs[0].ID != 20 {
+		t.Fatal("Moved channel still in the old guild:", chs)
+	}
+
+	chs, err = s.Channels(2)
+	expectNoError(t, err, "get channels of the new guild")
+
+	if len(chs) != 1 || chs[0].ID != 10 {
+		t.Fatal("Moved channel not in the new guild:", chs)
+	}
+}
+
+func testGuilds(t *testing.T, s state.Store) {
+	_, err := s.Guild(1)
+	expectNotFound(t, err, "missing guild")
+
+	_, err = s.Guilds()
+	expectNotFound(t, err, "no guilds")
+
+	expectNoError(t, s.GuildSet(&discord.Guild{
+		ID:     1,
+		Name:   "a",
+		Roles:  []discord.Role{{ID: 10}},
+		Emojis: []discord.Emoji{{ID: 20, Name: "e"}},
+	}), "set guild")
+	expectNoError(t, s.GuildSet(&discord.Guild{ID: 2, Name: "b"}), "set guild")
+
+	// Updates without roles and emojis should keep them.
+	expectNoError(t, s.GuildSet(&discord.Guild{ID: 1, Name: "c"}), "update guild")
+
+	g, err := s.Guild(1)
+	expectNoError(t, err, "get guild")
+
+	if g.Name != "c" || len(g.Roles) != 1 || len(g.Emojis) != 1 {
+		t.Fatalf("Unexpected guild: %#v", g)
+	}
+
+	gs, err := s.Guilds()
+	expectNoError(t, err, "get guilds")
+
+	if len(gs) != 2 || gs[0].ID != 2 || gs[1].ID != 1 {
+		t.Fatal("Unexpected guilds, expected latest first:", gs)
+	}
+
+	expectNoError(t, s.GuildRemove(1), "remove guild")
+
+	_, err = s.Guild(1)
+	expectNotFound(t, err, "removed guild")
+
+	_, err = s.Roles(1)
+	expectNotFound(t, err, "roles of a removed guild")
+}
+
+func testGuildRemove(t *testing.T, s state.Store) {
+	for _, guildID := range []discord.Snowflake{1, 2} {
+		expectNoError(t, s.GuildSet(&discord.Guild{ID: guildID}), "set guild")
+		expectNoError(t, s.ChannelSet(&discord.Channel{
+			ID: guildID * 10, GuildID: guildID,
+		}), "set channel")
+		expectNoError(t, s.MessageSet(&discord.Message{
+			ID: guildID * 1000, ChannelID: guildID * 10,
+		}), "set message")
+		expectNoError(t, s.MemberSet(guildID, &discord.Member{
+			User: discord.User{ID: 100},
+		}), "set member")
+		expectNoError(t, s.PresenceSet(guildID, &discord.Presence{
+			User: discord.User{ID: 100},
+		}), "set presence")
+		expectNoError(t, s.VoiceStateSet(guildID, &discord.VoiceState{
+			UserID: 100,
+		}), "set voice state")
+	}
+
+	expectNoError(t, s.GuildRemove(1), "remove guild")
+
+	// Everything in the guild should be gone.
+	_, err := s.Channel(10)
+	expectNotFound(t, err, "channel of a removed guild")
+
+	_, err = s.Channels(1)
+	expectNotFound(t, err, "channels of a removed guild")
+
+	_, err = s.Message(10, 1000)
+	expectNotFound(t, err, "message in a removed guild")
+
+	_, err = s.Member(1, 100)
+	expectNotFound(t, err, "member of a removed guild")
+
+	_, err = s.Members(1)
+	expectNotFound(t, err, "members of a removed guild")
+
+	_, err = s.Presence(1, 100)
+	expectNotFound(t, err, "presence in a removed guild")
+
+	_, err = s.Presences(1)
+	expectNotFound(t, err, "presences in a removed guild")
+
+	_, err = s.VoiceState(1, 100)
+	expectNotFound(t, err, "voice state in a removed guild")
+
+	_, err = s.VoiceStates(1)
+	expectNotFound(t, err, "voice states in a removed guild")
+
+	// The other guild shouldn't be touched.
+	_, err = s.Channel(20)
+	expectNoError(t, err, "get channel of another guild")
+
+	_, err = s.Message(20, 2000)
+	expectNoError(t, err, "get message in another guild")
+
+	_, err = s.Member(2, 100)
+	expectNoError(t, err, "get member of another guild")
+
+	_, err = s.Presence(2, 100)
+	expectNoError(t, err, "get presence in another guild")
+
+	_, err = s.VoiceState(2, 100)
+	expectNoError(t, err, "get voice state in another guild")
+}
+
+func testEmojis(t *testing.T, s state.Store) {
+	expectNotFound(t, s.EmojiSet(1, []discord.Emoji{{ID: 10}}),
+		"setting emojis of a missing guild")
+
+	_, err := s.Emojis(1)
+	expectNotFound(t, err, "emojis of a missing guild")
+
+	expectNoError(t, s.GuildSet(&discord.Guild{
+		ID:     1,
+		Emojis: []discord.Emoji{{ID: 10, Name: "a"}},
+	}), "set guild")
+
+	expectNoError(t, s.EmojiSet(1, []discord.Emoji{
+		{ID: 10, Name: "b"},
+		{ID: 20, Name: "c"},
+	}), "set emojis")
+
+	e, err := s.Emoji(1, 10)
+	expectNoError(t, err, "get emoji")
+
+	if e.Name != "b" {
+		t.Fatalf("Emoji not updated: %#v", e)
+	}
+
+	_, err = s.Emoji(1, 30)
+	expectNotFound(t, err, "missing emoji")
+
+	es, err := s.Emojis(1)
+	expectNoError(t, err, "get emojis")
+
+	var ids []discord.Snowflake
+	for _, e := range es {
+		ids = append(ids, e.ID)
+	}
+
+	expectIDs(t, ids, []discord.Snowflake{10, 20}, "emojis")
+}
+
+func testRoles(t *testing.T, s state.Store) {
+	expectNotFound(t, s.RoleSet(1, &discord.Role{ID: 10}),
+		"setting a role of a missing guild")
+
+	expectNoError(t, s.GuildSet(&discord.Guild{ID: 1}), "set guild")
+
+	expectNoError(t, s.RoleSet(1, &discord.Role{ID: 10, Name: "a"}), "set role")
+	expectNoError(t, s.RoleSet(1, &discord.Role{ID: 20, Name: "b"}), "set role")
+	expectNoError(t, s.RoleSet(1, &discord.Role{ID: 10, Name: "c"}), "update role")
+
+	r, err := s.Role(1, 10)
+	expectNoError(t, err, "get role")
+
+	if r.Name != "c" {
+		t.Fatalf("Role not updated: %#v", r)
+	}
+
+	expectNoError(t, s.RoleRemove(1, 10), "remove role")
+
+	_, err = s.Role(1, 10)
+	expectNotFound(t, err, "removed role")
+
+	expectNotFound(t, s.RoleRemove(1, 10), "removing a removed role")
+
+	rs, err := s.Roles(1)
+	expectNoError(t, err, "get roles")
+
+	if len(rs) != 1 || rs[0].ID != 20 {
+		t.Fatal("Unexpected roles:", rs)
+	}
+
+	// The guild should have the roles too.
+	g, err := s.Guild(1)
+	expectNoError(t, err, "get guild")
+
+	if len(g.Roles) != 1 || g.Roles[0].ID != 20 {
+		t.Fatal("Unexpected guild roles:", g.Roles)
+	}
+}
+
+func testMembers(t *testing.T, s state.Store) {
+	_, err := s.Member(1, 10)
+	expectNotFound(t, err, "missing member")
+
+	_, err = s.Members(1)
+	expectNotFound(t, err, "members of a missing guild")
+
+	for _, id := range []discord.Snowflake{30, 10, 40, 20} {
+		expectNoError(t, s.MemberSet(1, &discord.Member{
+			User: discord.User{ID: id},
+		}), "set member")
+	}
+
+	expectNoError(t, s.MemberSet(1, &discord.Member{
+		User: discord.User{ID: 20},
+		Nick: "nick",
+	}), "update member")
+
+	m, err := s.Member(1, 20)
+	expectNoError(t, err, "get member")
+
+	if m.Nick != "nick" {
+		t.Fatalf("Member not updated: %#v", m)
+	}
+
+	expectNoError(t, s.MemberRemove(1, 10), "remove member")
+	expectNotFound(t, s.MemberRemove(1, 10), "removing a removed member")
+
+	ms, err := s.Members(1)
+	expectNoError(t, err, "get members")
+
+	var ids []discord.Snowflake
+	for _, m := range ms {
+		ids = append(ids, m.User.ID)
+	}
+
+	expectOrderedIDs(t, ids, []discord.Snowflake{20, 30, 40}, "members")
+}
+
+func testMessages(t *testing.T, s state.Store) {
+	_, err := s.Message(1, 10)
+	expectNotFound(t, err, "missing message")
+
+	_, err = s.Messages(1)
+	expectNotFound(t, err, "messages of a missing channel")
+
+	var max = s.MaxMessages()
+	if max < 1 {
+		t.Fatal("Unexpected MaxMessages:", max)
+	}
+
+	// One more than the maximum, so the first one is dropped.
+	for i := 1; i <= max+1; i++ {
+		expectNoError(t, s.MessageSet(&discord.Message{
+			ID:        discord.Snowflake(i),
+			ChannelID: 1,
+			Author:    discord.User{ID: 100},
+			Content:   "content",
+		}), "set message")
+	}
+
+	ms, err := s.Messages(1)
+	expectNoError(t, err, "get messages")
+
+	if len(ms) != max || ms[0].ID != discord.Snowflake(max+1) || ms[max-1].ID != 2 {
+		t.Fatal("Unexpected messages, expected latest first:", ms)
+	}
+
+	_, err = s.Message(1, 1)
+	expectNotFound(t, err, "dropped message")
+
+	// Partial updates should keep the other fields.
+	expectNoError(t, s.MessageSet(&discord.Message{
+		ID:        2,
+		ChannelID: 1,
+		Content:   "edited",
+	}), "update message")
+
+	m, err := s.Message(1, 2)
+	expectNoError(t, err, "get message")
+
+	if m.Content != "edited" || m.Author.ID != 100 {
+		t.Fatalf("Unexpected updated message: %#v", m)
+	}
+
+	expectNoError(t, s.MessageRemove(1, 2), "remove message")
+	expectNotFound(t, s.MessageRemove(1, 2), "removing a removed message")
+}
+
+func testPresences(t *testing.T, s state.Store) {
+	_, err := s.Presence(1, 10)
+	expectNotFound(t, err, "missing presence")
+
+	_, err = s.Presences(1)
+	expectNotFound(t, err, "presences of a missing guild")
+
+	for _, id := range []discord.Snowflake{30, 20, 10} {
+		expectNoError(t, s.PresenceSet(1, &discord.Presence{
+			User: discord.User{ID: id},
+		}), "set presence")
+	}
+
+	expectNoError(t, s.PresenceSet(1, &discord.Presence{
+		User:   discord.User{ID: 10},
+		Status: discord.IdleStatus,
+	}), "update presence")
+
+	p, err := s.Presence(1, 10)
+	expectNoError(t, err, "get presence")
+
+	if p.Status != discord.IdleStatus {
+		t.Fatalf("Presence not updated: %#v", p)
+	}
+
+	expectNoError(t, s.PresenceRemove(1, 20), "remove presence")
+	expectNotFound(t, s.PresenceRemove(1, 20), "removing a removed presence")
+
+	ps, err := s.Presences(1)
+	expectNoError(t, err, "get presences")
+
+	var ids []discord.Snowflake
+	for _, p := range ps {
+		ids = append(ids, p.User.ID)
+	}
+
+	expectOrderedIDs(t, ids, []discord.Snowflake{10, 30}, "presences")
+}
+
+func testVoiceStates(t *testing.T, s state.Store) {
+	_, err := s.VoiceState(1, 10)
+	expectNotFound(t, err, "missing voice state")
+
+	_, err = s.VoiceStates(1)
+	expectNotFound(t, err, "voice states of a missing guild")
+
+	for _, id := range []discord.Snowflake{30, 20, 10} {
+		expectNoError(t, s.VoiceStateSet(1, &discord.VoiceState{
+			GuildID: 1, ChannelID: 100, UserID: id,
+		}), "set voice state")
+	}
+
+	expectNoError(t, s.VoiceStateSet(1, &discord.VoiceState{
+		GuildID: 1, ChannelID: 200, UserID: 10,
+	}), "update voice state")
+
+	vs, err := s.VoiceState(1, 10)
+	expectNoError(t, err, "get voice state")
+
+	if vs.ChannelID != 200 {
+		t.Fatalf("Voice state not updated: %#v", vs)
+	}
+
+	expectNoError(t, s.VoiceStateRemove(1, 20), "remove voice state")
+	expectNotFound(t, s.VoiceStateRemove(1, 20), "removing a removed voice state")
+
+	states, err := s.VoiceStates(1)
+	expectNoError(t, err, "get voice states")
+
+	var ids []discord.Snowflake
+	for _, vs := range states {
+		ids = append(ids, vs.UserID)
+	}
+
+	expectOrderedIDs(t, ids, []discord.Snowflake{10, 30}, "voice states")
+}
+
+func testReset(t *testing.T, s state.Store) {
+	expectNoError(t, s.MyselfSet(&discord.User{ID: 1}), "set Me")
+	expectNoError(t, s.GuildSet(&discord.Guild{ID: 1}), "set guild")
+	expectNoError(t, s.ChannelSet(&discord.Channel{
+		ID: 10, GuildID: 1,
+	}), "set channel")
+	expectNoError(t, s.MemberSet(1, &discord.Member{
+		User: discord.User{ID: 100},
+	}), "set member")
+	expectNoError(t, s.MessageSet(&discord.Message{
+		ID: 1000, ChannelID: 10,
+	}), "set message")
+
+	expectNoError(t, s.Reset(), "reset")
+
+	_, err := s.Me()
+	expectNotFound(t, err, "Me after Reset")
+
+	_, err = s.Guild(1)
+	expectNotFound(t, err, "guild after Reset")
+
+	_, err = s.Channel(10)
+	expectNotFound(t, err, "channel after Reset")
+
+	_, err = s.Member(1, 100)
+	expectNotFound(t, err, "member after Reset")
+
+	_, err = s.Message(10, 1000)
+	expectNotFound(t, err, "message after Reset")
+}
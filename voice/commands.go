@@ -0,0 +1,98 @@
+package voice
+
+import (
+	"time"
+
+	"github.com/diamondburned/arikawa/discord"
+)
+
+// https://discordapp.com/developers/docs/topics/voice-connections#establishing-a-voice-websocket-connection-example-voice-identify-payload
+type IdentifyData struct {
+	GuildID   discord.Snowflake `json:"server_id"`
+	UserID    discord.Snowflake `json:"user_id"`
+	SessionID string            `json:"session_id"`
+	Token     string            `json:"token"`
+}
+
+// Identify sends an Identify OP with the state the Gateway was created with.
+func (g *Gateway) Identify() error {
+	return g.Send(IdentifyOP, IdentifyData{
+		GuildID:   g.state.GuildID,
+		UserID:    g.state.UserID,
+		SessionID: g.state.SessionID,
+		Token:     g.state.Token,
+	})
+}
+
+// https://discordapp.com/developers/docs/topics/voice-connections#establishing-a-voice-udp-connection-example-select-protocol-payload
+type SelectProtocol struct {
+	Protocol string             `json:"protocol"`
+	Data     SelectProtocolData `json:"data"`
+}
+
+type SelectProtocolData struct {
+	Address string `json:"address"`
+	Port    uint16 `json:"port"`
+	Mode    string `json:"mode"`
+}
+
+// SelectProtocol tells the voice server our external IP and port, as found
+// from IP discovery, as well as the encryption mode.
+func (g *Gateway) SelectProtocol(data SelectProtocolData) error {
+	return g.Send(SelectProtocolOP, SelectProtocol{
+		Protocol: "udp",
+		Data:     data,
+	})
+}
+
+// https://discordapp.com/developers/docs/topics/voice-connections#speaking
+type SpeakingData struct {
+	Speaking SpeakingFlag      `json:"speaking"`
+	Delay    int               `json:"delay"`
+	SSRC     uint32            `json:"ssrc"`
+	UserID   discord.Snowflake `json:"user_id,omitempty"` // recv only
+}
+
+type SpeakingFlag uint8
+
+const NotSpeaking SpeakingFlag = 0
+
+const (
+	Microphone SpeakingFlag = 1 << iota
+	Soundshare
+	Priority
+)
+
+// Speaking sends a Speaking OP. This has to be sent at least once before
+// sending any voice data.
+func (g *Gateway) Speaking(flag SpeakingFlag) error {
+	return g.Send(SpeakingOP, SpeakingData{
+		Speaking: flag,
+		SSRC:     g.ready.SSRC,
+	})
+}
+
+// https://discordapp.com/developers/docs/topics/voice-connections#resuming-voice-connection-example-resume-connection-payload
+type ResumeData struct {
+	GuildID   discord.Snowflake `json:"server_id"`
+	SessionID string            `json:"session_id"`
+	Token     string            `json:"token"`
+}
+
+// Resume sends a Resume OP. Reconnect should be used to actually resume a dead
+// connection.
+func (g *Gateway) Resume() error {
+	return g.Send(ResumeOP, ResumeData{
+		GuildID:   g.state.GuildID,
+		SessionID: g.state.SessionID,
+		Token:     g.state.Token,
+	})
+}
+
+// HeartbeatData is a nonce, which the voice server echoes back in the
+// acknowledgement.
+type HeartbeatData uint64
+
+func (g *Gateway) Heartbeat() error {
+	return g.Send(HeartbeatOP, HeartbeatData(time.Now().UnixNano()))
+}
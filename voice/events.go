@@ -0,0 +1,51 @@
+package voice
+
+import "github.com/diamondburned/arikawa/discord"
+
+// Event is any event struct. They have an "Event" suffixed to them.
+type Event = interface{}
+
+// EventCreator maps received OP codes to their event structs. Ready,
+// SessionDescription and Hello are consumed by the handshake, but they are
+// kept here in case the server sends them again.
+var EventCreator = map[OPCode]func() Event{
+	ReadyOP:              func() Event { return new(ReadyEvent) },
+	SessionDescriptionOP: func() Event { return new(SessionDescriptionEvent) },
+	SpeakingOP:           func() Event { return new(SpeakingEvent) },
+	HelloOP:              func() Event { return new(HelloEvent) },
+	ResumedOP:            func() Event { return new(ResumedEvent) },
+	ClientDisconnectOP:   func() Event { return new(ClientDisconnectEvent) },
+}
+
+// https://discordapp.com/developers/docs/topics/voice-connections#establishing-a-voice-websocket-connection-example-voice-ready-payload
+type ReadyEvent struct {
+	SSRC  uint32   `json:"ssrc"`
+	IP    string   `json:"ip"`
+	Port  int      `json:"port"`
+	Modes []string `json:"modes"`
+}
+
+// https://discordapp.com/developers/docs/topics/voice-connections#establishing-a-voice-udp-connection-example-session-description-payload
+type SessionDescriptionEvent struct {
+	Mode      string   `json:"mode"`
+	SecretKey [32]byte `json:"secret_key"`
+}
+
+// SpeakingEvent is sent when a user starts or stops speaking. It is also sent
+// by the client to indicate that it's about to send voice data.
+type SpeakingEvent SpeakingData
+
+// https://discordapp.com/developers/docs/topics/voice-connections#heartbeating-example-hello-payload-since-v3
+type HelloEvent struct {
+	// The voice gateway sends this as a float, unlike the main gateway.
+	HeartbeatInterval float64 `json:"heartbeat_interval"`
+}
+
+// https://discordapp.com/developers/docs/topics/voice-connections#resuming-voice-connection-example-resumed-payload
+type ResumedEvent struct{}
+
+// ClientDisconnectEvent is undocumented, but it's sent when a user leaves the
+// voice channel.
+type ClientDisconnectEvent struct {
+	UserID discord.Snowflake `json:"user_id"`
+}
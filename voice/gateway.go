@@ -0,0 +1,454 @@
+package voice
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/diamondburned/arikawa/discord"
+	"github.com/diamondburned/arikawa/gateway"
+	"github.com/diamondburned/arikawa/internal/json"
+	"github.com/diamondburned/arikawa/internal/wsutil"
+	"github.com/pkg/errors"
+)
+
+const (
+	// Version is the voice gateway version. Version 4 is the one that sends
+	// Hello before Ready and expects Identify after Hello.
+	Version = "4"
+
+	// EncryptionMode is the only mode this package supports.
+	EncryptionMode = "xsalsa20_poly1305"
+)
+
+var (
+	// WSTimeout is the timeout for connecting and writing to the voice
+	// Websocket, before Gateway cancels and fails.
+	WSTimeout = wsutil.DefaultTimeout
+	// WSBuffer is the size of the Event channel.
+	WSBuffer = 10
+	// WSError is the default error handler.
+	WSError = func(err error) { log.Println("Voice gateway error:", err) }
+
+	WSDebug = func(v ...interface{}) {}
+)
+
+var (
+	ErrMissingForIdentify = errors.New(
+		"missing session ID, token or endpoint for identifying")
+	ErrNoSessionDescription = errors.New(
+		"encryption mode not supported by the voice server")
+	ErrGatewayClosed = errors.New("voice gateway closed")
+)
+
+// State contains the data received from the VoiceStateUpdate and
+// VoiceServerUpdate events that's needed to connect to the voice gateway.
+type State struct {
+	UserID    discord.Snowflake
+	GuildID   discord.Snowflake
+	ChannelID discord.Snowflake
+
+	SessionID string
+	Token     string
+	Endpoint  string
+}
+
+// URL returns the Websocket URL of the voice gateway. Discord gives the
+// endpoint without a scheme, and with a bogus :80 port. The scheme is kept if
+// the endpoint has one.
+func (s State) URL() string {
+	endpoint := s.Endpoint
+
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "wss://" + strings.TrimSuffix(endpoint, ":80")
+	}
+
+	return endpoint + "/?v=" + Version
+}
+
+// Gateway is a connection to a voice server's Websocket. It handles the
+// handshake and heartbeating, but not the UDP connection; that's done by
+// Session.
+type Gateway struct {
+	WS *wsutil.Websocket
+	json.Driver
+
+	// Timeout for connecting and writing to the Websocket, uses default
+	// WSTimeout (global).
+	WSTimeout time.Duration
+
+	// Events received after the handshake, such as Speaking and
+	// ClientDisconnect, are sent here.
+	Events chan Event
+
+	Pacemaker *gateway.Pacemaker
+
+	ErrorLog func(err error) // default to log.Println
+
+	state State
+	ready ReadyEvent
+
+	// Filled by methods, internal use. The event loop reconnects from its
+	// own goroutine, so these and Pacemaker are guarded by mut.
+	mut       sync.Mutex
+	paceDeath chan error
+	waitGroup *sync.WaitGroup
+	done      chan struct{} // closed to stop sending to Events
+	closed    bool
+}
+
+// NewGateway creates a new undialed voice Gateway with the given state.
+func NewGateway(s State) (*Gateway, error) {
+	if s.SessionID == "" || s.Token == "" || s.Endpoint == "" {
+		return nil, ErrMissingForIdentify
+	}
+
+	driver := json.Default{}
+
+	ctx, cancel := context.WithTimeout(context.Background(), WSTimeout)
+	defer cancel()
+
+	ws, err := wsutil.NewCustom(ctx, wsutil.NewConn(driver), s.URL())
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to create voice Websocket")
+	}
+
+	return &Gateway{
+		WS:        ws,
+		Driver:    driver,
+		WSTimeout: WSTimeout,
+		Events:    make(chan Event, WSBuffer),
+		ErrorLog:  WSError,
+		state:     s,
+	}, nil
+}
+
+// Ready returns the Ready event received during the handshake.
+func (g *Gateway) Ready() ReadyEvent {
+	return g.ready
+}
+
+// Open dials the voice Websocket and identifies. It returns once Ready is
+// received, which contains the address for the UDP connection.
+func (g *Gateway) Open(ctx context.Context) error {
+	g.mut.Lock()
+	g.closed = false
+	g.mut.Unlock()
+
+	if err := g.WS.Dial(ctx); err != nil {
+		return errors.Wrap(err, "Failed to dial voice gateway")
+	}
+
+	if err := g.start(ctx, false); err != nil {
+		g.Close()
+		return err
+	}
+
+	return nil
+}
+
+// Reconnect closes the Websocket if it's still alive, then dials it again and
+// resumes the session.
+func (g *Gateway) Reconnect(ctx context.Context) error {
+	g.mut.Lock()
+	g.closed = false
+	g.mut.Unlock()
+
+	return g.reconnect(ctx)
+}
+
+// reconnect is Reconnect without reopening a closed Gateway, which is used
+// when the event loop fails.
+func (g *Gateway) reconnect(ctx context.Context) error {
+	WSDebug("Reconnecting voice...")
+
+	g.mut.Lock()
+	alive := g.paceDeath != nil
+	g.mut.Unlock()
+
+	if alive {
+		g.stop()
+	}
+
+	if err := g.WS.Dial(ctx); err != nil {
+		return errors.Wrap(err, "Failed to redial voice gateway")
+	}
+
+	if err := g.start(ctx, true); err != nil {
+		g.stop()
+		return err
+	}
+
+	return nil
+}
+
+func (g *Gateway) start(ctx context.Context, resume bool) error {
+	ch := g.WS.Listen()
+
+	// Wait for an OP 8 Hello
+	var ev wsutil.Event
+	select {
+	case ev = <-ch:
+	case <-ctx.Done():
+		return errors.Wrap(ctx.Err(), "Failed to wait for Hello")
+	}
+
+	var hello HelloEvent
+	if _, err := AssertEvent(g, ev, HelloOP, &hello); err != nil {
+		return errors.Wrap(err, "Error at Hello")
+	}
+
+	wg := new(sync.WaitGroup)
+
+	g.mut.Lock()
+
+	// Close could've been called while reconnecting.
+	if g.closed {
+		g.mut.Unlock()
+		return ErrGatewayClosed
+	}
+
+	g.waitGroup = wg
+	g.done = make(chan struct{})
+	g.Pacemaker = &gateway.Pacemaker{
+		Heartrate: time.Duration(hello.HeartbeatInterval * float64(time.Millisecond)),
+		Pace:      g.Heartbeat,
+	}
+	g.paceDeath = g.Pacemaker.StartAsync(wg)
+
+	g.mut.Unlock()
+
+	if resume {
+		if err := g.Resume(); err != nil {
+			return errors.Wrap(err, "Failed to resume")
+		}
+
+		if err := g.expect(ctx, ch, ResumedOP, new(ResumedEvent)); err != nil {
+			return errors.Wrap(err, "Error at Resumed")
+		}
+
+		// A resumed session already has its session description, so the
+		// event loop can start right away.
+		wg.Add(1)
+		go g.handleWS(wg)
+
+	} else {
+		if err := g.Identify(); err != nil {
+			return errors.Wrap(err, "Failed to identify")
+		}
+
+		if err := g.expect(ctx, ch, ReadyOP, &g.ready); err != nil {
+			return errors.Wrap(err, "Error at Ready")
+		}
+	}
+
+	return nil
+}
+
+// SessionDescription sends a Select Protocol with the given external address,
+// then waits for the Session Description that contains the secret key. This
+// finishes the handshake that Open starts.
+func (g *Gateway) SessionDescription(
+	ctx context.Context, data SelectProtocolData) (*SessionDescriptionEvent, error) {
+
+	if data.Mode == "" {
+		data.Mode = EncryptionMode
+	}
+
+	var supported bool
+	for _, mode := range g.ready.Modes {
+		if mode == data.Mode {
+			supported = true
+			break
+		}
+	}
+
+	if !supported {
+		return nil, ErrNoSessionDescription
+	}
+
+	if err := g.SelectProtocol(data); err != nil {
+		return nil, errors.Wrap(err, "Failed to select protocol")
+	}
+
+	var desc SessionDescriptionEvent
+
+	err := g.expect(ctx, g.WS.Listen(), SessionDescriptionOP, &desc)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error at Session Description")
+	}
+
+	g.mut.Lock()
+	wg := g.waitGroup
+	g.mut.Unlock()
+
+	if wg == nil {
+		return nil, ErrGatewayClosed
+	}
+
+	// The handshake is done, so everything else goes to the event loop.
+	wg.Add(1)
+	go g.handleWS(wg)
+
+	return &desc, nil
+}
+
+func (g *Gateway) expect(
+	ctx context.Context, ch <-chan wsutil.Event, code OPCode, v interface{}) error {
+
+	for {
+		select {
+		case ev := <-ch:
+			op, err := DecodeOP(g, ev)
+			if err != nil {
+				return err
+			}
+
+			// Heartbeat acks could arrive before what we're waiting for.
+			if op.Code != code {
+				if err := HandleOP(g, op); err != nil {
+					return err
+				}
+				continue
+			}
+
+			return g.Unmarshal(op.Data, v)
+
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// handleWS handles the Websocket until the pacemaker dies or the connection
+// errors out, in which case it tries to resume.
+func (g *Gateway) handleWS(wg *sync.WaitGroup) {
+	err := g.eventLoop()
+	wg.Done()
+
+	if err != nil {
+		g.ErrorLog(err)
+
+		ctx, cancel := context.WithTimeout(context.Background(), g.WSTimeout)
+		defer cancel()
+
+		if err := g.reconnect(ctx); err != nil && err != ErrGatewayClosed {
+			g.ErrorLog(errors.Wrap(err, "Failed to reconnect voice"))
+		}
+	}
+}
+
+func (g *Gateway) eventLoop() error {
+	ch := g.WS.Listen()
+
+	g.mut.Lock()
+	paceDeath := g.paceDeath
+	g.mut.Unlock()
+
+	for {
+		select {
+		case err := <-paceDeath:
+			g.mut.Lock()
+			g.paceDeath = nil
+			g.mut.Unlock()
+
+			if err == nil {
+				WSDebug("Voice pacemaker stopped without errors.")
+				return nil
+			}
+
+			return errors.Wrap(err, "Voice pacemaker died, reconnecting")
+
+		case ev, ok := <-ch:
+			if !ok {
+				// Closed. We'll know from paceDeath if this is intended.
+				ch = nil
+				continue
+			}
+
+			if ev.Error != nil {
+				return errors.Wrap(ev.Error, "Voice Websocket error")
+			}
+
+			if err := HandleEvent(g, ev.Data); err != nil {
+				g.ErrorLog(errors.Wrap(err, "Voice WS handler error"))
+			}
+		}
+	}
+}
+
+// Close closes the underlying Websocket connection and stops the pacemaker.
+// The event loop doesn't reconnect after this.
+func (g *Gateway) Close() error {
+	g.mut.Lock()
+	g.closed = true
+	g.mut.Unlock()
+
+	return g.stop()
+}
+
+func (g *Gateway) stop() error {
+	g.mut.Lock()
+
+	if g.Pacemaker != nil {
+		g.Pacemaker.Stop()
+	}
+
+	// Unblock the event loop if it's sending to a full Events.
+	if g.done != nil {
+		select {
+		case <-g.done:
+		default:
+			close(g.done)
+		}
+	}
+
+	wg := g.waitGroup
+	g.waitGroup = nil
+
+	g.mut.Unlock()
+
+	// The pacemaker stopping also exits the event loop.
+	if wg != nil {
+		wg.Wait()
+	}
+
+	return g.WS.Close(nil)
+}
+
+// eventsDone returns the channel that's closed once events shouldn't be sent
+// anymore.
+func (g *Gateway) eventsDone() <-chan struct{} {
+	g.mut.Lock()
+	defer g.mut.Unlock()
+
+	return g.done
+}
+
+// Send sends a payload to the voice Websocket.
+func (g *Gateway) Send(code OPCode, v interface{}) error {
+	var op = OP{
+		Code: code,
+	}
+
+	if v != nil {
+		b, err := g.Driver.Marshal(v)
+		if err != nil {
+			return errors.Wrap(err, "Failed to encode v")
+		}
+
+		op.Data = b
+	}
+
+	b, err := g.Driver.Marshal(op)
+	if err != nil {
+		return errors.Wrap(err, "Failed to encode payload")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), g.WSTimeout)
+	defer cancel()
+
+	return g.WS.Send(ctx, b)
+}
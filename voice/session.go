@@ -0,0 +1,310 @@
+// Package voice handles the Discord voice gateway and the UDP connection to the
+// voice server. It takes the state from the main Gateway's VoiceStateUpdate
+// and VoiceServerUpdate events, then connects to the voice server with it.
+//
+// A Session is an io.Writer that accepts Opus frames, which are encrypted with
+// xsalsa20_poly1305 and sent over UDP. This package does not encode Opus.
+package voice
+
+import (
+	"context"
+	"sync"
+
+	"github.com/diamondburned/arikawa/discord"
+	"github.com/diamondburned/arikawa/gateway"
+	"github.com/diamondburned/arikawa/handler"
+	"github.com/diamondburned/arikawa/session"
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrMissingState = errors.New(
+		"missing voice state or voice server update for connecting")
+	ErrNotConnected = errors.New("voice session not connected")
+)
+
+// Session is a single voice connection. Each guild could only have one.
+type Session struct {
+	// Handler receives events from the voice gateway, such as Speaking and
+	// ClientDisconnect.
+	*handler.Handler
+
+	// ErrorLog logs errors from the voice gateway.
+	ErrorLog func(err error) // default to WSError
+
+	mut     sync.Mutex
+	state   State
+	gateway *Gateway
+	udp     *UDPConnection
+
+	speaking bool
+	hstop    chan struct{}
+}
+
+// NewSession creates a new unconnected voice session for the user with the
+// given ID.
+func NewSession(userID discord.Snowflake) *Session {
+	return &Session{
+		Handler:  handler.New(),
+		ErrorLog: WSError,
+		state: State{
+			UserID: userID,
+		},
+	}
+}
+
+// UpdateState updates the session with a VoiceStateUpdate event. Events that
+// don't belong to this session's user are ignored.
+func (s *Session) UpdateState(ev *gateway.VoiceStateUpdateEvent) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	if ev.UserID != s.state.UserID {
+		return
+	}
+
+	s.state.GuildID = ev.GuildID
+	s.state.ChannelID = ev.ChannelID
+	s.state.SessionID = ev.SessionID
+}
+
+// UpdateServer updates the session with a VoiceServerUpdate event. Events from
+// other guilds are ignored once the guild is known.
+func (s *Session) UpdateServer(ev *gateway.VoiceServerUpdateEvent) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	if s.state.GuildID.Valid() && s.state.GuildID != ev.GuildID {
+		return
+	}
+
+	s.state.GuildID = ev.GuildID
+	s.state.Token = ev.Token
+	s.state.Endpoint = ev.Endpoint
+}
+
+// State returns a copy of the session's current voice state.
+func (s *Session) State() State {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	return s.state
+}
+
+// Open connects to the voice gateway and the voice server. Both UpdateState
+// and UpdateServer must be called before this.
+func (s *Session) Open(ctx context.Context) error {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	if s.state.SessionID == "" || s.state.Endpoint == "" {
+		return ErrMissingState
+	}
+
+	// Close the old connections, if any.
+	s.close()
+
+	g, err := NewGateway(s.state)
+	if err != nil {
+		return err
+	}
+	g.ErrorLog = func(err error) { s.ErrorLog(err) }
+
+	if err := g.Open(ctx); err != nil {
+		return errors.Wrap(err, "Failed to open voice gateway")
+	}
+
+	ready := g.Ready()
+
+	udp, err := DialUDP(ctx, joinHostPort(ready.IP, ready.Port), ready.SSRC)
+	if err != nil {
+		g.Close()
+		return errors.Wrap(err, "Failed to open voice UDP connection")
+	}
+
+	desc, err := g.SessionDescription(ctx, SelectProtocolData{
+		Address: udp.Address,
+		Port:    udp.Port,
+		Mode:    EncryptionMode,
+	})
+	if err != nil {
+		udp.Close()
+		g.Close()
+		return errors.Wrap(err, "Failed to select protocol")
+	}
+
+	udp.UseSecret(desc.SecretKey)
+
+	s.gateway = g
+	s.udp = udp
+
+	stop := make(chan struct{})
+	s.hstop = stop
+	go s.startHandler(g.Events, stop)
+
+	return nil
+}
+
+func (s *Session) startHandler(events <-chan Event, stop <-chan struct{}) {
+	for {
+		select {
+		case <-stop:
+			return
+		case ev := <-events:
+			s.Handler.Call(ev)
+		}
+	}
+}
+
+// Speaking sends a Speaking OP to the voice gateway. Write calls this
+// automatically with Microphone if it hasn't been called.
+func (s *Session) Speaking(flag SpeakingFlag) error {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	return s.setSpeaking(flag)
+}
+
+func (s *Session) setSpeaking(flag SpeakingFlag) error {
+	if s.gateway == nil {
+		return ErrNotConnected
+	}
+
+	if err := s.gateway.Speaking(flag); err != nil {
+		return err
+	}
+
+	s.speaking = flag != NotSpeaking
+	return nil
+}
+
+// Write sends a single Opus frame. The call blocks for the duration of a frame
+// to keep the stream paced.
+func (s *Session) Write(frame []byte) (int, error) {
+	s.mut.Lock()
+
+	if s.udp == nil {
+		s.mut.Unlock()
+		return 0, ErrNotConnected
+	}
+
+	if !s.speaking {
+		if err := s.setSpeaking(Microphone); err != nil {
+			s.mut.Unlock()
+			return 0, errors.Wrap(err, "Failed to send speaking")
+		}
+	}
+
+	udp := s.udp
+	s.mut.Unlock()
+
+	return udp.Write(frame)
+}
+
+// StopSpeaking sends five frames of silence, then tells Discord that the user
+// has stopped speaking.
+func (s *Session) StopSpeaking() error {
+	for i := 0; i < 5; i++ {
+		if _, err := s.Write(SilenceFrame); err != nil {
+			return errors.Wrap(err, "Failed to send silence")
+		}
+	}
+
+	return s.Speaking(NotSpeaking)
+}
+
+// Close closes both the voice gateway and the UDP connection. It does not
+// leave the voice channel; that's done on the main Gateway.
+func (s *Session) Close() error {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	return s.close()
+}
+
+func (s *Session) close() error {
+	var err error
+
+	// Close the gateway first, so its event loop isn't stuck sending to the
+	// handler that's being stopped.
+	if s.gateway != nil {
+		err = s.gateway.Close()
+		s.gateway = nil
+	}
+
+	if s.hstop != nil {
+		close(s.hstop)
+		s.hstop = nil
+	}
+
+	if s.udp != nil {
+		if uerr := s.udp.Close(); uerr != nil {
+			err = uerr
+		}
+		s.udp = nil
+	}
+
+	s.speaking = false
+	return err
+}
+
+// JoinChannel joins the voice channel using the main Gateway, waits for the
+// VoiceStateUpdate and VoiceServerUpdate events, then opens a voice Session
+// with them.
+func JoinChannel(ctx context.Context, ses *session.Session,
+	guildID, channelID discord.Snowflake, mute, deaf bool) (*Session, error) {
+
+	me, err := ses.Me()
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to get self")
+	}
+
+	v := NewSession(me.ID)
+
+	var gotState, gotServer = make(chan struct{}), make(chan struct{})
+	var stateOnce, serverOnce sync.Once
+
+	rmState := ses.AddHandler(func(ev *gateway.VoiceStateUpdateEvent) {
+		if ev.GuildID != guildID || ev.UserID != me.ID {
+			return
+		}
+
+		v.UpdateState(ev)
+		stateOnce.Do(func() { close(gotState) })
+	})
+	defer rmState()
+
+	rmServer := ses.AddHandler(func(ev *gateway.VoiceServerUpdateEvent) {
+		if ev.GuildID != guildID {
+			return
+		}
+
+		v.UpdateServer(ev)
+		serverOnce.Do(func() { close(gotServer) })
+	})
+	defer rmServer()
+
+	err = ses.Gateway.UpdateVoiceState(gateway.UpdateVoiceStateData{
+		GuildID:   guildID,
+		ChannelID: channelID,
+		SelfMute:  mute,
+		SelfDeaf:  deaf,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to update voice state")
+	}
+
+	for _, ch := range []chan struct{}{gotState, gotServer} {
+		select {
+		case <-ch:
+		case <-ctx.Done():
+			return nil, errors.Wrap(ctx.Err(), "Failed to wait for voice events")
+		}
+	}
+
+	if err := v.Open(ctx); err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
@@ -0,0 +1,425 @@
+// +build unit
+
+package voice
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/diamondburned/arikawa/gateway"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/nacl/secretbox"
+	"nhooyr.io/websocket"
+)
+
+const testSSRC = 0x1234
+
+var testKey = [32]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+
+// testVoiceServer mocks both the voice Websocket and the UDP server.
+type testVoiceServer struct {
+	t *testing.T
+
+	ws  *httptest.Server
+	udp net.PacketConn
+
+	speaking chan SpeakingData
+	packets  chan []byte
+
+	// flood is the number of Speaking events sent after the handshake.
+	flood int
+}
+
+func newTestVoiceServer(t *testing.T) *testVoiceServer {
+	udp, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("Failed to listen UDP:", err)
+	}
+
+	s := &testVoiceServer{
+		t:        t,
+		udp:      udp,
+		speaking: make(chan SpeakingData, 1),
+		packets:  make(chan []byte, 10),
+	}
+
+	s.ws = httptest.NewServer(http.HandlerFunc(s.serveWS))
+	go s.serveUDP()
+
+	return s
+}
+
+func (s *testVoiceServer) Endpoint() string {
+	return strings.Replace(s.ws.URL, "http://", "ws://", 1)
+}
+
+func (s *testVoiceServer) Close() {
+	s.ws.Close()
+	s.udp.Close()
+}
+
+func (s *testVoiceServer) write(ctx context.Context, c *websocket.Conn, code OPCode, v interface{}) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		s.t.Error("Failed to marshal data:", err)
+		return
+	}
+
+	b, err = json.Marshal(OP{Code: code, Data: b})
+	if err != nil {
+		s.t.Error("Failed to marshal OP:", err)
+		return
+	}
+
+	if err := c.Write(ctx, websocket.MessageText, b); err != nil {
+		s.t.Error("Failed to write:", err)
+	}
+}
+
+func (s *testVoiceServer) read(ctx context.Context, c *websocket.Conn) (*OP, error) {
+	_, b, err := c.Read(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var op *OP
+	return op, json.Unmarshal(b, &op)
+}
+
+func (s *testVoiceServer) expect(
+	ctx context.Context, c *websocket.Conn, code OPCode, v interface{}) bool {
+
+	op, err := s.read(ctx, c)
+	if err != nil {
+		s.t.Error("Failed to read:", err)
+		return false
+	}
+
+	if op.Code != code {
+		s.t.Errorf("Unexpected OP %d, expected %d", op.Code, code)
+		return false
+	}
+
+	if err := json.Unmarshal(op.Data, v); err != nil {
+		s.t.Error("Failed to unmarshal data:", err)
+		return false
+	}
+
+	return true
+}
+
+func (s *testVoiceServer) serveWS(w http.ResponseWriter, r *http.Request) {
+	if v := r.URL.Query().Get("v"); v != Version {
+		s.t.Error("Unexpected version:", v)
+	}
+
+	c, err := websocket.Accept(w, r, nil)
+	if err != nil {
+		s.t.Error("Failed to accept:", err)
+		return
+	}
+	defer c.Close(websocket.StatusInternalError, "")
+
+	ctx := context.Background()
+
+	s.write(ctx, c, HelloOP, HelloEvent{HeartbeatInterval: 10000.5})
+
+	var identify IdentifyData
+	if !s.expect(ctx, c, IdentifyOP, &identify) {
+		return
+	}
+
+	if identify.SessionID != "session" || identify.Token != "token" {
+		s.t.Errorf("Unexpected Identify: %#v", identify)
+	}
+
+	s.write(ctx, c, ReadyOP, ReadyEvent{
+		SSRC:  testSSRC,
+		IP:    "127.0.0.1",
+		Port:  s.udp.LocalAddr().(*net.UDPAddr).Port,
+		Modes: []string{"plain", EncryptionMode},
+	})
+
+	var selectProtocol SelectProtocol
+	if !s.expect(ctx, c, SelectProtocolOP, &selectProtocol) {
+		return
+	}
+
+	if selectProtocol.Data.Address != "127.0.0.1" || selectProtocol.Data.Port == 0 {
+		s.t.Errorf("Unexpected Select Protocol: %#v", selectProtocol)
+	}
+
+	s.write(ctx, c, SessionDescriptionOP, SessionDescriptionEvent{
+		Mode:      EncryptionMode,
+		SecretKey: testKey,
+	})
+
+	s.write(ctx, c, ClientDisconnectOP, ClientDisconnectEvent{UserID: 2})
+
+	for i := 0; i < s.flood; i++ {
+		s.write(ctx, c, SpeakingOP, SpeakingData{Speaking: Microphone, SSRC: 2})
+	}
+
+	for {
+		op, err := s.read(ctx, c)
+		if err != nil {
+			return
+		}
+
+		if op.Code == SpeakingOP {
+			var speaking SpeakingData
+			if err := json.Unmarshal(op.Data, &speaking); err != nil {
+				s.t.Error("Failed to unmarshal Speaking:", err)
+			}
+			s.speaking <- speaking
+		}
+	}
+}
+
+func (s *testVoiceServer) serveUDP() {
+	var buf [1024]byte
+
+	n, addr, err := s.udp.ReadFrom(buf[:])
+	if err != nil {
+		return
+	}
+
+	if n != 74 || binary.BigEndian.Uint16(buf[0:2]) != 0x1 {
+		s.t.Errorf("Unexpected IP discovery request: %v", buf[:n])
+		return
+	}
+
+	if ssrc := binary.BigEndian.Uint32(buf[4:8]); ssrc != testSSRC {
+		s.t.Error("Unexpected SSRC in IP discovery:", ssrc)
+	}
+
+	var resp [74]byte
+	binary.BigEndian.PutUint16(resp[0:2], 0x2)
+	binary.BigEndian.PutUint16(resp[2:4], 70)
+	copy(resp[4:8], buf[4:8])
+	copy(resp[8:72], "127.0.0.1")
+	binary.BigEndian.PutUint16(resp[72:74], uint16(addr.(*net.UDPAddr).Port))
+
+	if _, err := s.udp.WriteTo(resp[:], addr); err != nil {
+		s.t.Error("Failed to write IP discovery response:", err)
+		return
+	}
+
+	for {
+		n, _, err := s.udp.ReadFrom(buf[:])
+		if err != nil {
+			return
+		}
+
+		var nonce [24]byte
+		copy(nonce[:], buf[:12])
+
+		b, ok := secretbox.Open(nil, buf[12:n], &nonce, &testKey)
+		if !ok {
+			s.t.Error("Failed to decrypt packet")
+			continue
+		}
+
+		// Prepend the sequence for checking.
+		s.packets <- append(append([]byte{}, buf[2:4]...), b...)
+	}
+}
+
+func TestStateURL(t *testing.T) {
+	var tests = []struct {
+		endpoint string
+		url      string
+	}{
+		{"us-east1.discord.gg:80", "wss://us-east1.discord.gg/?v=" + Version},
+		{"us-east1.discord.gg", "wss://us-east1.discord.gg/?v=" + Version},
+		{"ws://127.0.0.1:8080", "ws://127.0.0.1:8080/?v=" + Version},
+	}
+
+	for _, test := range tests {
+		s := State{Endpoint: test.endpoint}
+		if url := s.URL(); url != test.url {
+			t.Errorf("Unexpected URL for %q: %q, expected %q", test.endpoint, url, test.url)
+		}
+	}
+}
+
+func TestSession(t *testing.T) {
+	srv := newTestVoiceServer(t)
+	defer srv.Close()
+
+	s := NewSession(1)
+	s.ErrorLog = func(err error) { t.Error("Voice error:", err) }
+
+	if err := s.Open(context.Background()); err != ErrMissingState {
+		t.Fatal("Unexpected error opening without state:", err)
+	}
+
+	s.UpdateState(&gateway.VoiceStateUpdateEvent{
+		GuildID:   3,
+		ChannelID: 4,
+		UserID:    1,
+		SessionID: "session",
+	})
+	s.UpdateServer(&gateway.VoiceServerUpdateEvent{
+		GuildID:  3,
+		Token:    "token",
+		Endpoint: srv.Endpoint(),
+	})
+
+	disconnected := make(chan *ClientDisconnectEvent, 1)
+	s.AddHandler(func(ev *ClientDisconnectEvent) {
+		disconnected <- ev
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := s.Open(ctx); err != nil {
+		t.Fatal("Failed to open:", err)
+	}
+	defer s.Close()
+
+	select {
+	case ev := <-disconnected:
+		if ev.UserID != 2 {
+			t.Error("Unexpected ClientDisconnect user:", ev.UserID)
+		}
+	case <-ctx.Done():
+		t.Fatal("Timed out waiting for ClientDisconnect")
+	}
+
+	var frames = [][]byte{{0x1, 0x2, 0x3}, {0x4, 0x5}}
+
+	for _, frame := range frames {
+		if _, err := s.Write(frame); err != nil {
+			t.Fatal("Failed to write frame:", err)
+		}
+	}
+
+	select {
+	case speaking := <-srv.speaking:
+		if speaking.Speaking != Microphone || speaking.SSRC != testSSRC {
+			t.Errorf("Unexpected Speaking: %#v", speaking)
+		}
+	case <-ctx.Done():
+		t.Fatal("Timed out waiting for Speaking")
+	}
+
+	for i, frame := range frames {
+		select {
+		case packet := <-srv.packets:
+			if seq := binary.BigEndian.Uint16(packet[:2]); seq != uint16(i) {
+				t.Errorf("Unexpected sequence %d, expected %d", seq, i)
+			}
+			if !bytes.Equal(packet[2:], frame) {
+				t.Errorf("Unexpected frame %v, expected %v", packet[2:], frame)
+			}
+		case <-ctx.Done():
+			t.Fatal("Timed out waiting for packet")
+		}
+	}
+}
+
+func TestSessionCloseBusy(t *testing.T) {
+	srv := newTestVoiceServer(t)
+	srv.flood = 3 * WSBuffer
+	defer srv.Close()
+
+	s := NewSession(1)
+	s.ErrorLog = func(err error) { t.Error("Voice error:", err) }
+	s.UpdateState(&gateway.VoiceStateUpdateEvent{
+		GuildID: 3, ChannelID: 4, UserID: 1, SessionID: "session",
+	})
+	s.UpdateServer(&gateway.VoiceServerUpdateEvent{
+		GuildID: 3, Token: "token", Endpoint: srv.Endpoint(),
+	})
+
+	// A slow handler, so the events fill up the buffer.
+	s.Synchronous = true
+	release := make(chan struct{})
+	defer close(release)
+
+	received := make(chan struct{}, 1)
+	s.AddHandler(func(ev *SpeakingEvent) {
+		select {
+		case received <- struct{}{}:
+		default:
+		}
+		<-release
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := s.Open(ctx); err != nil {
+		t.Fatal("Failed to open:", err)
+	}
+
+	select {
+	case <-received:
+	case <-ctx.Done():
+		t.Fatal("Timed out waiting for Speaking")
+	}
+
+	// Wait for the rest of the events to fill up the buffer.
+	for len(s.gateway.Events) < WSBuffer {
+		select {
+		case <-ctx.Done():
+			t.Fatal("Timed out filling the events")
+		case <-time.After(time.Millisecond):
+		}
+	}
+
+	closed := make(chan error, 1)
+	go func() { closed <- s.Close() }()
+
+	select {
+	case <-closed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Close deadlocked with a full event buffer")
+	}
+}
+
+func TestGatewayHelloTimeout(t *testing.T) {
+	// A server that never sends Hello.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			t.Error("Failed to accept:", err)
+			return
+		}
+		defer c.Close(websocket.StatusInternalError, "")
+
+		// Read until the client closes the connection.
+		for {
+			if _, _, err := c.Read(r.Context()); err != nil {
+				return
+			}
+		}
+	}))
+	defer srv.Close()
+
+	g, err := NewGateway(State{
+		SessionID: "session",
+		Token:     "token",
+		Endpoint:  strings.Replace(srv.URL, "http://", "ws://", 1),
+	})
+	if err != nil {
+		t.Fatal("Failed to create gateway:", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	if err := g.Open(ctx); errors.Cause(err) != context.DeadlineExceeded {
+		t.Fatal("Unexpected error:", err)
+	}
+}
@@ -0,0 +1,195 @@
+package voice
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/nacl/secretbox"
+)
+
+const (
+	// FrameDuration is the duration of each Opus frame. Discord expects 20ms
+	// frames.
+	FrameDuration = 20 * time.Millisecond
+	// FrameSamples is the number of samples in each Opus frame per channel,
+	// which is 48kHz times 20ms.
+	FrameSamples = 960
+)
+
+// SilenceFrame is an Opus frame of silence. Discord recommends sending five of
+// these after the client stops speaking to avoid interpolation.
+var SilenceFrame = []byte{0xF8, 0xFF, 0xFE}
+
+var ErrUDPClosed = errors.New("UDP connection closed")
+
+// UDPConnection is the UDP connection to a voice server. It does IP discovery
+// and sends encrypted RTP packets containing Opus frames.
+type UDPConnection struct {
+	// External address and port found from IP discovery
+	Address string
+	Port    uint16
+
+	conn net.Conn
+	ssrc uint32
+
+	mut       sync.Mutex
+	sequence  uint16
+	timestamp uint32
+	header    [12]byte
+	nonce     [24]byte
+	secret    *[32]byte
+	buffer    []byte
+	pace      *time.Ticker
+}
+
+// DialUDP dials the voice server at the given address, which is the IP and
+// port from the Ready event, then does IP discovery.
+func DialUDP(ctx context.Context, addr string, ssrc uint32) (*UDPConnection, error) {
+	var dialer net.Dialer
+
+	conn, err := dialer.DialContext(ctx, "udp", addr)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to dial host")
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	address, port, err := discoverIP(conn, ssrc)
+	if err != nil {
+		conn.Close()
+		return nil, errors.Wrap(err, "Failed to discover IP")
+	}
+
+	// Clear the deadline set for discovery.
+	conn.SetDeadline(time.Time{})
+
+	u := &UDPConnection{
+		Address: address,
+		Port:    port,
+		conn:    conn,
+		ssrc:    ssrc,
+	}
+
+	// Version + Flags, Payload Type
+	u.header[0] = 0x80
+	u.header[1] = 0x78
+	binary.BigEndian.PutUint32(u.header[8:12], ssrc)
+
+	return u, nil
+}
+
+// https://discordapp.com/developers/docs/topics/voice-connections#ip-discovery
+func discoverIP(conn net.Conn, ssrc uint32) (string, uint16, error) {
+	var packet [74]byte
+
+	binary.BigEndian.PutUint16(packet[0:2], 0x1) // request
+	binary.BigEndian.PutUint16(packet[2:4], 70)  // length
+	binary.BigEndian.PutUint32(packet[4:8], ssrc)
+
+	if _, err := conn.Write(packet[:]); err != nil {
+		return "", 0, errors.Wrap(err, "Failed to write")
+	}
+
+	// Reuse the buffer for the response, which has the same size.
+	if _, err := conn.Read(packet[:]); err != nil {
+		return "", 0, errors.Wrap(err, "Failed to read")
+	}
+
+	if binary.BigEndian.Uint16(packet[0:2]) != 0x2 {
+		return "", 0, errors.New("Unexpected IP discovery response type")
+	}
+
+	// The address is a null-terminated string.
+	address := packet[8:72]
+	if i := bytes.IndexByte(address, 0); i > -1 {
+		address = address[:i]
+	}
+
+	port := binary.BigEndian.Uint16(packet[72:74])
+
+	return string(address), port, nil
+}
+
+// UseSecret sets the secret key received from the Session Description. Write
+// can't be used before this is called.
+func (u *UDPConnection) UseSecret(secret [32]byte) {
+	u.mut.Lock()
+	defer u.mut.Unlock()
+
+	u.secret = &secret
+
+	if u.pace == nil {
+		u.pace = time.NewTicker(FrameDuration)
+	}
+}
+
+// Write encrypts and sends an Opus frame. Each call is paced to FrameDuration,
+// as Discord expects frames to arrive in real time.
+func (u *UDPConnection) Write(frame []byte) (int, error) {
+	u.mut.Lock()
+	defer u.mut.Unlock()
+
+	if u.conn == nil {
+		return 0, ErrUDPClosed
+	}
+
+	if u.secret == nil {
+		return 0, errors.New("no secret key, UseSecret not called")
+	}
+
+	binary.BigEndian.PutUint16(u.header[2:4], u.sequence)
+	binary.BigEndian.PutUint32(u.header[4:8], u.timestamp)
+
+	// The nonce is the RTP header padded with zeroes.
+	copy(u.nonce[:], u.header[:])
+
+	u.buffer = secretbox.Seal(append(u.buffer[:0], u.header[:]...),
+		frame, &u.nonce, u.secret)
+
+	<-u.pace.C
+
+	if _, err := u.conn.Write(u.buffer); err != nil {
+		return 0, errors.Wrap(err, "Failed to write packet")
+	}
+
+	u.sequence++
+	u.timestamp += FrameSamples
+
+	return len(frame), nil
+}
+
+// RemoteAddr returns the address of the voice server as a string.
+func (u *UDPConnection) RemoteAddr() string {
+	return u.conn.RemoteAddr().String()
+}
+
+// Close closes the UDP connection.
+func (u *UDPConnection) Close() error {
+	u.mut.Lock()
+	defer u.mut.Unlock()
+
+	if u.conn == nil {
+		return nil
+	}
+
+	if u.pace != nil {
+		u.pace.Stop()
+	}
+
+	err := u.conn.Close()
+	u.conn = nil
+
+	return err
+}
+
+func joinHostPort(ip string, port int) string {
+	return net.JoinHostPort(ip, strconv.Itoa(port))
+}